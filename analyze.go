@@ -0,0 +1,95 @@
+package lexicon
+
+// Analysis is the report of key and structure statistics by Analyze. The
+// histograms map a length or count to the number of its occurrences
+type Analysis struct {
+	NumKeys     int
+	NumSlots    int
+	UsedSlots   int
+	SuffixBytes int
+
+	// Lengths of keys in bytes
+	KeyLengths map[int]int
+
+	// Number of children of nodes in double array, not including value
+	// slots and links to suffixes
+	Branching map[int]int
+
+	// Lengths of suffixes in bytes, not including the terminating '\x00'
+	SuffixLengths map[int]int
+
+	// Ratio of used slots in each block of BlockSize slots. The block size is
+	// not stored in file, so it is 256 for Lexicon not built in this process
+	BlockSize      int
+	BlockFillRates []float64
+}
+
+// Analyze computes statistics of keys and the double array, to help decide
+// preprocessing of keys and build options like WithSuffixLength or
+// WithBlockSize. It walks all keys and slots once
+func (t *Lexicon) Analyze() *Analysis {
+	a := &Analysis{
+		NumSlots:      len(t.slots),
+		SuffixBytes:   len(t.suffix),
+		KeyLengths:    map[int]int{},
+		Branching:     map[int]int{},
+		SuffixLengths: map[int]int{},
+		BlockSize:     t.blockSize,
+	}
+	if a.BlockSize == 0 {
+		a.BlockSize = 256
+	}
+
+	t.walkPrefix("", func(key string, value int32) bool {
+		a.NumKeys++
+		a.KeyLengths[len(key)]++
+		return true
+	})
+
+	// Count children of each node by their parents
+	numChildren := make([]int, len(t.slots))
+	for i := 1; i < len(t.slots); i++ {
+		slot := &t.slots[i]
+		if slot.empty() {
+			continue
+		}
+		if t.slots[slot.Check].Base != int32(i) {
+			numChildren[slot.Check]++
+		}
+	}
+	for i := range t.slots {
+		slot := &t.slots[i]
+		if slot.empty() {
+			continue
+		}
+		a.UsedSlots++
+		isValue := i != 0 && t.slots[slot.Check].Base == int32(i)
+		if !isValue && slot.Base >= 0 {
+			a.Branching[numChildren[i]]++
+		}
+	}
+
+	for _, begin := range t.suffixIndex {
+		length := 0
+		for t.suffix[int(begin)+length] != '\x00' {
+			length++
+		}
+		a.SuffixLengths[length]++
+	}
+
+	for begin := 0; begin < len(t.slots); begin += a.BlockSize {
+		end := begin + a.BlockSize
+		if end > len(t.slots) {
+			end = len(t.slots)
+		}
+		used := 0
+		for i := begin; i < end; i++ {
+			if !t.slots[i].empty() {
+				used++
+			}
+		}
+		a.BlockFillRates = append(a.BlockFillRates, float64(used)/float64(a.BlockSize))
+	}
+
+	return a
+}
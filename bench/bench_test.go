@@ -0,0 +1,168 @@
+package bench
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/ling0322/lexicon"
+)
+
+// datasets are shared by all benchmarks since generating them is slow
+var datasets []*Dataset
+var datasetsOnce sync.Once
+
+// loadDatasets generates datasets on first call
+func loadDatasets() []*Dataset {
+	datasetsOnce.Do(func() {
+		datasets = Datasets()
+	})
+	return datasets
+}
+
+func BenchmarkBuild(b *testing.B) {
+	for _, d := range loadDatasets() {
+		b.Run(d.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			var retained int64
+			for i := 0; i < b.N; i++ {
+				var before, after runtime.MemStats
+				runtime.GC()
+				runtime.ReadMemStats(&before)
+				t, err := lexicon.Build(d.Dict, nil)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				// Memory still used by the lexicon after intermediate data
+				// of building is collected
+				b.StopTimer()
+				runtime.GC()
+				runtime.ReadMemStats(&after)
+				retained = int64(after.HeapAlloc) - int64(before.HeapAlloc)
+				runtime.KeepAlive(t)
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(retained), "retained-bytes")
+		})
+	}
+}
+
+// encodings are the file encodings measured by BenchmarkFileSize
+var encodings = []struct {
+	name string
+	opts []lexicon.SaveOption
+}{
+	{"plain", nil},
+	{"packed", []lexicon.SaveOption{lexicon.WithPackedSuffix()}},
+	{"compact", []lexicon.SaveOption{lexicon.WithCompactEncoding()}},
+	{"small", []lexicon.SaveOption{lexicon.WithSmallSlots()}},
+}
+
+func BenchmarkFileSize(b *testing.B) {
+	for _, d := range loadDatasets() {
+		t, err := lexicon.Build(d.Dict, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, encoding := range encodings {
+			b.Run(d.Name+"/"+encoding.name, func(b *testing.B) {
+				filename := filepath.Join(b.TempDir(), "lexicon.reimu")
+				for i := 0; i < b.N; i++ {
+					err = t.Save(filename, encoding.opts...)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.StopTimer()
+
+				info, err := os.Stat(filename)
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.ReportMetric(float64(info.Size()), "file-bytes")
+				b.ReportMetric(float64(info.Size())/float64(len(d.Dict)), "bytes/key")
+			})
+		}
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	for _, d := range loadDatasets() {
+		t, err := lexicon.Build(d.Dict, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(d.Name+"/lexicon", func(b *testing.B) {
+			sum := int32(0)
+			for i := 0; i < b.N; i++ {
+				v, _ := t.Get(d.Queries[i%len(d.Queries)])
+				sum += v
+			}
+		})
+		b.Run(d.Name+"/map", func(b *testing.B) {
+			sum := int32(0)
+			for i := 0; i < b.N; i++ {
+				sum += d.Dict[d.Queries[i%len(d.Queries)]]
+			}
+		})
+	}
+}
+
+// BenchmarkGetMiss gets missing keys only, with and without Bloom filter
+func BenchmarkGetMiss(b *testing.B) {
+	for _, d := range loadDatasets() {
+		misses := []string{}
+		for _, query := range d.Queries {
+			if _, ok := d.Dict[query]; !ok {
+				misses = append(misses, query)
+			}
+		}
+
+		for _, bitsPerKey := range []int{0, 10} {
+			t, err := lexicon.Build(d.Dict, nil, lexicon.WithBloomFilter(bitsPerKey))
+			if err != nil {
+				b.Fatal(err)
+			}
+			name := d.Name + "/lexicon"
+			if bitsPerKey > 0 {
+				name = d.Name + "/bloom"
+			}
+			b.Run(name, func(b *testing.B) {
+				found := 0
+				for i := 0; i < b.N; i++ {
+					if _, ok := t.Get(misses[i%len(misses)]); ok {
+						found++
+					}
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkGetSkewed gets keys in Zipf distribution, where a few keys
+// dominate the queries
+func BenchmarkGetSkewed(b *testing.B) {
+	for _, d := range loadDatasets() {
+		zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.2, 1, uint64(len(d.Queries)-1))
+		queries := make([]string, 1<<16)
+		for i := range queries {
+			queries[i] = d.Queries[zipf.Uint64()]
+		}
+
+		t, err := lexicon.Build(d.Dict, nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run(d.Name+"/lexicon", func(b *testing.B) {
+			sum := int32(0)
+			for i := 0; i < b.N; i++ {
+				v, _ := t.Get(queries[i%len(queries)])
+				sum += v
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+// Package bench contains benchmarks of lexicon on fixed datasets. Datasets
+// are generated by a fixed seed, so results are comparable between runs and
+// machines without shipping large word lists in the repository. Run them by:
+//
+//	go test -bench . ./bench
+package bench
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// Dataset is a fixed set of keys for benchmarking, together with queries
+// which are half hits and half misses
+type Dataset struct {
+	Name    string
+	Dict    map[string]int32
+	Queries []string
+}
+
+// Datasets returns all datasets for benchmarking
+func Datasets() []*Dataset {
+	return []*Dataset{
+		newDataset("english", 100000, englishWord),
+		newDataset("cjk", 100000, cjkWord),
+		newDataset("url", 100000, url),
+	}
+}
+
+// newDataset generates a dataset with n keys by generator gen
+func newDataset(name string, n int, gen func(*rand.Rand) string) *Dataset {
+	rng := rand.New(rand.NewSource(20160822))
+	d := &Dataset{
+		Name: name,
+		Dict: map[string]int32{},
+	}
+	for len(d.Dict) < n {
+		key := gen(rng)
+		if _, ok := d.Dict[key]; !ok {
+			d.Dict[key] = int32(len(d.Dict))
+			d.Queries = append(d.Queries, key)
+		}
+	}
+
+	// Misses
+	for len(d.Queries) < 2*n {
+		key := gen(rng)
+		if _, ok := d.Dict[key]; !ok {
+			d.Queries = append(d.Queries, key)
+		}
+	}
+	rng.Shuffle(len(d.Queries), func(i, j int) {
+		d.Queries[i], d.Queries[j] = d.Queries[j], d.Queries[i]
+	})
+
+	return d
+}
+
+var syllables = []string{
+	"a", "al", "an", "ar", "be", "ble", "ca", "con", "de", "di", "en", "er",
+	"ex", "fi", "ga", "in", "ing", "is", "ka", "la", "le", "li", "ly", "ma",
+	"ment", "mi", "na", "ne", "ni", "no", "o", "per", "pro", "ra", "re", "ri",
+	"ro", "sa", "se", "si", "sion", "ta", "te", "ter", "ti", "tion", "to",
+	"tu", "un", "ver",
+}
+
+// englishWord generates an English-like word of 1 to 5 syllables, with
+// inflections
+func englishWord(rng *rand.Rand) string {
+	var b strings.Builder
+	for n := rng.Intn(5) + 1; n > 0; n-- {
+		b.WriteString(syllables[rng.Intn(len(syllables))])
+	}
+	switch rng.Intn(4) {
+	case 0:
+		b.WriteString("s")
+	case 1:
+		b.WriteString("ed")
+	}
+	return b.String()
+}
+
+// cjkWord generates a word of 1 to 4 characters from the 3000 most common
+// CJK unified ideographs range, most words have 2 characters as in Chinese
+// dictionaries
+func cjkWord(rng *rand.Rand) string {
+	length := []int{1, 2, 2, 2, 2, 3, 3, 4}[rng.Intn(8)]
+	runes := make([]rune, length)
+	for i := range runes {
+		// Skewed to the beginning of the range
+		runes[i] = rune(0x4e00 + rng.Intn(rng.Intn(3000)+1))
+	}
+	return string(runes)
+}
+
+var hosts = []string{
+	"example.com", "www.example.org", "api.example.net", "cdn.example.io",
+	"docs.example.dev", "blog.example.co.uk",
+}
+
+// url generates an URL with a long shared scheme and host
+func url(rng *rand.Rand) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "https://%s", hosts[rng.Intn(len(hosts))])
+	for n := rng.Intn(4) + 1; n > 0; n-- {
+		fmt.Fprintf(&b, "/%s", englishWord(rng))
+	}
+	if rng.Intn(3) == 0 {
+		fmt.Fprintf(&b, "?id=%d", rng.Intn(100000))
+	}
+	return b.String()
+}
@@ -0,0 +1,126 @@
+package lexicon
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// bloomFilter is the Bloom filter of keys, Get checks it before traversing
+// the double array, so most of missing keys are rejected by reading k bits
+type bloomFilter struct {
+	numHashes uint32
+	bits      []uint64
+}
+
+// WithBloomFilter builds a Bloom filter of keys with bitsPerKey bits for each
+// key, which is stored in file and checked by Get before traversal. It speeds
+// up workloads of mostly missing keys sharing long prefixes with existing
+// keys, like URLs, while misses rejected at the first bytes of traversal
+// could be slightly slower (see BenchmarkGetMiss). 10 bits per key gives
+// about 1% false positive rate. 0 means no filter, which is the default
+func WithBloomFilter(bitsPerKey int) Option {
+	return func(o *buildOptions) {
+		o.bloomBitsPerKey = bitsPerKey
+	}
+}
+
+// bloomHash returns the FNV-1a hash of key, without allocation
+func bloomHash(key string) uint64 {
+	const offset64 = 14695981039346656037
+	const prime64 = 1099511628211
+
+	h := uint64(offset64)
+	for i := 0; i < len(key); i++ {
+		h ^= uint64(key[i])
+		h *= prime64
+	}
+	return h
+}
+
+// newBloomFilter builds the Bloom filter of keys in dict
+func newBloomFilter(dict map[string]int32, bitsPerKey int) *bloomFilter {
+	numHashes := int(math.Round(float64(bitsPerKey) * math.Ln2))
+	if numHashes < 1 {
+		numHashes = 1
+	} else if numHashes > 30 {
+		numHashes = 30
+	}
+
+	numBits := len(dict) * bitsPerKey
+	if numBits < 64 {
+		numBits = 64
+	}
+	f := &bloomFilter{
+		numHashes: uint32(numHashes),
+		bits:      make([]uint64, (numBits+63)/64),
+	}
+	for key := range dict {
+		f.add(key)
+	}
+	return f
+}
+
+// add adds key into filter. The k bit positions are derived from one hash by
+// double hashing
+func (f *bloomFilter) add(key string) {
+	h := bloomHash(key)
+	delta := h>>32 | 1
+	numBits := uint64(len(f.bits)) * 64
+	for i := uint32(0); i < f.numHashes; i++ {
+		bit := h % numBits
+		f.bits[bit/64] |= 1 << (bit % 64)
+		h += delta
+	}
+}
+
+// mayContain returns false if key is definitely not in filter
+func (f *bloomFilter) mayContain(key string) bool {
+	h := bloomHash(key)
+	delta := h>>32 | 1
+	numBits := uint64(len(f.bits)) * 64
+	for i := uint32(0); i < f.numHashes; i++ {
+		bit := h % numBits
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+		h += delta
+	}
+	return true
+}
+
+// clone returns a deep copy of filter
+func (f *bloomFilter) clone() *bloomFilter {
+	return &bloomFilter{
+		numHashes: f.numHashes,
+		bits:      append([]uint64{}, f.bits...),
+	}
+}
+
+// marshal encodes the filter into bytes: number of hashes then the bits
+func (f *bloomFilter) marshal() []byte {
+	payload := make([]byte, 4+len(f.bits)*8)
+	binary.LittleEndian.PutUint32(payload, f.numHashes)
+	for i, word := range f.bits {
+		binary.LittleEndian.PutUint64(payload[4+i*8:], word)
+	}
+	return payload
+}
+
+// readBloomFilter reads the filter from payload of its section
+func readBloomFilter(payload []byte) (*bloomFilter, error) {
+	if len(payload) < 12 || (len(payload)-4)%8 != 0 {
+		return nil, ErrCorrupted
+	}
+
+	f := &bloomFilter{
+		numHashes: binary.LittleEndian.Uint32(payload),
+		bits:      make([]uint64, (len(payload)-4)/8),
+	}
+	if f.numHashes < 1 || f.numHashes > 30 {
+		return nil, ErrCorrupted
+	}
+	for i := range f.bits {
+		f.bits[i] = binary.LittleEndian.Uint64(payload[4+i*8:])
+	}
+	return f, nil
+}
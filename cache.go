@@ -0,0 +1,74 @@
+package lexicon
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CachedLexicon caches the results of recent Get, both hits and misses, and
+// evicts the least recently used ones when it is full. It helps workloads of
+// heavy-tailed repeated queries. It is safe for concurrent use
+type CachedLexicon struct {
+	t          *Lexicon
+	maxEntries int
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// cachedEntry is a result of Get in CachedLexicon
+type cachedEntry struct {
+	key   string
+	value int32
+	ok    bool
+}
+
+// NewCachedLexicon creates the CachedLexicon of t, which caches at most
+// maxEntries results
+func NewCachedLexicon(t *Lexicon, maxEntries int) *CachedLexicon {
+	return &CachedLexicon{
+		t:          t,
+		maxEntries: maxEntries,
+		entries:    map[string]*list.Element{},
+		lru:        list.New(),
+	}
+}
+
+// Lexicon returns the underlying Lexicon
+func (c *CachedLexicon) Lexicon() *Lexicon {
+	return c.t
+}
+
+// Get is Get of the underlying Lexicon, returns the cached result if any
+func (c *CachedLexicon) Get(key string) (value int32, ok bool) {
+	c.mutex.Lock()
+	if e, found := c.entries[key]; found {
+		c.lru.MoveToFront(e)
+		entry := e.Value.(*cachedEntry)
+		c.mutex.Unlock()
+		return entry.value, entry.ok
+	}
+	c.mutex.Unlock()
+
+	value, ok = c.t.Get(key)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, found := c.entries[key]; !found && c.maxEntries > 0 {
+		c.entries[key] = c.lru.PushFront(&cachedEntry{key, value, ok})
+		for c.lru.Len() > c.maxEntries {
+			oldest := c.lru.Back()
+			c.lru.Remove(oldest)
+			delete(c.entries, oldest.Value.(*cachedEntry).key)
+		}
+	}
+	return value, ok
+}
+
+// Len returns the number of cached results
+func (c *CachedLexicon) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lru.Len()
+}
@@ -0,0 +1,165 @@
+package lexicon
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// CedictHeadword selects which headwords of CC-CEDICT entries are indexed
+type CedictHeadword int
+
+const (
+	CedictSimplified CedictHeadword = 1 << iota
+	CedictTraditional
+)
+
+// CedictEntry is an entry of CC-CEDICT
+type CedictEntry struct {
+	Traditional string   `json:"traditional"`
+	Simplified  string   `json:"simplified"`
+	Pinyin      string   `json:"pinyin"`
+	Definitions []string `json:"definitions"`
+}
+
+// ReadCedict reads CC-CEDICT, where each line is "traditional simplified
+// [pinyin] /definition 1/definition 2/". Returns the entries of each
+// headword selected by 'headwords', in the order of file. A headword may
+// have several entries, e.g. for different readings
+func ReadCedict(r io.Reader, headwords CedictHeadword) (map[string][]CedictEntry, error) {
+	dict := map[string][]CedictEntry{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry, err := parseCedictLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if headwords&CedictSimplified != 0 {
+			dict[entry.Simplified] = append(dict[entry.Simplified], entry)
+		}
+		if headwords&CedictTraditional != 0 && (headwords&CedictSimplified == 0 ||
+			entry.Traditional != entry.Simplified) {
+			dict[entry.Traditional] = append(dict[entry.Traditional], entry)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return dict, nil
+}
+
+// parseCedictLine parses an entry of CC-CEDICT
+func parseCedictLine(line string) (CedictEntry, error) {
+	entry := CedictEntry{}
+	fields := strings.SplitN(line, " ", 3)
+	if len(fields) != 3 {
+		return entry, fmt.Errorf("invalid entry: %s", line)
+	}
+	entry.Traditional, entry.Simplified = fields[0], fields[1]
+
+	rest := fields[2]
+	begin := strings.IndexByte(rest, '[')
+	end := strings.IndexByte(rest, ']')
+	if begin != 0 || end < 0 {
+		return entry, fmt.Errorf("invalid pinyin: %s", line)
+	}
+	entry.Pinyin = rest[1:end]
+
+	definitions := strings.TrimSpace(rest[end+1:])
+	if !strings.HasPrefix(definitions, "/") || !strings.HasSuffix(definitions, "/") {
+		return entry, fmt.Errorf("invalid definitions: %s", line)
+	}
+	for _, d := range strings.Split(definitions[1:len(definitions)-1], "/") {
+		if d != "" {
+			entry.Definitions = append(entry.Definitions, d)
+		}
+	}
+	return entry, nil
+}
+
+// BuildCedict builds the reimu-trie from CC-CEDICT, indexed by headwords.
+// The entries of each headword are stored as payload by JSONCodec, which
+// could be got by GetPayload into a []CedictEntry
+func BuildCedict(
+	r io.Reader,
+	headwords CedictHeadword,
+	progress func(int, int),
+	opts ...Option) (*Lexicon, error) {
+	entries, err := ReadCedict(r, headwords)
+	if err != nil {
+		return nil, err
+	}
+
+	dict := make(map[string]interface{}, len(entries))
+	for headword, e := range entries {
+		dict[headword] = e
+	}
+	return BuildPayloads(dict, JSONCodec{}, progress, opts...)
+}
+
+// CedictRuneMap reads CC-CEDICT and derives the table of characters of the
+// other form to the form 'to', which is CedictSimplified or
+// CedictTraditional, for RuneMap. Characters are aligned in headwords of the
+// same length. Traditional to simplified is mostly many to one, while the
+// reverse is one to many (e.g. "发" to "發" and "髮"), where the most frequent
+// one is chosen. So building a simplified dictionary and mapping queries to
+// simplified is recommended
+func CedictRuneMap(r io.Reader, to CedictHeadword) (map[rune]rune, error) {
+	counts := map[rune]map[rune]int{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entry, err := parseCedictLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		from, target := []rune(entry.Traditional), []rune(entry.Simplified)
+		if to == CedictTraditional {
+			from, target = target, from
+		}
+		if len(from) != len(target) {
+			continue
+		}
+		for i := range from {
+			if from[i] == target[i] {
+				continue
+			}
+			if counts[from[i]] == nil {
+				counts[from[i]] = map[rune]int{}
+			}
+			counts[from[i]][target[i]]++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	table := make(map[rune]rune, len(counts))
+	for from, targets := range counts {
+		best := rune(-1)
+		for target, count := range targets {
+			if best < 0 || count > targets[best] || count == targets[best] && target < best {
+				best = target
+			}
+		}
+		table[from] = best
+	}
+	return table, nil
+}
@@ -0,0 +1,69 @@
+package lexicon
+
+import (
+	"sort"
+)
+
+// Chain resolves lookups across several lexicons by priority, e.g. user
+// dictionary -> domain dictionary -> base dictionary. A key in a lexicon
+// hides the same key in all lexicons after it
+type Chain struct {
+	lexicons []*Lexicon
+}
+
+// NewChain creates a Chain of lexicons, from the highest priority to the
+// lowest
+func NewChain(lexicons []*Lexicon) *Chain {
+	return &Chain{
+		lexicons: append([]*Lexicon{}, lexicons...),
+	}
+}
+
+// Get gets the value by key from the first lexicon which has it
+func (c *Chain) Get(key string) (value int32, ok bool) {
+	for _, t := range c.lexicons {
+		if value, ok := t.Get(key); ok {
+			return value, true
+		}
+	}
+	return -1, false
+}
+
+// Complete is the same as Lexicon.Complete, but over all lexicons in chain.
+// For a key in several lexicons, the value from the first one is returned
+func (c *Chain) Complete(prefix string, maxEdits int) []Entry {
+	seen := map[string]bool{}
+	fuzzyEntries := []fuzzyEntry{}
+	for _, t := range c.lexicons {
+		if maxEdits <= 0 {
+			for _, e := range t.Complete(prefix, 0) {
+				if !seen[e.Key] {
+					seen[e.Key] = true
+					fuzzyEntries = append(fuzzyEntries, fuzzyEntry{e, 0})
+				}
+			}
+			continue
+		}
+
+		t.fuzzyComplete(prefix, maxEdits, func(e fuzzyEntry) bool {
+			if !seen[e.Key] {
+				seen[e.Key] = true
+				fuzzyEntries = append(fuzzyEntries, e)
+			}
+			return true
+		})
+	}
+
+	sort.Slice(fuzzyEntries, func(i, j int) bool {
+		if fuzzyEntries[i].distance != fuzzyEntries[j].distance {
+			return fuzzyEntries[i].distance < fuzzyEntries[j].distance
+		}
+		return fuzzyEntries[i].Key < fuzzyEntries[j].Key
+	})
+	entries := make([]Entry, len(fuzzyEntries))
+	for i, e := range fuzzyEntries {
+		entries[i] = e.Entry
+	}
+
+	return entries
+}
@@ -0,0 +1,75 @@
+package lexicon
+
+// Candidate is one hypothesis in multi-candidate traversal. Key is the bytes
+// consumed to reach State
+type Candidate struct {
+	Key      string
+	Value    int32
+	HasValue bool
+	State    State
+}
+
+// TraverseClasses traverses the Lexicon from the initial state, where the
+// i-th byte of the key could be any byte in classes[i]. It is useful for
+// T9-style input (classes[i] = letters on a key) or keyboard-layout tolerant
+// lookup. Returns all candidates that are still valid after the last class,
+// whether or not they have values
+func (t *Lexicon) TraverseClasses(classes [][]byte) []Candidate {
+	candidates := []Candidate{{State: InitialState()}}
+	for _, class := range classes {
+		// Remove duplicated bytes in class, otherwise we will get duplicated
+		// candidates
+		var seen [256]bool
+		bytes := make([]byte, 0, len(class))
+		for _, b := range class {
+			if !seen[b] {
+				seen[b] = true
+				bytes = append(bytes, b)
+			}
+		}
+
+		nextCandidates := []Candidate{}
+		for _, c := range candidates {
+			for _, b := range bytes {
+				s := c.State
+				if t.next(&s, b) {
+					nextCandidates = append(nextCandidates, Candidate{
+						Key:   c.Key + string([]byte{b}),
+						State: s,
+					})
+				}
+			}
+		}
+		candidates = nextCandidates
+		if len(candidates) == 0 {
+			break
+		}
+	}
+
+	for i := range candidates {
+		c := &candidates[i]
+		// The initial state has no key, even though its value slot is set
+		if c.State.state != 0 {
+			c.Value, c.HasValue = t.value(&c.State)
+		}
+	}
+
+	return candidates
+}
+
+// StepAll advances each state in 'states' by byte 'b' at once. Returns the
+// advanced states in the same order as 'states', so callers could keep their
+// own data of each hypothesis aligned. States that failed to advance are
+// invalid (Valid() = false) in the result
+func (t *Lexicon) StepAll(states []State, b byte) []State {
+	nextStates := make([]State, len(states))
+	copy(nextStates, states)
+	for i := range nextStates {
+		s := &nextStates[i]
+		if s.Valid() {
+			t.next(s, b)
+		}
+	}
+
+	return nextStates
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/ling0322/lexicon"
+)
+
+// runConvert converts a dictionary between formats
+func runConvert(args []string) int {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	from := flags.String("from", "reimu", "format of input: reimu (any version), tsv, front or darts (darts-clone)")
+	to := flags.String("to", "reimu", "format of output: reimu, reimu-packed, reimu-compact, reimu-small, reimu-v1, tsv or front")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lexicon convert [-from format] [-to format] input output\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+	input, output := flags.Arg(0), flags.Arg(1)
+
+	t, err := readDict(*from, input)
+	if err != nil {
+		return fatalf("%s: %v", input, err)
+	}
+	if err = writeDict(t, *to, output); err != nil {
+		return fatalf("%s: %v", output, err)
+	}
+
+	return 0
+}
+
+// readDict reads the dictionary in format from filename
+func readDict(format, filename string) (*lexicon.Lexicon, error) {
+	switch format {
+	case "reimu":
+		return lexicon.Read(filename)
+	case "darts":
+		d, err := lexicon.ReadDartsClone(filename)
+		if err != nil {
+			return nil, err
+		}
+		return d.ToLexicon(nil)
+	case "tsv", "front":
+		fd, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer fd.Close()
+
+		var dict map[string]int32
+		if format == "tsv" {
+			dict, err = lexicon.ReadTSV(fd)
+		} else {
+			dict, err = lexicon.ReadFrontCoded(fd)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return lexicon.Build(dict, nil)
+	}
+
+	return nil, fmt.Errorf("unknown input format: %s", format)
+}
+
+// writeDict writes the dictionary t in format to filename
+func writeDict(t *lexicon.Lexicon, format, filename string) error {
+	var write func(fd *os.File) error
+	switch format {
+	case "reimu":
+		return t.Save(filename)
+	case "reimu-packed":
+		return t.Save(filename, lexicon.WithPackedSuffix())
+	case "reimu-compact":
+		return t.Save(filename, lexicon.WithCompactEncoding())
+	case "reimu-small":
+		return t.Save(filename, lexicon.WithSmallSlots())
+	case "reimu-v1":
+		write = func(fd *os.File) error { return t.WriteV1(fd) }
+	case "tsv":
+		write = func(fd *os.File) error { return t.WriteTSV(fd) }
+	case "front":
+		write = func(fd *os.File) error { return t.WriteFrontCoded(fd) }
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+
+	fd, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err = write(fd); err != nil {
+		fd.Close()
+		return err
+	}
+	return fd.Close()
+}
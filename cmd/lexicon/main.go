@@ -0,0 +1,62 @@
+// Command lexicon works with reimu-trie lexicon files from shell.
+//
+// Usage:
+//
+//	lexicon <command> [arguments]
+//
+// Commands are:
+//
+//	verify    check the integrity of a lexicon file
+//	repl      look up a lexicon file interactively
+//	segment   segment text from stdin by a dictionary
+//	convert   convert a dictionary between formats
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command is a subcommand of lexicon, run returns the exit code
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
+}
+
+var commands = []command{
+	{"verify", "check the integrity of a lexicon file", runVerify},
+	{"repl", "look up a lexicon file interactively", runRepl},
+	{"segment", "segment text from stdin by a dictionary", runSegment},
+	{"convert", "convert a dictionary between formats", runConvert},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: lexicon <command> [arguments]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s%s\n", c.name, c.usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name == os.Args[1] {
+			os.Exit(c.run(os.Args[2:]))
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "lexicon: unknown command: %s\n", os.Args[1])
+	usage()
+	os.Exit(2)
+}
+
+// fatalf prints the error message to stderr and returns the exit code 1
+func fatalf(format string, args ...interface{}) int {
+	fmt.Fprintf(os.Stderr, "lexicon: "+format+"\n", args...)
+	return 1
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/ling0322/lexicon"
+)
+
+const replHelp = `Commands:
+  get <key>        look up the value of key
+  prefix <prefix>  list keys starting with prefix
+  longest <text>   find the longest key which is a prefix of text
+  help             show this message
+  quit             exit
+`
+
+// runRepl looks up a lexicon file interactively
+func runRepl(args []string) int {
+	flags := flag.NewFlagSet("repl", flag.ExitOnError)
+	limit := flags.Int("limit", 20, "max number of keys listed by prefix")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lexicon repl [-limit n] file.reimu\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	t, err := lexicon.Read(flags.Arg(0))
+	if err != nil {
+		return fatalf("%v", err)
+	}
+
+	fmt.Print(replHelp)
+	repl(t, os.Stdin, os.Stdout, *limit)
+	return 0
+}
+
+// repl reads commands from r and writes results to w until quit or EOF
+func repl(t *lexicon.Lexicon, r io.Reader, w io.Writer, limit int) {
+	scanner := bufio.NewScanner(r)
+	for {
+		fmt.Fprint(w, "> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(w)
+			return
+		}
+
+		// The argument is the rest of line, so keys could contain spaces
+		line := strings.TrimSpace(scanner.Text())
+		command, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			command, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+
+		switch command {
+		case "":
+		case "get":
+			if value, ok := t.Get(arg); ok {
+				fmt.Fprintf(w, "%q\t%d\n", arg, value)
+			} else {
+				fmt.Fprintf(w, "%q not found\n", arg)
+			}
+		case "prefix":
+			n := 0
+			t.WalkPrefix(t.Normalize(arg), func(key string, value int32) bool {
+				if n >= limit {
+					fmt.Fprintf(w, "... (more than %d keys)\n", limit)
+					return false
+				}
+				fmt.Fprintf(w, "%q\t%d\n", key, value)
+				n++
+				return true
+			})
+			if n == 0 {
+				fmt.Fprintf(w, "no key starts with %q\n", arg)
+			}
+		case "longest":
+			if match, ok := t.LongestPrefix(t.Normalize(arg)); ok {
+				fmt.Fprintf(w, "%q\t%d\n", match.Key, match.Value)
+			} else {
+				fmt.Fprintf(w, "no key is a prefix of %q\n", arg)
+			}
+		case "help":
+			fmt.Fprint(w, replHelp)
+		case "quit", "exit":
+			return
+		default:
+			fmt.Fprintf(w, "unknown command: %s, type help for commands\n", command)
+		}
+	}
+}
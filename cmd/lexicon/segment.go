@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ling0322/lexicon"
+)
+
+// runSegment segments text from stdin by the dictionary, and writes tokens
+// to stdout
+func runSegment(args []string) int {
+	flags := flag.NewFlagSet("segment", flag.ExitOnError)
+	dict := flags.String("dict", "", "lexicon file as dictionary")
+	lines := flags.Bool("lines", false, "write one token per line instead of space-joined lines")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lexicon segment -dict file.reimu [-lines] < input\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if *dict == "" || flags.NArg() != 0 {
+		flags.Usage()
+		return 2
+	}
+
+	t, err := lexicon.Read(*dict)
+	if err != nil {
+		return fatalf("%v", err)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	w := bufio.NewWriter(os.Stdout)
+	for scanner.Scan() {
+		tokens := t.Segment(t.Normalize(scanner.Text()))
+		if *lines {
+			for _, token := range tokens {
+				w.WriteString(token)
+				w.WriteByte('\n')
+			}
+		} else {
+			w.WriteString(strings.Join(tokens, " "))
+			w.WriteByte('\n')
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return fatalf("%v", err)
+	}
+	if err = w.Flush(); err != nil {
+		return fatalf("%v", err)
+	}
+
+	return 0
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ling0322/lexicon"
+)
+
+// maxMismatches is the max number of mismatched keys printed by verify
+const maxMismatches = 10
+
+// runVerify checks the checksum and structure of a lexicon file, and
+// optionally that it contains exactly the keys and values in source TSV
+func runVerify(args []string) int {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	source := flags.String("tsv", "", "source TSV to compare key by key")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: lexicon verify [-tsv source.tsv] file.reimu\n")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	// Read checks checksum and suffixes
+	t, err := lexicon.Read(flags.Arg(0))
+	if err != nil {
+		return fatalf("%v", err)
+	}
+	if err = t.Verify(); err != nil {
+		return fatalf("%s: %v", flags.Arg(0), err)
+	}
+
+	if *source != "" {
+		fd, err := os.Open(*source)
+		if err != nil {
+			return fatalf("%v", err)
+		}
+		dict, err := lexicon.ReadTSV(fd)
+		fd.Close()
+		if err != nil {
+			return fatalf("%s: %v", *source, err)
+		}
+		if mismatches := compareDict(t, dict); mismatches > 0 {
+			return fatalf("%d mismatches with %s", mismatches, *source)
+		}
+	}
+
+	fmt.Printf("%s: OK\n", flags.Arg(0))
+	return 0
+}
+
+// compareDict compares the keys and values in t with dict, prints the
+// mismatches and returns the number of them
+func compareDict(t *lexicon.Lexicon, dict map[string]int32) int {
+	mismatches := 0
+	report := func(format string, args ...interface{}) {
+		if mismatches < maxMismatches {
+			fmt.Fprintf(os.Stderr, format+"\n", args...)
+		}
+		mismatches++
+	}
+
+	// Keys in lexicon are normalized by its transforms, if several keys are
+	// normalized to the same one, the value of the smallest key is kept
+	keys := make([]string, 0, len(dict))
+	for key := range dict {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	expected := map[string]int32{}
+	for _, key := range keys {
+		if _, ok := expected[t.Normalize(key)]; !ok {
+			expected[t.Normalize(key)] = dict[key]
+		}
+	}
+
+	for _, key := range keys {
+		value := expected[t.Normalize(key)]
+		if v, ok := t.Get(key); !ok {
+			report("missing key %q", key)
+		} else if v != value {
+			report("key %q: expect %d but got %d", key, value, v)
+		}
+	}
+
+	t.WalkPrefix("", func(key string, value int32) bool {
+		if _, ok := expected[key]; !ok {
+			report("unexpected key %q", key)
+		}
+		return true
+	})
+
+	return mismatches
+}
@@ -0,0 +1,46 @@
+package lexicon
+
+import (
+	"sort"
+)
+
+// Collator compares strings in a linguistic order, returns -1, 0 or 1 like
+// strings.Compare. *collate.Collator of golang.org/x/text/collate implements
+// it, e.g. collate.New(language.German)
+type Collator interface {
+	CompareString(a, b string) int
+}
+
+// sortCollated sorts entries by c, entries equal by c are ordered by bytes
+func sortCollated(entries []Entry, c Collator) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return c.CompareString(entries[i].Key, entries[j].Key) < 0
+	})
+}
+
+// CollatedEntries returns entries whose key starts with prefix, ordered by
+// collator c instead of bytes. Collation order is not compatible with the
+// trie, so all entries with prefix are collected and sorted
+func (t *Lexicon) CollatedEntries(prefix string, c Collator) []Entry {
+	entries := []Entry{}
+	t.walkPrefix(prefix, func(key string, value int32) bool {
+		entries = append(entries, Entry{key, value})
+		return true
+	})
+	sortCollated(entries, c)
+	return entries
+}
+
+// CollatedRange returns entries whose key is between lo and hi inclusively
+// by collator c, ordered by c. It walks all entries of Lexicon
+func (t *Lexicon) CollatedRange(lo, hi string, c Collator) []Entry {
+	entries := []Entry{}
+	t.walkPrefix("", func(key string, value int32) bool {
+		if c.CompareString(key, lo) >= 0 && c.CompareString(key, hi) <= 0 {
+			entries = append(entries, Entry{key, value})
+		}
+		return true
+	})
+	sortCollated(entries, c)
+	return entries
+}
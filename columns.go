@@ -0,0 +1,276 @@
+package lexicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// columnTable stores the named int32 columns of keys as parallel arrays. The
+// value of a key in Lexicon is its row index in columns
+type columnTable struct {
+	names  []string
+	values [][]int32
+}
+
+// Row is the values of a key in all columns of Lexicon, which are int32 in
+// Lexicon built by BuildColumns, or typed by schema in Lexicon built by
+// BuildSchema or BuildRows
+type Row struct {
+	table *columnTable
+	rows  *rowTable
+	row   int32
+}
+
+// BuildColumns builds the reimu-trie from dict where each key carries one
+// int32 for each column in 'columns', e.g. POS tag and frequency. Rows could
+// be got by GetRow. The value of a key got by Get is its row index
+func BuildColumns(
+	columns []string,
+	dict map[string][]int32,
+	progress func(int, int),
+	opts ...Option) (*Lexicon, error) {
+	table := &columnTable{
+		names:  append([]string{}, columns...),
+		values: make([][]int32, len(columns)),
+	}
+	for i := range table.values {
+		table.values[i] = make([]int32, 0, len(dict))
+	}
+
+	// Rows are ordered by key, so the result is the same for the same dict
+	keys := make([]string, 0, len(dict))
+	for key := range dict {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rowIndex := make(map[string]int32, len(dict))
+	for _, key := range keys {
+		row := dict[key]
+		if len(row) != len(columns) {
+			return nil, &KeyError{key, fmt.Errorf(
+				"%w: expect %d but got %d",
+				ErrColumnCount,
+				len(columns),
+				len(row))}
+		}
+
+		rowIndex[key] = int32(len(rowIndex))
+		for i, value := range row {
+			table.values[i] = append(table.values[i], value)
+		}
+	}
+
+	t, err := Build(rowIndex, progress, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t.columns = table
+	return t, nil
+}
+
+// Columns returns the names of columns in Lexicon, or nil if it is not built
+// by BuildColumns, BuildSchema or BuildRows
+func (t *Lexicon) Columns() []string {
+	schema := t.Schema()
+	if schema == nil {
+		return nil
+	}
+
+	names := make([]string, len(schema))
+	for i, column := range schema {
+		names[i] = column.Name
+	}
+	return names
+}
+
+// GetRow gets the row of key in Lexicon built by BuildColumns, BuildSchema or
+// BuildRows. On success, returns (row, true)
+func (t *Lexicon) GetRow(key string) (Row, bool) {
+	if t.columns == nil && t.rows == nil {
+		return Row{}, false
+	}
+
+	row, ok := t.Get(key)
+	if !ok {
+		return Row{}, false
+	}
+	return Row{t.columns, t.rows, row}, true
+}
+
+// index returns the index of column by name, or -1 if no such column
+func (r Row) index(name string) int {
+	if r.rows != nil {
+		for i, column := range r.rows.schema {
+			if column.Name == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for i, n := range r.table.names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Len returns the number of columns in row
+func (r Row) Len() int {
+	if r.rows != nil {
+		return len(r.rows.schema)
+	}
+	return len(r.table.names)
+}
+
+// Column returns the value of i-th column in row. Enum columns are the
+// indexes of values, and string columns are parsed, or 0 if they are not
+// integers
+func (r Row) Column(i int) int32 {
+	if r.rows != nil {
+		value, _ := r.rows.int32(r.row, i)
+		return value
+	}
+	return r.table.values[i][r.row]
+}
+
+// Field returns the value of i-th column in row as string
+func (r Row) Field(i int) string {
+	if r.rows != nil {
+		return r.rows.string(r.row, i)
+	}
+	return strconv.Itoa(int(r.table.values[i][r.row]))
+}
+
+// Int returns the value of column by name. Returns ok = false if no such
+// column, or the column is not an integer
+func (r Row) Int(name string) (value int32, ok bool) {
+	i := r.index(name)
+	if i < 0 {
+		return 0, false
+	}
+	if r.rows != nil {
+		return r.rows.int32(r.row, i)
+	}
+	return r.Column(i), true
+}
+
+// Float returns the value of column by name as float32. Returns ok = false if
+// no such column, or the column is not a number
+func (r Row) Float(name string) (value float32, ok bool) {
+	i := r.index(name)
+	if i < 0 {
+		return 0, false
+	}
+	if r.rows != nil {
+		return r.rows.float32(r.row, i)
+	}
+	return float32(r.Column(i)), true
+}
+
+// String returns the value of column by name as string. Returns ok = false if
+// no such column
+func (r Row) String(name string) (value string, ok bool) {
+	i := r.index(name)
+	if i < 0 {
+		return "", false
+	}
+	return r.Field(i), true
+}
+
+// clone returns a deep copy of column table
+func (c *columnTable) clone() *columnTable {
+	values := make([][]int32, len(c.values))
+	for i := range c.values {
+		values[i] = append([]int32{}, c.values[i]...)
+	}
+	return &columnTable{
+		names:  append([]string{}, c.names...),
+		values: values,
+	}
+}
+
+// marshal encodes the column table into bytes
+func (c *columnTable) marshal() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var err error
+	binaryWrite := func(data interface{}) {
+		if err == nil {
+			err = binary.Write(buf, binary.LittleEndian, data)
+		}
+	}
+
+	numRows := 0
+	if len(c.values) > 0 {
+		numRows = len(c.values[0])
+	}
+
+	// Number of columns, number of rows, names and then values of columns
+	binaryWrite(int32(len(c.names)))
+	binaryWrite(int32(numRows))
+	for _, name := range c.names {
+		binaryWrite(int32(len(name)))
+		binaryWrite([]byte(name))
+	}
+	for _, column := range c.values {
+		binaryWrite(column)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readColumnTable reads the column table from payload of its section
+func readColumnTable(payload []byte) (*columnTable, error) {
+	r := bytes.NewReader(payload)
+	var err error
+	binaryRead := func(data interface{}) {
+		if err == nil {
+			err = binary.Read(r, binary.LittleEndian, data)
+		}
+	}
+
+	var numColumns, numRows int32
+	binaryRead(&numColumns)
+	binaryRead(&numRows)
+	// Each column has at least the length of its name
+	if err == nil && (numColumns < 0 || numRows < 0 ||
+		int(numColumns) > r.Len()/4 ||
+		int64(numColumns)*int64(numRows)*4 > int64(r.Len())) {
+		return nil, ErrCorrupted
+	}
+
+	c := &columnTable{
+		names:  make([]string, numColumns),
+		values: make([][]int32, numColumns),
+	}
+	for i := range c.names {
+		var length int32
+		binaryRead(&length)
+		if err == nil && (length < 0 || int(length) > r.Len()) {
+			return nil, ErrCorrupted
+		}
+		if err == nil {
+			name := make([]byte, length)
+			binaryRead(&name)
+			c.names[i] = string(name)
+		}
+	}
+	for i := range c.values {
+		c.values[i] = make([]int32, numRows)
+		binaryRead(&c.values[i])
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
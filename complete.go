@@ -0,0 +1,177 @@
+package lexicon
+
+import (
+	"sort"
+)
+
+// Entry is a key-value pair in Lexicon
+type Entry struct {
+	Key   string
+	Value int32
+}
+
+// fuzzyEntry is an entry found by fuzzy search together with its edit
+// distance to the query
+type fuzzyEntry struct {
+	Entry
+	distance int
+}
+
+// Complete returns all entries whose key starts with a string within
+// 'maxEdits' edits (Levenshtein distance) of 'prefix'. With maxEdits = 0 it
+// is the exact prefix completion. Entries are ordered by edit distance, then
+// by key
+func (t *Lexicon) Complete(prefix string, maxEdits int) []Entry {
+	if t.metrics != nil {
+		t.metrics.prefixSearches.Add(1)
+	}
+
+	entries := []Entry{}
+	if maxEdits <= 0 {
+		t.walkPrefix(prefix, func(key string, value int32) bool {
+			entries = append(entries, Entry{key, value})
+			return true
+		})
+		return entries
+	}
+
+	fuzzyEntries := []fuzzyEntry{}
+	t.fuzzyComplete(prefix, maxEdits, func(e fuzzyEntry) bool {
+		fuzzyEntries = append(fuzzyEntries, e)
+		return true
+	})
+	sort.SliceStable(fuzzyEntries, func(i, j int) bool {
+		return fuzzyEntries[i].distance < fuzzyEntries[j].distance
+	})
+	for _, e := range fuzzyEntries {
+		entries = append(entries, e.Entry)
+	}
+
+	return entries
+}
+
+// CompleteFiltered returns up to 'limit' entries whose key starts with
+// prefix and passes filter, in lexicographical order. filter is called during
+// the walk, e.g. to keep values in a range, and the walk stops once limit
+// entries are found. So it doesn't fetch all completions to filter them. No
+// limit if it is 0 or negative
+func (t *Lexicon) CompleteFiltered(
+	prefix string,
+	limit int,
+	filter func(key string, value int32) bool) []Entry {
+	entries := []Entry{}
+	t.WalkPrefix(prefix, func(key string, value int32) bool {
+		if filter(key, value) {
+			entries = append(entries, Entry{key, value})
+		}
+		return limit <= 0 || len(entries) < limit
+	})
+	return entries
+}
+
+// WalkPrefix calls fn for each entry whose key starts with prefix, in
+// lexicographical order. Returning false from fn stops the walk, so that
+// e.g. finding the first 10 completions doesn't pay for enumerating the whole
+// subtree
+func (t *Lexicon) WalkPrefix(prefix string, fn func(key string, value int32) bool) {
+	if t.metrics != nil {
+		t.metrics.prefixSearches.Add(1)
+	}
+	t.walkPrefix(prefix, fn)
+}
+
+// walkPrefix is WalkPrefix without counting in metrics, for internal use
+func (t *Lexicon) walkPrefix(prefix string, fn func(key string, value int32) bool) {
+	s := InitialState()
+	t.Traverse(prefix, &s)
+	if !s.Valid() {
+		return
+	}
+
+	t.walk(&s, []byte(prefix), func(key []byte, value int32) bool {
+		return fn(string(key), value)
+	})
+}
+
+// fuzzyComplete calls fn for each entry whose key starts with a string within
+// 'maxEdits' edits of 'prefix', in lexicographical order. The distance of an
+// entry is the minimal edit distance between 'prefix' and any prefix of its
+// key. Stops once fn returns false
+func (t *Lexicon) fuzzyComplete(
+	prefix string,
+	maxEdits int,
+	fn func(e fuzzyEntry) bool) {
+	// row[i] is the edit distance between prefix[:i] and the bytes consumed
+	row := make([]int, len(prefix)+1)
+	for i := range row {
+		row[i] = i
+	}
+
+	s := InitialState()
+	best := row[len(prefix)]
+	t.fuzzyWalk(&s, []byte{}, prefix, row, best, maxEdits, fn)
+}
+
+// fuzzyWalk is the recursive part of fuzzyComplete. 'best' is the minimal
+// distance between prefix and the prefixes of 'key'
+func (t *Lexicon) fuzzyWalk(
+	s *State,
+	key []byte,
+	prefix string,
+	row []int,
+	best int,
+	maxEdits int,
+	fn func(e fuzzyEntry) bool) bool {
+	if best <= maxEdits {
+		if value, ok := t.value(s); ok && s.state != 0 {
+			if !fn(fuzzyEntry{Entry{string(key), value}, best}) {
+				return false
+			}
+		}
+	}
+
+	minDistance := row[0]
+	for _, d := range row {
+		if d < minDistance {
+			minDistance = d
+		}
+	}
+	if minDistance > maxEdits {
+		if best > maxEdits {
+			// Neither this node nor its descendants could match the prefix
+			return true
+		}
+
+		// Prefix already matched, all keys in the subtree are completions
+		return t.children(s, func(b byte, child State) bool {
+			return t.walk(&child, append(key, b), func(k []byte, v int32) bool {
+				return fn(fuzzyEntry{Entry{string(k), v}, best})
+			})
+		})
+	}
+
+	return t.children(s, func(b byte, child State) bool {
+		nextRow := make([]int, len(row))
+		nextRow[0] = row[0] + 1
+		for i := 1; i < len(row); i++ {
+			cost := 1
+			if prefix[i-1] == b {
+				cost = 0
+			}
+			nextRow[i] = min3(row[i]+1, nextRow[i-1]+1, row[i-1]+cost)
+		}
+
+		nextBest := best
+		if nextRow[len(prefix)] < nextBest {
+			nextBest = nextRow[len(prefix)]
+		}
+		return t.fuzzyWalk(
+			&child,
+			append(key, b),
+			prefix,
+			nextRow,
+			nextBest,
+			maxEdits,
+			fn)
+	})
+}
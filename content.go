@@ -0,0 +1,64 @@
+package lexicon
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// entries returns all keys and values in Lexicon, in the order of keys
+func (t *Lexicon) entries() []Entry {
+	entries := []Entry{}
+	t.walkPrefix("", func(key string, value int32) bool {
+		entries = append(entries, Entry{key, value})
+		return true
+	})
+	return entries
+}
+
+// Equal returns true if t and other have the same keys and values. Only the
+// contents are compared, lexicons built from the same dict with different
+// options (e.g. block size) are equal even if their slots differ
+func (t *Lexicon) Equal(other *Lexicon) bool {
+	if t == other {
+		return true
+	}
+	if t == nil || other == nil {
+		return false
+	}
+
+	entries := other.entries()
+	i := 0
+	equal := true
+	t.walkPrefix("", func(key string, value int32) bool {
+		if i >= len(entries) || entries[i].Key != key || entries[i].Value != value {
+			equal = false
+			return false
+		}
+		i++
+		return true
+	})
+
+	return equal && i == len(entries)
+}
+
+// ContentHash returns the SHA-256 hash of keys and values in Lexicon. Like
+// Equal, it depends only on the contents, so it could be used as cache key or
+// to detect whether a rebuilt lexicon has changed
+func (t *Lexicon) ContentHash() [sha256.Size]byte {
+	h := sha256.New()
+	buf := make([]byte, 4)
+	t.walkPrefix("", func(key string, value int32) bool {
+		// Length of key, key and then value, all lengths and values are little
+		// endian
+		binary.LittleEndian.PutUint32(buf, uint32(len(key)))
+		h.Write(buf)
+		h.Write([]byte(key))
+		binary.LittleEndian.PutUint32(buf, uint32(value))
+		h.Write(buf)
+		return true
+	})
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
@@ -0,0 +1,134 @@
+package lexicon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+// DartsClone is a double array in the file format of darts-clone, the
+// compacted double array of C++ library. Each unit is an uint32 of label,
+// offset to children, has-leaf bit, or value for leaf units
+type DartsClone struct {
+	units []uint32
+}
+
+// ReadDartsClone reads the double array file saved by darts-clone
+func ReadDartsClone(filename string) (*DartsClone, error) {
+	fd, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	info, err := fd.Stat()
+	if err != nil {
+		return nil, err
+	}
+	d, err := readDartsClone(bufio.NewReader(fd), info.Size())
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// readDartsClone reads darts-clone units of 'size' bytes from r
+func readDartsClone(r io.Reader, size int64) (*DartsClone, error) {
+	if size == 0 || size%4 != 0 {
+		return nil, ErrCorrupted
+	}
+
+	d := &DartsClone{units: make([]uint32, size/4)}
+	if err := binary.Read(r, binary.LittleEndian, d.units); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// dartsHasLeaf returns true if a key ends at the node of unit
+func dartsHasLeaf(unit uint32) bool {
+	return (unit>>8)&1 == 1
+}
+
+// dartsValue returns the value of leaf unit
+func dartsValue(unit uint32) int32 {
+	return int32(unit & (1<<31 - 1))
+}
+
+// dartsLabel returns the label of unit, leaf units have the highest bit set
+// so they never match a byte
+func dartsLabel(unit uint32) uint32 {
+	return unit & (1<<31 | 0xff)
+}
+
+// dartsOffset returns the offset from the node of unit to its children
+func dartsOffset(unit uint32) uint32 {
+	return (unit >> 10) << ((unit & (1 << 9)) >> 6)
+}
+
+// child returns the child of node at pos by byte b
+func (d *DartsClone) child(pos uint32, b byte) (uint32, bool) {
+	next := pos ^ dartsOffset(d.units[pos]) ^ uint32(b)
+	if int(next) >= len(d.units) || dartsLabel(d.units[next]) != uint32(b) {
+		return 0, false
+	}
+	return next, true
+}
+
+// value returns the value of key ending at node pos
+func (d *DartsClone) value(pos uint32) (int32, bool) {
+	if !dartsHasLeaf(d.units[pos]) {
+		return -1, false
+	}
+	leaf := pos ^ dartsOffset(d.units[pos])
+	if int(leaf) >= len(d.units) {
+		return -1, false
+	}
+	return dartsValue(d.units[leaf]), true
+}
+
+// Get gets the value by key. On success, returns (value, true)
+func (d *DartsClone) Get(key string) (value int32, ok bool) {
+	pos := uint32(0)
+	for i := 0; i < len(key); i++ {
+		if pos, ok = d.child(pos, key[i]); !ok {
+			return -1, false
+		}
+	}
+	return d.value(pos)
+}
+
+// Walk calls fn for each key and value in the double array, in the order of
+// keys. Stops once fn returns false
+func (d *DartsClone) Walk(fn func(key string, value int32) bool) {
+	d.walk(0, []byte{}, fn)
+}
+
+// walk visits the keys under node pos, returns false if stopped by fn
+func (d *DartsClone) walk(pos uint32, key []byte, fn func(key string, value int32) bool) bool {
+	if value, ok := d.value(pos); ok && len(key) > 0 {
+		if !fn(string(key), value) {
+			return false
+		}
+	}
+	for b := 1; b < 256; b++ {
+		if next, ok := d.child(pos, byte(b)); ok {
+			if !d.walk(next, append(key, byte(b)), fn) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ToLexicon builds the reimu-trie with the keys and values in darts-clone
+// double array
+func (d *DartsClone) ToLexicon(progress func(int, int), opts ...Option) (*Lexicon, error) {
+	dict := map[string]int32{}
+	d.Walk(func(key string, value int32) bool {
+		dict[key] = value
+		return true
+	})
+	return Build(dict, progress, opts...)
+}
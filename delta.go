@@ -0,0 +1,206 @@
+package lexicon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+)
+
+// A delta file records the changes to a base lexicon file. Open merges
+// filename + DeltaSuffix over the base automatically. It is the header and
+// then records appended one by one, each record is: op (1 byte), length of
+// key (int32), key, value (int32) and CRC-32 of the preceding bytes in
+// record (uint32)
+const DeltaSuffix = ".delta"
+const deltaHeader = "REIMU_Delta.v1"
+
+// Ops of delta records
+const (
+	deltaSet    byte = 'S'
+	deltaDelete byte = 'D'
+)
+
+// DeltaWriter appends changes to a delta file
+type DeltaWriter struct {
+	fd *os.File
+	w  *bufio.Writer
+}
+
+// OpenDeltaWriter opens the delta file for appending changes, and creates it
+// if not exists. A torn record at the end (e.g. of a crash in writing) is
+// truncated, so records appended after it are still readable
+func OpenDeltaWriter(filename string) (*DeltaWriter, error) {
+	fd, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := fd.Stat()
+	if err == nil && info.Size() == 0 {
+		_, err = fd.Write([]byte(deltaHeader))
+	} else if err == nil {
+		err = checkDeltaHeader(io.NewSectionReader(fd, 0, info.Size()))
+		var valid int64
+		if err == nil {
+			size := info.Size() - int64(len(deltaHeader))
+			r := io.NewSectionReader(fd, int64(len(deltaHeader)), size)
+			valid, err = readDeltaRecords(r, size, func(byte, string, int32) error { return nil })
+		}
+		if err == ErrCorrupted {
+			err = fd.Truncate(int64(len(deltaHeader)) + valid)
+		}
+	}
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	return &DeltaWriter{fd, bufio.NewWriter(fd)}, nil
+}
+
+// Set records that key is added or changed to value
+func (dw *DeltaWriter) Set(key string, value int32) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	return dw.write(deltaSet, key, value)
+}
+
+// Delete records that key is deleted
+func (dw *DeltaWriter) Delete(key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	return dw.write(deltaDelete, key, 0)
+}
+
+// write writes a record
+func (dw *DeltaWriter) write(op byte, key string, value int32) error {
+	record := make([]byte, 13+len(key))
+	record[0] = op
+	binary.LittleEndian.PutUint32(record[1:], uint32(len(key)))
+	copy(record[5:], key)
+	binary.LittleEndian.PutUint32(record[5+len(key):], uint32(value))
+	n := len(record) - 4
+	binary.LittleEndian.PutUint32(record[n:], crc32.ChecksumIEEE(record[:n]))
+
+	_, err := dw.w.Write(record)
+	return err
+}
+
+// Flush writes the buffered records to file
+func (dw *DeltaWriter) Flush() error {
+	return dw.w.Flush()
+}
+
+// Close flushes the records and closes the file
+func (dw *DeltaWriter) Close() error {
+	err := dw.w.Flush()
+	if closeErr := dw.fd.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// checkDeltaHeader reads and checks the header of delta file
+func checkDeltaHeader(r io.Reader) error {
+	header := make([]byte, len(deltaHeader))
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorruptHeader, err)
+	}
+	if string(header) != deltaHeader {
+		return ErrCorruptHeader
+	}
+	return nil
+}
+
+// readDeltaRecords reads records after header from r, which has 'size' bytes
+// left, and calls fn for each of them. Returns the number of bytes of valid
+// records. A truncated record or a record with wrong checksum returns
+// ErrCorrupted. Stops at the first error of fn and returns it
+func readDeltaRecords(
+	r io.Reader,
+	size int64,
+	fn func(op byte, key string, value int32) error) (int64, error) {
+	br := bufio.NewReader(r)
+	valid := int64(0)
+	for {
+		head := make([]byte, 5)
+		if _, err := io.ReadFull(br, head); err == io.EOF {
+			return valid, nil
+		} else if err != nil {
+			return valid, ErrCorrupted
+		}
+
+		length := binary.LittleEndian.Uint32(head[1:])
+		// The record should be within the file, so a corrupted length doesn't
+		// allocate more than the file size
+		if (head[0] != deltaSet && head[0] != deltaDelete) || int64(length)+13 > size-valid {
+			return valid, ErrCorrupted
+		}
+		record := make([]byte, 5+int(length)+8)
+		copy(record, head)
+		if _, err := io.ReadFull(br, record[5:]); err != nil {
+			return valid, ErrCorrupted
+		}
+		n := len(record) - 4
+		if crc32.ChecksumIEEE(record[:n]) != binary.LittleEndian.Uint32(record[n:]) {
+			return valid, ErrCorrupted
+		}
+
+		key := string(record[5 : 5+length])
+		if err := fn(record[0], key, int32(binary.LittleEndian.Uint32(record[5+length:]))); err != nil {
+			return valid, err
+		}
+		valid += int64(len(record))
+	}
+}
+
+// ApplyDelta applies the changes in delta file to overlay, in order. A torn
+// record at the end (e.g. of a crash in writing) and the records after it
+// are ignored, like in EnableWAL
+func (o *Overlay) ApplyDelta(filename string) error {
+	fd, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	info, err := fd.Stat()
+	if err != nil {
+		return err
+	}
+	if err = checkDeltaHeader(fd); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	size := info.Size() - int64(len(deltaHeader))
+	_, err = readDeltaRecords(fd, size, func(op byte, key string, value int32) error {
+		if op == deltaSet {
+			return o.Set(key, value)
+		}
+		return o.Delete(key)
+	})
+	if err != nil && err != ErrCorrupted {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
+
+// Open reads the lexicon file as the base of an Overlay, and applies the
+// delta file filename + DeltaSuffix over it if exists
+func Open(filename string, opts ...ReadOption) (*Overlay, error) {
+	t, err := Read(filename, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	o := NewOverlay(t)
+	err = o.ApplyDelta(filename + DeltaSuffix)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return o, nil
+}
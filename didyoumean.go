@@ -0,0 +1,69 @@
+package lexicon
+
+import (
+	"sort"
+)
+
+// autoEdits returns the max edits of fuzzy completion in DidYouMean by the
+// length of query, since short queries are close to too many keys
+func autoEdits(query string) int {
+	switch {
+	case len(query) <= 2:
+		return 0
+	case len(query) <= 5:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// DidYouMean returns up to n entries for a search box query. The key equal
+// to query comes first, then the keys starting with query by value
+// descending. If they are fewer than n, the keys starting with a string
+// within a few edits of query are added, ranked by edit distance, then by
+// value descending. The max edits are 0 for queries up to 2 bytes, 1 up to 5
+// bytes, and 2 for longer ones
+func (t *Lexicon) DidYouMean(query string, n int) []Entry {
+	entries := []Entry{}
+	if n <= 0 {
+		return entries
+	}
+	found := map[string]bool{}
+	add := func(e Entry) {
+		if len(entries) < n && !found[e.Key] {
+			found[e.Key] = true
+			entries = append(entries, e)
+		}
+	}
+
+	s := InitialState()
+	if value, ok := t.Traverse(query, &s); ok && query != "" {
+		add(Entry{query, value})
+	}
+	for _, e := range t.topKPrefix(query, n) {
+		add(e)
+	}
+
+	maxEdits := autoEdits(query)
+	if len(entries) == n || maxEdits == 0 {
+		return entries
+	}
+	candidates := []fuzzyEntry{}
+	t.fuzzyComplete(query, maxEdits, func(e fuzzyEntry) bool {
+		if !found[e.Key] {
+			candidates = append(candidates, e)
+		}
+		return true
+	})
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return rankedBefore(candidates[i].Entry, candidates[j].Entry)
+	})
+	for _, e := range candidates {
+		add(e.Entry)
+	}
+
+	return entries
+}
@@ -0,0 +1,238 @@
+package lexicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// DiskLexicon looks up a lexicon file through io.ReaderAt without loading
+// it into memory. Each lookup reads the slots and suffix it visits, so it is
+// suitable for huge files on disk or remote storage (see HTTPReaderAt), with
+// a CachedReaderAt in front to avoid reading the same blocks again. The
+// checksum of file is not verified, since it needs the whole file
+type DiskLexicon struct {
+	r io.ReaderAt
+
+	flags          uint32
+	numSlots       int32
+	numSuffix      int32
+	numSuffixBytes int64
+	transforms     []Transform
+
+	// Size of each suffixIndex in file, 4 or 8 if flagSuffix64
+	suffixIndexSize int64
+
+	// Offsets of arrays in file
+	slotsOffset       int64
+	suffixIndexOffset int64
+	suffixValueOffset int64
+	suffixOffset      int64
+}
+
+// OpenDisk opens the lexicon file of 'size' bytes in r
+func OpenDisk(r io.ReaderAt, size int64) (*DiskLexicon, error) {
+	d := &DiskLexicon{r: r}
+
+	header := make([]byte, len(Header))
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	offset := int64(len(Header))
+	switch string(header) {
+	case Header:
+		flags, err := d.readInt32(offset)
+		if err != nil {
+			return nil, err
+		}
+		d.flags = uint32(flags)
+		offset += 4
+		if d.flags&^knownFlags != 0 {
+			return nil, ErrUnsupportedVersion
+		}
+		if d.flags&encodingFlags != 0 {
+			return nil, fmt.Errorf("%w: encoded arrays need Read", ErrUnsupportedVersion)
+		}
+	case headerV1:
+	default:
+		if bytes.HasPrefix(header, []byte(headerPrefix)) {
+			return nil, ErrUnsupportedVersion
+		}
+		return nil, ErrCorruptHeader
+	}
+
+	counts := make([]int32, 3)
+	err := binary.Read(io.NewSectionReader(r, offset, 12), binary.LittleEndian, counts)
+	if err != nil {
+		return nil, err
+	}
+	d.numSlots, d.numSuffix, d.numSuffixBytes = counts[0], counts[1], int64(counts[2])
+	d.slotsOffset = offset + 12
+	d.suffixIndexSize = 4
+	if d.flags&flagSuffix64 != 0 {
+		// Number of suffix bytes is int64 as well
+		d.numSuffixBytes, err = d.readInt64(offset + 8)
+		if err != nil {
+			return nil, err
+		}
+		d.slotsOffset = offset + 16
+		d.suffixIndexSize = 8
+	}
+	if d.numSlots < 1 || d.numSuffix < 0 || d.numSuffixBytes < 0 {
+		return nil, ErrCorrupted
+	}
+
+	d.suffixIndexOffset = d.slotsOffset + int64(d.numSlots)*8
+	d.suffixValueOffset = d.suffixIndexOffset + int64(d.numSuffix)*d.suffixIndexSize
+	d.suffixOffset = d.suffixValueOffset + int64(d.numSuffix)*4
+	if d.flags&flagSet != 0 {
+		// No suffixValue in sets
+		d.suffixOffset = d.suffixValueOffset
+	}
+	offset = d.suffixOffset + d.numSuffixBytes
+	if offset > size {
+		return nil, ErrCorrupted
+	}
+
+	// Sections, only transforms are needed in lookup
+	for offset+sectionTagSize+4 <= size {
+		tag := make([]byte, sectionTagSize)
+		if _, err = r.ReadAt(tag, offset); err != nil {
+			return nil, err
+		}
+		length, err := d.readInt32(offset + sectionTagSize)
+		if err != nil {
+			return nil, err
+		}
+		offset += sectionTagSize + 4
+		if length < 0 || offset+int64(length) > size {
+			return nil, ErrCorrupted
+		}
+
+		if string(tag) == sectionTransforms {
+			payload := make([]byte, length)
+			if _, err = r.ReadAt(payload, offset); err != nil {
+				return nil, err
+			}
+			if d.transforms, err = readTransforms(payload); err != nil {
+				return nil, err
+			}
+		}
+		offset += int64(length)
+	}
+
+	return d, nil
+}
+
+// readInt32 reads an int32 at offset
+func (d *DiskLexicon) readInt32(offset int64) (int32, error) {
+	buf := make([]byte, 4)
+	if _, err := d.r.ReadAt(buf, offset); err != nil {
+		return 0, err
+	}
+	return int32(binary.LittleEndian.Uint32(buf)), nil
+}
+
+// readInt64 reads an int64 at offset
+func (d *DiskLexicon) readInt64(offset int64) (int64, error) {
+	buf := make([]byte, 8)
+	if _, err := d.r.ReadAt(buf, offset); err != nil {
+		return 0, err
+	}
+	return int64(binary.LittleEndian.Uint64(buf)), nil
+}
+
+// slot reads the i-th slot, i should be less than numSlots
+func (d *DiskLexicon) slot(i int32) (slotT, error) {
+	buf := make([]byte, 8)
+	if _, err := d.r.ReadAt(buf, d.slotsOffset+int64(i)*8); err != nil {
+		return slotT{}, err
+	}
+	return slotT{
+		Base:  int32(binary.LittleEndian.Uint32(buf)),
+		Check: int32(binary.LittleEndian.Uint32(buf[4:])),
+	}, nil
+}
+
+// Get gets the value by key. On success, returns (value, true, nil). err is
+// the error of reading the underlying reader
+func (d *DiskLexicon) Get(key string) (value int32, ok bool, err error) {
+	key = applyTransforms(key, d.transforms)
+	if err = checkKey(key); err != nil {
+		return -1, false, nil
+	}
+
+	state := int32(0)
+	for i := 0; i < len(key); i++ {
+		current, err := d.slot(state)
+		if err != nil {
+			return -1, false, err
+		}
+		if current.Base < 0 {
+			return d.getSuffix(-current.Base-1, key[i:])
+		}
+
+		nextState := current.Base ^ int32(key[i])
+		if nextState >= d.numSlots {
+			return -1, false, nil
+		}
+		next, err := d.slot(nextState)
+		if err != nil || next.Check != state {
+			return -1, false, err
+		}
+		state = nextState
+	}
+
+	current, err := d.slot(state)
+	if err != nil || current.Base < 0 || current.Base >= d.numSlots {
+		return -1, false, err
+	}
+	valueSlot, err := d.slot(current.Base)
+	if err != nil || valueSlot.Check != state {
+		return -1, false, err
+	}
+	return valueSlot.Base, true, nil
+}
+
+// getSuffix returns the value of suffix 'suffixId' if it equals to 'rest'
+func (d *DiskLexicon) getSuffix(suffixId int32, rest string) (int32, bool, error) {
+	if suffixId >= d.numSuffix {
+		return -1, false, ErrCorrupted
+	}
+	offset := d.suffixIndexOffset + int64(suffixId)*d.suffixIndexSize
+	var begin int64
+	var err error
+	if d.suffixIndexSize == 8 {
+		begin, err = d.readInt64(offset)
+	} else {
+		var begin32 int32
+		begin32, err = d.readInt32(offset)
+		begin = int64(begin32)
+	}
+	if err != nil {
+		return -1, false, err
+	}
+
+	// The suffix equals to rest if it is followed by '\x00'
+	end := begin + int64(len(rest)) + 1
+	if begin < 0 || end > d.numSuffixBytes {
+		return -1, false, nil
+	}
+	suffix := make([]byte, len(rest)+1)
+	if _, err = d.r.ReadAt(suffix, d.suffixOffset+begin); err != nil {
+		return -1, false, err
+	}
+	if string(suffix[:len(rest)]) != rest || suffix[len(rest)] != '\x00' {
+		return -1, false, nil
+	}
+
+	if d.flags&flagSet != 0 {
+		return 0, true, nil
+	}
+	value, err := d.readInt32(d.suffixValueOffset + int64(suffixId)*4)
+	if err != nil {
+		return -1, false, err
+	}
+	return value, true, nil
+}
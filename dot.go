@@ -0,0 +1,120 @@
+package lexicon
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// dotWriter writes GraphViz DOT statements and keeps the first error
+type dotWriter struct {
+	w   io.Writer
+	err error
+}
+
+// printf writes a formatted line into DOT output
+func (d *dotWriter) printf(format string, args ...interface{}) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format+"\n", args...)
+	}
+}
+
+// dotLabel quotes bytes as a DOT label, non-printable bytes are escaped
+func dotLabel(b []byte) string {
+	// strconv.Quote escapes the non-printable bytes and also the quotes, the
+	// escaped backslashes are valid in DOT as well
+	return strconv.Quote(string(b))
+}
+
+// ExportDOT writes the structure of double array in GraphViz DOT format,
+// for visualizing small lexicons in debugging. Nodes are named by their slot
+// index, nodes with value are double circles and suffixes are boxes. Only
+// nodes within maxDepth bytes from root are written, maxDepth <= 0 means no
+// limit
+func (t *Lexicon) ExportDOT(w io.Writer, maxDepth int) error {
+	d := &dotWriter{w: w}
+	d.printf("digraph lexicon {")
+	d.printf("  node [shape=circle];")
+	t.exportDOTNode(d, 0, 0, maxDepth)
+	d.printf("}")
+
+	return d.err
+}
+
+// exportDOTNode writes the node of state and its descendants
+func (t *Lexicon) exportDOTNode(d *dotWriter, state int32, depth, maxDepth int) {
+	s := State{state: state, suffixId: -1, suffixPtr: -1}
+	if value, ok := t.value(&s); ok && state != 0 {
+		d.printf("  s%d [shape=doublecircle, label=\"%d\\n=%d\"];", state, state, value)
+	} else {
+		d.printf("  s%d [label=\"%d\"];", state, state)
+	}
+	if maxDepth > 0 && depth >= maxDepth {
+		return
+	}
+
+	base := t.slots[state].Base
+	if base < 0 {
+		suffixId := -base - 1
+		suffix := []byte{}
+		for p := t.suffixIndex[suffixId]; t.suffix[p] != '\x00'; p++ {
+			suffix = append(suffix, t.suffix[p])
+		}
+		d.printf(
+			"  x%d [shape=box, label=%s];",
+			suffixId,
+			dotLabel([]byte(fmt.Sprintf("%s\n=%d", suffix, t.suffixValue[suffixId]))))
+		d.printf("  s%d -> x%d [style=dashed];", state, suffixId)
+		return
+	}
+
+	t.children(&s, func(b byte, child State) bool {
+		d.printf("  s%d -> s%d [label=%s];", state, child.state, dotLabel([]byte{b}))
+		t.exportDOTNode(d, child.state, depth+1, maxDepth)
+		return true
+	})
+}
+
+// ExportTrieDOT writes the intermediate trie built from dict, before
+// converting to double array, in GraphViz DOT format. It shows how keys are
+// split into nodes and suffixes. maxDepth is the same as Lexicon.ExportDOT
+func ExportTrieDOT(w io.Writer, dict map[string]int32, maxDepth int) error {
+	trie, err := buildTrie(dict, nil)
+	if err != nil {
+		return err
+	}
+
+	d := &dotWriter{w: w}
+	d.printf("digraph trie {")
+	d.printf("  node [shape=circle];")
+	id := 0
+	trie.exportDOT(d, &id, 0, maxDepth)
+	d.printf("}")
+
+	return d.err
+}
+
+// exportDOT writes the trie node and its descendants. id is the counter to
+// name nodes
+func (t *_Trie) exportDOT(d *dotWriter, id *int, depth, maxDepth int) {
+	nodeId := *id
+	*id++
+
+	if t.hasSuffix {
+		label := fmt.Sprintf("%s\n=%d", t.suffix, t.value)
+		d.printf("  n%d [shape=box, label=%s];", nodeId, dotLabel([]byte(label)))
+		return
+	} else if t.hasValue {
+		d.printf("  n%d [shape=doublecircle, label=\"=%d\"];", nodeId, t.value)
+	} else {
+		d.printf("  n%d [label=\"\"];", nodeId)
+	}
+	if maxDepth > 0 && depth >= maxDepth {
+		return
+	}
+
+	for _, child := range t.children {
+		d.printf("  n%d -> n%d [label=%s];", nodeId, *id, dotLabel([]byte{child.label}))
+		child.node.exportDOT(d, id, depth+1, maxDepth)
+	}
+}
@@ -0,0 +1,92 @@
+package lexicon
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors of reading lexicon files, got by errors.Is from the error of Read
+var (
+	// ErrCorruptHeader means the file is not a lexicon file
+	ErrCorruptHeader = errors.New("lexicon: corrupt header")
+
+	// ErrUnsupportedVersion means the file is a lexicon file of unknown
+	// version, e.g. written by a newer version of this package
+	ErrUnsupportedVersion = errors.New("lexicon: unsupported file version")
+
+	// ErrCorrupted means the content of file is corrupted
+	ErrCorrupted = errors.New("lexicon: corrupted lexicon")
+
+	// ErrChecksum means the checksum of file mismatches its content
+	ErrChecksum = errors.New("lexicon: checksum mismatch")
+
+	// ErrMemoryLimit means reading the file needs more memory than the limit
+	// set by WithMemoryLimit
+	ErrMemoryLimit = errors.New("lexicon: memory limit exceeded")
+)
+
+// Errors of building lexicons
+var (
+	ErrEmptyKey         = errors.New("lexicon: unexpected empty key")
+	ErrKeyContainsNUL   = errors.New("lexicon: unexpected character '\\x00' in key")
+	ErrKeyTooLong       = errors.New("lexicon: key too long")
+	ErrInvalidUTF8      = errors.New("lexicon: invalid UTF-8 in key")
+	ErrInvalidBlockSize = errors.New("lexicon: invalid block size")
+	ErrColumnCount      = errors.New("lexicon: unexpected number of columns")
+	ErrColumnValue      = errors.New("lexicon: invalid column value")
+	ErrUnknownPhonetic  = errors.New("lexicon: unknown phonetic algorithm")
+	ErrUnknownTransform = errors.New("lexicon: unknown transform")
+
+	// ErrTooLarge means slots, suffixes or sections exceed the int32 limit of
+	// file format, got from Build or Save
+	ErrTooLarge = errors.New("lexicon: lexicon too large")
+)
+
+// Errors of getting typed columns by Int32Field, Float32Field, StringField
+// and EnumField
+var (
+	ErrUnknownColumn = errors.New("lexicon: unknown column")
+	ErrColumnType    = errors.New("lexicon: unexpected column type")
+)
+
+// KeyError is the error caused by a key, Err is one of ErrEmptyKey,
+// ErrKeyContainsNUL, ErrKeyTooLong, ErrInvalidUTF8, ErrColumnCount and
+// ErrColumnValue
+type KeyError struct {
+	Key string
+	Err error
+}
+
+// maxErrorKeyLength is the max length of key shown in KeyError.Error
+const maxErrorKeyLength = 64
+
+// Error implements the error interface. Long keys are cut to the first 64
+// bytes
+func (e *KeyError) Error() string {
+	if len(e.Key) > maxErrorKeyLength {
+		return fmt.Sprintf(
+			"%s: %q... (%d bytes)",
+			e.Err.Error(),
+			e.Key[:maxErrorKeyLength],
+			len(e.Key))
+	}
+	return fmt.Sprintf("%s: %q", e.Err.Error(), e.Key)
+}
+
+// Unwrap returns the underlying error, for errors.Is
+func (e *KeyError) Unwrap() error {
+	return e.Err
+}
+
+// checkKey returns KeyError if key is empty or contains NUL
+func checkKey(key string) error {
+	if key == "" {
+		return &KeyError{key, ErrEmptyKey}
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] == '\x00' {
+			return &KeyError{key, ErrKeyContainsNUL}
+		}
+	}
+	return nil
+}
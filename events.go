@@ -0,0 +1,121 @@
+package lexicon
+
+import (
+	"fmt"
+)
+
+// BuildEventKind is the kind of BuildEvent
+type BuildEventKind int
+
+const (
+	// A phase of Build started, its name is in BuildEvent.Phase
+	BuildPhaseStarted BuildEventKind = iota + 1
+
+	// A block of slots is allocated, its id is in BuildEvent.Block
+	BuildBlockAllocated
+
+	// BuildEvent.Nodes of BuildEvent.TotalNodes trie nodes are placed into
+	// the double array. Sent every ProgressStep nodes and once at the end
+	BuildNodesPlaced
+
+	// All suffixes are written, BuildEvent.SuffixBytes is their total length
+	BuildSuffixWritten
+
+	// The key set is known to make the double array large, which is
+	// described in BuildEvent.Warning. Sent before placing nodes
+	BuildWarning
+)
+
+// Phases of Build in BuildEvent.Phase
+const (
+	BuildPhaseTrie        = "trie"
+	BuildPhaseDoubleArray = "doubleArray"
+	BuildPhasePhonetic    = "phonetic"
+)
+
+// BuildEvent is an event in Build. Only the fields of its kind are set
+type BuildEvent struct {
+	Kind        BuildEventKind
+	Phase       string
+	Block       int
+	Nodes       int
+	TotalNodes  int
+	SuffixBytes int
+	Warning     string
+}
+
+// WithBuildEvents sends the events of Build to fn, which is called in the
+// goroutine of Build. It gives more details than the progress function, e.g.
+// for build dashboards
+func WithBuildEvents(fn func(BuildEvent)) Option {
+	return func(o *buildOptions) {
+		o.events = fn
+	}
+}
+
+// emit sends event e to the events callback of build options, if any
+func (o *buildOptions) emit(e BuildEvent) {
+	if o.events != nil {
+		o.events(e)
+	}
+}
+
+// Thresholds of build warnings
+const (
+	warnNodesPerKey   = 16
+	warnRootChildren  = 192
+	warnAlphabetRatio = 8
+)
+
+// warn sends the warning to events callback and logger of build options
+func (o *buildOptions) warn(format string, v ...interface{}) {
+	warning := fmt.Sprintf(format, v...)
+	o.emit(BuildEvent{Kind: BuildWarning, Warning: warning})
+	o.logf("lexicon: warning: %s", warning)
+}
+
+// checkKeySet warns about the key set in dict if it is known to blow up the
+// double array. trie has numNodes nodes, and slots are allocated in blocks
+// of blockSize
+func (o *buildOptions) checkKeySet(
+	dict map[string]int32,
+	trie *_Trie,
+	numNodes int,
+	blockSize int) {
+	if len(dict) == 0 {
+		return
+	}
+
+	// Long keys sharing prefixes but not tails are stored as nodes instead
+	// of suffixes
+	if nodesPerKey := numNodes / len(dict); nodesPerKey > warnNodesPerKey {
+		o.warn(
+			"%d trie nodes per key, long near-identical keys are stored as "+
+				"nodes, consider shortening keys or WithSuffixLength",
+			nodesPerKey)
+	}
+
+	// Children of root are in a single block
+	if len(trie.children) > warnRootChildren {
+		o.warn("%d children of root in a single block", len(trie.children))
+	}
+
+	// Blocks are mostly empty when keys use only a few bytes
+	var used [256]bool
+	alphabet := 0
+	for key := range dict {
+		for i := 0; i < len(key); i++ {
+			if !used[key[i]] {
+				used[key[i]] = true
+				alphabet++
+			}
+		}
+	}
+	if alphabet*warnAlphabetRatio <= blockSize {
+		o.warn(
+			"keys use only %d distinct bytes in blocks of %d slots, "+
+				"consider WithBlockSize(0)",
+			alphabet,
+			blockSize)
+	}
+}
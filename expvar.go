@@ -0,0 +1,50 @@
+package lexicon
+
+import (
+	"expvar"
+	"fmt"
+)
+
+// lexiconMetrics is the lookup counters of Lexicon
+type lexiconMetrics struct {
+	gets           expvar.Int
+	hits           expvar.Int
+	misses         expvar.Int
+	prefixSearches expvar.Int
+}
+
+// recordGet counts a Get and its result
+func (m *lexiconMetrics) recordGet(ok bool) {
+	m.gets.Add(1)
+	if ok {
+		m.hits.Add(1)
+	} else {
+		m.misses.Add(1)
+	}
+}
+
+// PublishExpvar publishes the metrics of Lexicon by expvar as a map named
+// 'name', so they show up in /debug/vars. It includes lookup counters
+// (gets, hits, misses and prefix searches by Complete and WalkPrefix), and
+// structural stats (slots, suffixes and suffix bytes). Lookups are not
+// counted before publishing, and it should be called before Lexicon is
+// shared between goroutines. Returns an error if name is already used
+func (t *Lexicon) PublishExpvar(name string) error {
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("expvar %s is already published", name)
+	}
+
+	m := &lexiconMetrics{}
+	vars := new(expvar.Map).Init()
+	vars.Set("gets", &m.gets)
+	vars.Set("hits", &m.hits)
+	vars.Set("misses", &m.misses)
+	vars.Set("prefix_searches", &m.prefixSearches)
+	vars.Set("slots", expvar.Func(func() interface{} { return len(t.slots) }))
+	vars.Set("suffixes", expvar.Func(func() interface{} { return len(t.suffixIndex) }))
+	vars.Set("suffix_bytes", expvar.Func(func() interface{} { return len(t.suffix) }))
+
+	t.metrics = m
+	expvar.Publish(name, vars)
+	return nil
+}
@@ -0,0 +1,46 @@
+package lexicon
+
+import (
+	"math"
+)
+
+// BuildFloat32 builds the reimu-trie from dict with float32 values, e.g.
+// log-probabilities in NLP dictionaries. Values are stored bit-cast in the
+// int32 arrays, and could be got by GetFloat
+func BuildFloat32(
+	dict map[string]float32,
+	progress func(int, int),
+	opts ...Option) (*Lexicon, error) {
+	intDict := make(map[string]int32, len(dict))
+	for key, value := range dict {
+		intDict[key] = int32(math.Float32bits(value))
+	}
+
+	t, err := Build(intDict, progress, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t.flags |= flagFloat32
+	return t, nil
+}
+
+// HasFloatValues returns true if values in Lexicon are float32, that is,
+// built by BuildFloat32
+func (t *Lexicon) HasFloatValues() bool {
+	return t.flags&flagFloat32 != 0
+}
+
+// GetFloat gets the float32 value by key in Lexicon. For Lexicon with int32
+// values, the value is converted to float32
+func (t *Lexicon) GetFloat(key string) (value float32, ok bool) {
+	v, ok := t.Get(key)
+	if !ok {
+		return 0, false
+	}
+
+	if t.HasFloatValues() {
+		return math.Float32frombits(uint32(v)), true
+	}
+	return float32(v), true
+}
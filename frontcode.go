@@ -0,0 +1,82 @@
+package lexicon
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteFrontCoded writes keys and values in Lexicon to w as front-coded
+// text, ordered by key. Each line is the length of prefix shared with the
+// previous key, the rest of key and the value, separated by tab. It is
+// compact and diff-friendly for huge dictionaries in version control. Keys
+// containing '\n' could not be written
+func (t *Lexicon) WriteFrontCoded(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	previous := ""
+	var err error
+	t.walkPrefix("", func(key string, value int32) bool {
+		if strings.IndexByte(key, '\n') >= 0 {
+			err = fmt.Errorf("unexpected '\\n' in key: %q", key)
+			return false
+		}
+
+		shared := 0
+		for shared < len(key) && shared < len(previous) && key[shared] == previous[shared] {
+			shared++
+		}
+		_, err = fmt.Fprintf(bw, "%d\t%s\t%d\n", shared, key[shared:], value)
+		previous = key
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// ReadFrontCoded reads the dict for Build from front-coded text written by
+// WriteFrontCoded
+func ReadFrontCoded(r io.Reader) (map[string]int32, error) {
+	dict := map[string]int32{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	previous := ""
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		// The rest of key may contain tab, so split by the first and the
+		// last one
+		first := strings.IndexByte(line, '\t')
+		last := strings.LastIndexByte(line, '\t')
+		if first < 0 || first == last {
+			return nil, fmt.Errorf("line %d: expect 3 fields separated by tab", lineNo)
+		}
+		shared, err := strconv.Atoi(line[:first])
+		if err != nil || shared < 0 || shared > len(previous) {
+			return nil, fmt.Errorf("line %d: invalid shared prefix length", lineNo)
+		}
+		value, err := strconv.ParseInt(line[last+1:], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		key := previous[:shared] + line[first+1:last]
+		dict[key] = int32(value)
+		previous = key
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return dict, nil
+}
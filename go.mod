@@ -0,0 +1,3 @@
+module github.com/ling0322/lexicon
+
+go 1.16
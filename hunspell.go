@@ -0,0 +1,377 @@
+package lexicon
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// hunspellAffix is a prefix or suffix rule in Hunspell .aff file
+type hunspellAffix struct {
+	strip        string
+	add          string
+	condition    []hunspellCharClass
+	crossProduct bool
+}
+
+// hunspellCharClass is a character of affix condition: any character (.),
+// one of chars ([abc]) or none of them ([^abc])
+type hunspellCharClass struct {
+	any    bool
+	negate bool
+	chars  string
+}
+
+// hunspellAff is the affix rules and settings in Hunspell .aff file
+type hunspellAff struct {
+	flagType  string
+	aliases   [][]string
+	needAffix string
+	prefixes  map[string][]hunspellAffix
+	suffixes  map[string][]hunspellAffix
+}
+
+// ReadHunspell reads a Hunspell dictionary: the .dic file and optionally the
+// .aff file. Returns the dict for Build and the stems, where the value of a
+// word in dict is the index of its stem. If aff is not nil, words are
+// expanded by the prefix and suffix rules in it, otherwise only stems are
+// read. Files should be encoded in UTF-8. If a word comes from several
+// stems, the first stem is kept
+func ReadHunspell(dic, aff io.Reader) (dict map[string]int32, stems []string, err error) {
+	rules := &hunspellAff{}
+	if aff != nil {
+		rules, err = readHunspellAff(aff)
+		if err != nil {
+			return nil, nil, fmt.Errorf("aff: %w", err)
+		}
+	}
+
+	dict = map[string]int32{}
+	add := func(word string, stem int32) {
+		if _, ok := dict[word]; !ok && word != "" {
+			dict[word] = stem
+		}
+	}
+
+	scanner := bufio.NewScanner(dic)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if lineNo == 1 {
+			// The first line is the approximate number of words
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		word, flagField := splitHunspellWord(line)
+		flags, err := rules.parseFlags(flagField)
+		if err != nil {
+			return nil, nil, fmt.Errorf("dic: line %d: %w", lineNo, err)
+		}
+
+		stem := int32(len(stems))
+		stems = append(stems, word)
+		if aff == nil {
+			add(word, stem)
+			continue
+		}
+		if !containsString(flags, rules.needAffix) {
+			add(word, stem)
+		}
+		rules.expand(word, flags, func(form string) {
+			add(form, stem)
+		})
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return dict, stems, nil
+}
+
+// splitHunspellWord splits the line of .dic file into word and flags. Word
+// ends at the first unescaped '/', flags ends at white space where the
+// optional morphological fields begin
+func splitHunspellWord(line string) (word, flags string) {
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		line = line[:i]
+	}
+
+	b := strings.Builder{}
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) && line[i+1] == '/' {
+			b.WriteByte('/')
+			i++
+		} else if line[i] == '/' {
+			return b.String(), line[i+1:]
+		} else {
+			b.WriteByte(line[i])
+		}
+	}
+	return b.String(), ""
+}
+
+// containsString returns true if s is in list
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// readHunspellAff reads the rules in .aff file
+func readHunspellAff(r io.Reader) (*hunspellAff, error) {
+	rules := &hunspellAff{
+		prefixes: map[string][]hunspellAffix{},
+		suffixes: map[string][]hunspellAffix{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	// The number of rule lines left after a PFX/SFX header, and the cross
+	// product option of them
+	ruleLines := 0
+	crossProduct := false
+	for scanner.Scan() {
+		lineNo++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		switch fields[0] {
+		case "FLAG":
+			if len(fields) > 1 {
+				rules.flagType = fields[1]
+			}
+		case "NEEDAFFIX":
+			if len(fields) > 1 {
+				rules.needAffix = fields[1]
+			}
+		case "AF":
+			// The first AF line is the number of aliases, ignore it
+			if len(fields) > 1 {
+				if _, err := strconv.Atoi(fields[1]); err == nil && rules.aliases == nil {
+					rules.aliases = [][]string{}
+					continue
+				}
+				flags, err := rules.splitFlags(fields[1])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNo, err)
+				}
+				rules.aliases = append(rules.aliases, flags)
+			}
+		case "PFX", "SFX":
+			if len(fields) < 4 {
+				return nil, fmt.Errorf("line %d: invalid affix rule", lineNo)
+			}
+			if ruleLines == 0 {
+				// Header: PFX flag cross_product count
+				count, err := strconv.Atoi(fields[3])
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", lineNo, err)
+				}
+				ruleLines = count
+				crossProduct = fields[2] == "Y"
+				continue
+			}
+
+			// Rule: PFX flag strip add[/flags] [condition]
+			ruleLines--
+			affix := hunspellAffix{crossProduct: crossProduct}
+			if fields[2] != "0" {
+				affix.strip = fields[2]
+			}
+			affix.add = fields[3]
+			if i := strings.IndexByte(affix.add, '/'); i >= 0 {
+				// Continuation flags are not supported
+				affix.add = affix.add[:i]
+			}
+			if affix.add == "0" {
+				affix.add = ""
+			}
+			condition := "."
+			if len(fields) > 4 {
+				condition = fields[4]
+			}
+			var err error
+			affix.condition, err = parseHunspellCondition(condition)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+
+			if fields[0] == "PFX" {
+				rules.prefixes[fields[1]] = append(rules.prefixes[fields[1]], affix)
+			} else {
+				rules.suffixes[fields[1]] = append(rules.suffixes[fields[1]], affix)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+// parseFlags parses the flags of a word, which may be an alias of flags
+func (rules *hunspellAff) parseFlags(field string) ([]string, error) {
+	if field == "" {
+		return nil, nil
+	}
+	if rules.aliases != nil {
+		i, err := strconv.Atoi(field)
+		if err != nil || i < 1 || i > len(rules.aliases) {
+			return nil, fmt.Errorf("invalid flag alias: %s", field)
+		}
+		return rules.aliases[i-1], nil
+	}
+	return rules.splitFlags(field)
+}
+
+// splitFlags splits flags by the flag type
+func (rules *hunspellAff) splitFlags(field string) ([]string, error) {
+	flags := []string{}
+	switch rules.flagType {
+	case "long":
+		if len(field)%2 != 0 {
+			return nil, fmt.Errorf("invalid long flags: %s", field)
+		}
+		for i := 0; i < len(field); i += 2 {
+			flags = append(flags, field[i:i+2])
+		}
+	case "num":
+		flags = strings.Split(field, ",")
+	default:
+		// Single characters
+		for _, r := range field {
+			flags = append(flags, string(r))
+		}
+	}
+	return flags, nil
+}
+
+// expand calls fn for each word formed by applying the affixes of flags on
+// word
+func (rules *hunspellAff) expand(word string, flags []string, fn func(form string)) {
+	for _, flag := range flags {
+		for _, suffix := range rules.suffixes[flag] {
+			form, ok := suffix.applySuffix(word)
+			if !ok {
+				continue
+			}
+			fn(form)
+
+			// Cross product with prefixes
+			if !suffix.crossProduct {
+				continue
+			}
+			for _, prefixFlag := range flags {
+				for _, prefix := range rules.prefixes[prefixFlag] {
+					if !prefix.crossProduct {
+						continue
+					}
+					if crossed, ok := prefix.applyPrefix(form); ok {
+						fn(crossed)
+					}
+				}
+			}
+		}
+		for _, prefix := range rules.prefixes[flag] {
+			if form, ok := prefix.applyPrefix(word); ok {
+				fn(form)
+			}
+		}
+	}
+}
+
+// applySuffix applies the suffix rule on word if its condition matches
+func (a *hunspellAffix) applySuffix(word string) (string, bool) {
+	if !strings.HasSuffix(word, a.strip) || !a.matchSuffix(word) {
+		return "", false
+	}
+	return word[:len(word)-len(a.strip)] + a.add, true
+}
+
+// applyPrefix applies the prefix rule on word if its condition matches
+func (a *hunspellAffix) applyPrefix(word string) (string, bool) {
+	if !strings.HasPrefix(word, a.strip) || !a.matchPrefix(word) {
+		return "", false
+	}
+	return a.add + word[len(a.strip):], true
+}
+
+// matchSuffix returns true if the end of word matches the condition
+func (a *hunspellAffix) matchSuffix(word string) bool {
+	for i := len(a.condition) - 1; i >= 0; i-- {
+		r, size := utf8.DecodeLastRuneInString(word)
+		if size == 0 || !a.condition[i].match(r) {
+			return false
+		}
+		word = word[:len(word)-size]
+	}
+	return true
+}
+
+// matchPrefix returns true if the beginning of word matches the condition
+func (a *hunspellAffix) matchPrefix(word string) bool {
+	for _, class := range a.condition {
+		r, size := utf8.DecodeRuneInString(word)
+		if size == 0 || !class.match(r) {
+			return false
+		}
+		word = word[size:]
+	}
+	return true
+}
+
+// match returns true if r matches the character class
+func (c hunspellCharClass) match(r rune) bool {
+	if c.any {
+		return true
+	}
+	return strings.ContainsRune(c.chars, r) != c.negate
+}
+
+// parseHunspellCondition parses the condition of affix rule. "." alone
+// means no condition
+func parseHunspellCondition(condition string) ([]hunspellCharClass, error) {
+	if condition == "." {
+		return nil, nil
+	}
+
+	classes := []hunspellCharClass{}
+	for len(condition) > 0 {
+		if condition[0] == '[' {
+			end := strings.IndexByte(condition, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid condition: %s", condition)
+			}
+			class := hunspellCharClass{chars: condition[1:end]}
+			if strings.HasPrefix(class.chars, "^") {
+				class.negate = true
+				class.chars = class.chars[1:]
+			}
+			classes = append(classes, class)
+			condition = condition[end+1:]
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(condition)
+		if r == '.' {
+			classes = append(classes, hunspellCharClass{any: true})
+		} else {
+			classes = append(classes, hunspellCharClass{chars: string(r)})
+		}
+		condition = condition[size:]
+	}
+	return classes, nil
+}
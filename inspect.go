@@ -0,0 +1,104 @@
+package lexicon
+
+import (
+	"fmt"
+	"io"
+)
+
+// VisitNodes calls fn for each used slot in the double array, in the order
+// of slot index, without copying the arrays. It is for analysis tools, e.g.
+// computing fill rate or detecting fragmentation. 'check' is the index of
+// parent slot (the root slot 0 is checked by itself). Negative 'base' is a
+// link to suffix -base - 1, and for the value slot of a node, the one at
+// 'base' of its parent, 'base' is the value
+func (t *Lexicon) VisitNodes(fn func(state int32, base, check int32)) {
+	for i := range t.slots {
+		slot := &t.slots[i]
+		if !slot.empty() {
+			fn(int32(i), slot.Base, slot.Check)
+		}
+	}
+}
+
+// NumSlots returns the number of slots in double array, including the free
+// ones
+func (t *Lexicon) NumSlots() int {
+	return len(t.slots)
+}
+
+// DumpStructure writes the structure of double array to w as a tree, for
+// inclusion in bug reports. Each node shows the byte leads to it and its
+// slot index, values and suffix links are shown as leaves
+func (t *Lexicon) DumpStructure(w io.Writer) error {
+	d := &dumper{w: w}
+	d.printf(
+		"LEXICON slots=%d suffixes=%d suffix_bytes=%d",
+		len(t.slots),
+		len(t.suffixIndex),
+		len(t.suffix))
+	d.printf("ROOT [0]")
+	t.dumpNode(d, 0, "")
+
+	return d.err
+}
+
+// dumper writes lines and keeps the first error
+type dumper struct {
+	w   io.Writer
+	err error
+}
+
+// printf writes a formatted line
+func (d *dumper) printf(format string, args ...interface{}) {
+	if d.err == nil {
+		_, d.err = fmt.Fprintf(d.w, format+"\n", args...)
+	}
+}
+
+// dumpNode writes the descendants of state, prefix is the indent of lines
+func (t *Lexicon) dumpNode(d *dumper, state int32, prefix string) {
+	base := t.slots[state].Base
+	if base < 0 {
+		suffixId := -base - 1
+		suffix := []byte{}
+		for p := t.suffixIndex[suffixId]; t.suffix[p] != '\x00'; p++ {
+			suffix = append(suffix, t.suffix[p])
+		}
+		d.printf(
+			"%s+- SUFFIX(%q, %d) [suffix %d]",
+			prefix,
+			suffix,
+			t.suffixValue[suffixId],
+			suffixId)
+		return
+	}
+
+	type edge struct {
+		b     byte
+		state int32
+	}
+	s := State{state: state, suffixId: -1, suffixPtr: -1}
+	edges := []edge{}
+	t.children(&s, func(b byte, child State) bool {
+		edges = append(edges, edge{b, child.state})
+		return true
+	})
+	value, hasValue := t.value(&s)
+	hasValue = hasValue && state != 0
+
+	for i, e := range edges {
+		medium := "|-"
+		nextPrefix := prefix + "|  "
+		if i == len(edges)-1 && !hasValue {
+			medium = "+-"
+			nextPrefix = prefix + "   "
+		}
+		d.printf("%s%s %q [%d]", prefix, medium, e.b, e.state)
+		t.dumpNode(d, e.state, nextPrefix)
+	}
+
+	// The value node
+	if hasValue {
+		d.printf("%s+- VALUE(%d) [%d]", prefix, value, base)
+	}
+}
@@ -0,0 +1,98 @@
+package lexicon
+
+// ItemIterator iterates entries of Lexicon in lexicographical order, keys are
+// reconstructed lazily during traversal. Use it like:
+//
+//	it := t.Items()
+//	for it.Next() {
+//		fmt.Println(it.Key(), it.Value())
+//	}
+type ItemIterator struct {
+	t     *Lexicon
+	stack []itemFrame
+	key   []byte
+	value int32
+}
+
+// itemFrame is a state in the traversal of ItemIterator
+type itemFrame struct {
+	s      State
+	keyLen int
+
+	// The smallest byte of the children not yet visited
+	from int
+}
+
+// Items returns the iterator of all entries in Lexicon
+func (t *Lexicon) Items() *ItemIterator {
+	return &ItemIterator{
+		t:     t,
+		stack: []itemFrame{{InitialState(), 0, 1}},
+	}
+}
+
+// Next moves to the next entry, returns false when there are no more entries
+func (it *ItemIterator) Next() bool {
+	for len(it.stack) > 0 {
+		top := len(it.stack) - 1
+		frame := it.stack[top]
+		b, child, ok := it.t.childFrom(&frame.s, frame.from)
+		if !ok {
+			it.stack = it.stack[:top]
+			continue
+		}
+
+		it.stack[top].from = int(b) + 1
+		it.key = append(it.key[:frame.keyLen], b)
+		it.stack = append(it.stack, itemFrame{child, frame.keyLen + 1, 1})
+		if value, ok := it.t.value(&child); ok {
+			it.value = value
+			return true
+		}
+	}
+
+	return false
+}
+
+// Key returns the key of current entry
+func (it *ItemIterator) Key() string {
+	return string(it.key)
+}
+
+// Value returns the value of current entry
+func (it *ItemIterator) Value() int32 {
+	return it.value
+}
+
+// childFrom returns the first child of state 's' whose byte is not less than
+// 'from', together with the byte leads to it
+func (t *Lexicon) childFrom(s *State, from int) (byte, State, bool) {
+	if s.state >= 0 {
+		base := t.slots[s.state].Base
+		if base >= 0 {
+			for b := from; b < 256; b++ {
+				nextState := base ^ int32(b)
+				if int(nextState) < len(t.slots) && t.slots[nextState].Check == s.state {
+					return byte(b), State{state: nextState, suffixId: -1, suffixPtr: -1}, true
+				}
+			}
+			return 0, State{}, false
+		}
+
+		suffixId := -base - 1
+		suffixPtr := t.suffixIndex[suffixId]
+		b := t.suffix[suffixPtr]
+		if int(b) < from {
+			return 0, State{}, false
+		}
+		return b, State{state: -1, suffixId: suffixId, suffixPtr: suffixPtr + 1}, true
+	} else if s.suffixId >= 0 {
+		b := t.suffix[s.suffixPtr]
+		if b == '\x00' || int(b) < from {
+			return 0, State{}, false
+		}
+		return b, State{state: -1, suffixId: s.suffixId, suffixPtr: s.suffixPtr + 1}, true
+	}
+
+	return 0, State{}, false
+}
@@ -0,0 +1,55 @@
+package lexicon
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadJieba reads a jieba dictionary for Build, where each line is a word
+// and optionally its frequency and POS tag, separated by spaces. Returns
+// the frequencies of words, 0 if not given, and the tags of words which
+// have one, which could be built by BuildStrings. Like jieba, a word
+// appearing again overrides the previous one
+func ReadJieba(r io.Reader) (freqs map[string]int32, tags map[string]string, err error) {
+	freqs = map[string]int32{}
+	tags = map[string]string{}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > 3 {
+			return nil, nil, fmt.Errorf("line %d: expect word, freq and tag", lineNo)
+		}
+
+		word := fields[0]
+		freq := int64(0)
+		if len(fields) > 1 {
+			freq, err = strconv.ParseInt(fields[1], 10, 32)
+			if err != nil {
+				return nil, nil, fmt.Errorf("line %d: %w", lineNo, err)
+			}
+		}
+		freqs[word] = int32(freq)
+		if len(fields) > 2 {
+			tags[word] = fields[2]
+		} else {
+			delete(tags, word)
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return freqs, tags, nil
+}
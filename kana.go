@@ -0,0 +1,236 @@
+package lexicon
+
+import (
+	"strings"
+)
+
+// hepburn is the Hepburn romanization of hiragana, including the
+// combinations with small ya, yu and yo
+var hepburn = map[string]string{
+	"あ": "a", "い": "i", "う": "u", "え": "e", "お": "o",
+	"か": "ka", "き": "ki", "く": "ku", "け": "ke", "こ": "ko",
+	"さ": "sa", "し": "shi", "す": "su", "せ": "se", "そ": "so",
+	"た": "ta", "ち": "chi", "つ": "tsu", "て": "te", "と": "to",
+	"な": "na", "に": "ni", "ぬ": "nu", "ね": "ne", "の": "no",
+	"は": "ha", "ひ": "hi", "ふ": "fu", "へ": "he", "ほ": "ho",
+	"ま": "ma", "み": "mi", "む": "mu", "め": "me", "も": "mo",
+	"や": "ya", "ゆ": "yu", "よ": "yo",
+	"ら": "ra", "り": "ri", "る": "ru", "れ": "re", "ろ": "ro",
+	"わ": "wa", "ゐ": "i", "ゑ": "e", "を": "o", "ん": "n",
+	"が": "ga", "ぎ": "gi", "ぐ": "gu", "げ": "ge", "ご": "go",
+	"ざ": "za", "じ": "ji", "ず": "zu", "ぜ": "ze", "ぞ": "zo",
+	"だ": "da", "ぢ": "ji", "づ": "zu", "で": "de", "ど": "do",
+	"ば": "ba", "び": "bi", "ぶ": "bu", "べ": "be", "ぼ": "bo",
+	"ぱ": "pa", "ぴ": "pi", "ぷ": "pu", "ぺ": "pe", "ぽ": "po",
+	"ゔ": "vu",
+	"ぁ": "a", "ぃ": "i", "ぅ": "u", "ぇ": "e", "ぉ": "o",
+	"ゃ": "ya", "ゅ": "yu", "ょ": "yo", "ゎ": "wa",
+	"きゃ": "kya", "きゅ": "kyu", "きょ": "kyo",
+	"しゃ": "sha", "しゅ": "shu", "しょ": "sho",
+	"ちゃ": "cha", "ちゅ": "chu", "ちょ": "cho",
+	"にゃ": "nya", "にゅ": "nyu", "にょ": "nyo",
+	"ひゃ": "hya", "ひゅ": "hyu", "ひょ": "hyo",
+	"みゃ": "mya", "みゅ": "myu", "みょ": "myo",
+	"りゃ": "rya", "りゅ": "ryu", "りょ": "ryo",
+	"ぎゃ": "gya", "ぎゅ": "gyu", "ぎょ": "gyo",
+	"じゃ": "ja", "じゅ": "ju", "じょ": "jo",
+	"ぢゃ": "ja", "ぢゅ": "ju", "ぢょ": "jo",
+	"びゃ": "bya", "びゅ": "byu", "びょ": "byo",
+	"ぴゃ": "pya", "ぴゅ": "pyu", "ぴょ": "pyo",
+}
+
+// romajiInputs are spellings accepted by RomajiToHiragana besides Hepburn
+var romajiInputs = map[string]string{
+	"si": "し", "ti": "ち", "tu": "つ", "hu": "ふ", "zi": "じ",
+	"di": "ぢ", "du": "づ", "wo": "を", "nn": "ん", "n'": "ん",
+	"sya": "しゃ", "syu": "しゅ", "syo": "しょ",
+	"tya": "ちゃ", "tyu": "ちゅ", "tyo": "ちょ",
+	"zya": "じゃ", "zyu": "じゅ", "zyo": "じょ",
+	"jya": "じゃ", "jyu": "じゅ", "jyo": "じょ",
+	"xa": "ぁ", "xi": "ぃ", "xu": "ぅ", "xe": "ぇ", "xo": "ぉ",
+	"xya": "ゃ", "xyu": "ゅ", "xyo": "ょ", "xtu": "っ",
+	"-": "ー",
+}
+
+// romajiToKana maps romaji to hiragana, built from hepburn and romajiInputs
+var romajiToKana = map[string]string{}
+
+// maxRomajiLength is the length of the longest romaji in romajiToKana
+var maxRomajiLength = 0
+
+func init() {
+	for kana, romaji := range hepburn {
+		// Small kana and historical kana share romaji with the common ones
+		switch kana {
+		case "ぁ", "ぃ", "ぅ", "ぇ", "ぉ", "ゃ", "ゅ", "ょ", "ゎ", "ゐ", "ゑ", "を", "ぢ", "づ",
+			"ぢゃ", "ぢゅ", "ぢょ":
+			continue
+		}
+		romajiToKana[romaji] = kana
+	}
+	for romaji, kana := range romajiInputs {
+		romajiToKana[romaji] = kana
+	}
+	for romaji := range romajiToKana {
+		if len(romaji) > maxRomajiLength {
+			maxRomajiLength = len(romaji)
+		}
+	}
+}
+
+// HiraganaToKatakana converts hiragana in s to katakana, other characters
+// are unchanged
+func HiraganaToKatakana(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ぁ' && r <= 'ゖ' {
+			return r + 'ァ' - 'ぁ'
+		}
+		return r
+	}, s)
+}
+
+// KatakanaToHiragana converts katakana in s to hiragana, other characters
+// are unchanged
+func KatakanaToHiragana(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'ァ' && r <= 'ヶ' {
+			return r - 'ァ' + 'ぁ'
+		}
+		return r
+	}, s)
+}
+
+// isRomajiVowel returns true if b is an ASCII vowel
+func isRomajiVowel(b byte) bool {
+	return strings.IndexByte("aeiou", b) >= 0
+}
+
+// KanaToRomaji converts hiragana and katakana in s to Hepburn romaji, e.g.
+// "トウキョウ" to "toukyou". Long vowels are spelled out instead of macrons,
+// the prolonged sound mark "ー" repeats the previous vowel, and "ん" before a
+// vowel or "y" is "n'". Other characters are unchanged
+func KanaToRomaji(s string) string {
+	runes := []rune(KatakanaToHiragana(s))
+	b := &strings.Builder{}
+	sokuon := false
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if r == 'っ' {
+			sokuon = true
+			continue
+		}
+
+		romaji, ok := "", false
+		if i+1 < len(runes) {
+			romaji, ok = hepburn[string(runes[i:i+2])]
+			if ok {
+				i++
+			}
+		}
+		if !ok {
+			romaji, ok = hepburn[string(r)]
+		}
+		if !ok {
+			if r == 'ー' {
+				if written := b.String(); written != "" && isRomajiVowel(written[len(written)-1]) {
+					b.WriteByte(written[len(written)-1])
+					continue
+				}
+			}
+			if sokuon {
+				b.WriteString("xtu")
+				sokuon = false
+			}
+			b.WriteRune(r)
+			continue
+		}
+
+		if sokuon {
+			// Doubles the consonant, "tch" for "ch"
+			if strings.HasPrefix(romaji, "ch") {
+				b.WriteByte('t')
+			} else if !isRomajiVowel(romaji[0]) {
+				b.WriteByte(romaji[0])
+			} else {
+				b.WriteString("xtu")
+			}
+			sokuon = false
+		}
+		if r == 'ん' && i+1 < len(runes) {
+			if next, ok := hepburn[string(runes[i+1])]; ok && (isRomajiVowel(next[0]) || next[0] == 'y') {
+				romaji = "n'"
+			}
+		}
+		b.WriteString(romaji)
+	}
+	if sokuon {
+		b.WriteString("xtu")
+	}
+
+	return b.String()
+}
+
+// RomajiToHiragana converts romaji in s to hiragana by the longest match,
+// e.g. "toukyou" and "tokyo" to "とうきょう" and "ときょ". Both Hepburn and
+// Nihon-shiki spellings like "shi" and "si" are accepted, doubled consonants
+// are "っ", and "n" not followed by a vowel or "y" is "ん", so "kinnen" is
+// "きんねん" while "kin'en" is "きんえん". ASCII letters are
+// case insensitive, and characters not in romaji are unchanged
+func RomajiToHiragana(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r >= 'A' && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+	b := &strings.Builder{}
+	for i := 0; i < len(s); {
+		matched := false
+		for n := maxRomajiLength; n > 0; n-- {
+			if i+n > len(s) {
+				continue
+			}
+			if kana, ok := romajiToKana[s[i:i+n]]; ok {
+				// "n" and "nn" before a vowel or "y" leave the last "n" to the
+				// next syllable
+				if (s[i:i+n] == "n" || s[i:i+n] == "nn") && i+n < len(s) &&
+					(isRomajiVowel(s[i+n]) || s[i+n] == 'y') {
+					continue
+				}
+				b.WriteString(kana)
+				i += n
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		// Doubled consonant, or "tch"
+		c := s[i]
+		if i+1 < len(s) && c >= 'a' && c <= 'z' && !isRomajiVowel(c) && c != 'n' &&
+			(s[i+1] == c || c == 't' && s[i+1] == 'c') {
+			b.WriteString("っ")
+			i++
+			continue
+		}
+
+		b.WriteByte(c)
+		i++
+	}
+
+	return b.String()
+}
+
+// KanaReading is the transform for reading-based Japanese dictionaries,
+// which converts romaji and katakana to hiragana, so keys and queries in any
+// of the three systems are the same
+func KanaReading() Transform {
+	return Transform{
+		name: "kana-reading",
+		apply: func(key string) string {
+			return KatakanaToHiragana(RomajiToHiragana(key))
+		},
+	}
+}
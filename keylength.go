@@ -0,0 +1,80 @@
+package lexicon
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// KeyLengthPolicy is what Build does with keys longer than the limit set by
+// WithMaxKeyLength
+type KeyLengthPolicy int
+
+const (
+	// Build fails with KeyError of ErrKeyTooLong
+	RejectLongKeys KeyLengthPolicy = iota
+
+	// Keys are truncated to the limit in bytes. Valid UTF-8 keys are cut at
+	// the rune boundary before the limit, so they are still valid, and Build
+	// fails with ErrKeyTooLong if the first rune is longer than the limit.
+	// If several keys become the same, the value of the smallest original
+	// key is kept. Queries are not truncated, so Get of the original key
+	// fails
+	TruncateLongKeys
+)
+
+// WithMaxKeyLength limits the length of keys to n bytes, longer keys are
+// handled by policy. It is checked after transforms. Lookups take time
+// linear to the query length, but a long key which doesn't share its tail
+// is stored entirely in the suffix region, so a few megabyte-long keys could
+// dominate the file size. 0 means no limit, which is the default
+func WithMaxKeyLength(n int, policy KeyLengthPolicy) Option {
+	return func(o *buildOptions) {
+		o.maxKeyLength = n
+		o.keyLengthPolicy = policy
+	}
+}
+
+// limitKeyLength applies the max key length of options on keys in dict
+func limitKeyLength(dict map[string]int32, o *buildOptions) (map[string]int32, error) {
+	if o.maxKeyLength <= 0 {
+		return dict, nil
+	}
+
+	longKeys := []string{}
+	for key := range dict {
+		if len(key) > o.maxKeyLength {
+			longKeys = append(longKeys, key)
+		}
+	}
+	if len(longKeys) == 0 {
+		return dict, nil
+	}
+	sort.Strings(longKeys)
+	if o.keyLengthPolicy != TruncateLongKeys {
+		return nil, &KeyError{longKeys[0], ErrKeyTooLong}
+	}
+
+	limited := make(map[string]int32, len(dict))
+	for key, value := range dict {
+		if len(key) <= o.maxKeyLength {
+			limited[key] = value
+		}
+	}
+	for _, key := range longKeys {
+		end := o.maxKeyLength
+		if utf8.ValidString(key) {
+			for end > 0 && !utf8.RuneStart(key[end]) {
+				end--
+			}
+		}
+		// The first rune is longer than the limit
+		if end == 0 {
+			return nil, &KeyError{key, ErrKeyTooLong}
+		}
+		truncated := key[:end]
+		if _, ok := limited[truncated]; !ok {
+			limited[truncated] = dict[key]
+		}
+	}
+	return limited, nil
+}
@@ -0,0 +1,73 @@
+package lexicon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// WriteV1 writes Lexicon to w in file format version 1, for the readers of
+// older versions. Version 1 stores only the double array and suffixes, so it
+// fails with ErrUnsupportedVersion if Lexicon has float values, transforms,
+// phonetic or value index, columns, rows or strings
+func (t *Lexicon) WriteV1(w io.Writer) error {
+	if t.flags != 0 || len(t.transforms) > 0 ||
+		t.phonetic != nil || t.values != nil || t.columns != nil ||
+		t.rows != nil || t.strings != nil {
+		return fmt.Errorf("%w: version 1 could not store the lexicon", ErrUnsupportedVersion)
+	}
+
+	bw := bufio.NewWriter(w)
+	var err error
+	binaryWrite := func(data interface{}) {
+		if err == nil {
+			err = binary.Write(bw, binary.LittleEndian, data)
+		}
+	}
+
+	slots := t.paddedSlots()
+	err = checkLength("slots", len(slots))
+	if err == nil {
+		err = checkLength("suffix bytes", len(t.suffix))
+	}
+	binaryWrite([]byte(headerV1))
+	binaryWrite(int32(len(slots)))
+	binaryWrite(int32(len(t.suffixIndex)))
+	binaryWrite(int32(len(t.suffix)))
+	binaryWrite(slots)
+	binaryWrite(t.suffixIndexOf(false))
+	binaryWrite(t.suffixValue)
+	binaryWrite(t.suffix)
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// paddedSlots returns slots padded by empty ones, so that base ^ b is inside
+// slots for each node and byte b. Readers of version 1 don't check the bound
+func (t *Lexicon) paddedSlots() []slotT {
+	slots := t.slots
+	size := len(slots)
+	for i := range slots {
+		if slots[i].empty() || slots[i].Base < 0 {
+			continue
+		}
+		if i != 0 && slots[slots[i].Check].Base == int32(i) {
+			// Value slot, its base is the value
+			continue
+		}
+		if int(slots[i].Base|0xff)+1 > size {
+			size = int(slots[i].Base|0xff) + 1
+		}
+	}
+
+	padded := make([]slotT, size)
+	copy(padded, slots)
+	for i := len(slots); i < size; i++ {
+		padded[i].Check = -1
+	}
+	return padded
+}
@@ -1,38 +1,142 @@
 package lexicon
 
 import (
+	"bufio"
 	"encoding/binary"
-	"errors"
 	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
 	"os"
 	"strings"
 )
 
-const Header = "REIMU_Lex.v1"
+// Header is the header of current file format. Version 2 adds flags after
+// the header, files of version 1 (headerV1) could still be read
+const Header = "REIMU_Lex.v2"
+const headerV1 = "REIMU_Lex.v1"
+const headerPrefix = "REIMU_Lex.v"
 const ProgressStep = 4096
 
+// Flags of lexicon, stored after header
+const (
+	// Values are float32 bit-cast into int32
+	flagFloat32 uint32 = 1 << iota
+
+	// Only keys are stored, all values are 0 and suffixValue is omitted in
+	// file
+	flagSet
+
+	// All keys are valid UTF-8, checked by WithUTF8Validation
+	flagUTF8
+
+	// suffixIndex and suffixValue are packed in file, see WithPackedSuffix.
+	// It is a flag of file encoding and never set in Lexicon
+	flagPackedSuffix
+
+	// Slots are varints in file, see WithCompactEncoding. It is a flag of
+	// file encoding and never set in Lexicon
+	flagVarintSlots
+
+	// Slots are int16 pairs in file, see WithSmallSlots. It is a flag of file
+	// encoding and never set in Lexicon
+	flagSmallSlots
+
+	// Number of suffix bytes and suffixIndex are int64 in file, which is set
+	// only if suffix bytes exceed int32. It is a flag of file and never set in
+	// Lexicon
+	flagSuffix64
+)
+
+// knownFlags are all flags known by this version, files with other flags are
+// from newer writers
+const knownFlags = flagFloat32 | flagSet | flagUTF8 | flagSuffix64 | encodingFlags
+
+// encodingFlags are the flags of file encoding
+const encodingFlags = flagPackedSuffix | flagVarintSlots | flagSmallSlots
+
+// Optional sections are stored after the double array and suffix, each one
+// is: tag (4 bytes), length of payload (int32) and payload. Readers skip the
+// sections they don't know
+const sectionTagSize = 4
+const sectionPhonetic = "PHON"
+const sectionColumns = "COLS"
+const sectionStrings = "STRT"
+const sectionRows = "ROWS"
+const sectionTransforms = "XFRM"
+const sectionBloom = "BLOM"
+const sectionValueIndex = "VIDX"
+
+// The checksum section is the last one, its payload is the CRC-32 (IEEE) of
+// all bytes before it
+const sectionChecksum = "CRC3"
+
 // Lexicon is the double array implementation of a trie-based lexicon
 type Lexicon struct {
 	slots []slotT
 
-	suffixIndex []int32
+	// suffixIndex is int64 since suffix could exceed 2GB, e.g. URLs with
+	// long tails. Number of suffixes is still int32 as suffix ids are in base
+	suffixIndex []int64
 	suffixValue []int32
 	suffix      []byte
 
+	// Flags of this lexicon, see flagXXX
+	flags uint32
+
+	// Transforms applied to keys in Build and queries in Get
+	transforms []Transform
+
+	// Optional index of phonetic codes, nil if not built
+	phonetic *phoneticIndex
+
+	// Optional value columns of keys, nil if not built by BuildColumns
+	columns *columnTable
+
+	// Optional string values of keys, nil if not built by BuildStrings
+	strings *stringTable
+
+	// Optional string columns of keys, nil if not built by BuildRows
+	rows *rowTable
+
+	// Optional Bloom filter of keys, nil if not built with WithBloomFilter
+	bloom *bloomFilter
+
+	// Optional index of keys by values, nil if not built with WithValueIndex
+	values *valueIndex
+
 	// Free blocks are the blocks which have free slots. Only be used in trie
 	// building. Here freeBlocks should be an array to keep blocks in order
 	freeBlocks []*blockT
 
+	// Number of slots in one block, also only be used in trie building. It
+	// should be a power of 2 and greater than any byte in keys, so children
+	// of a node are always in the same block
+	blockSize int
+
 	// Only used to display progress
 	totalNodes     int
 	processedNodes int
+
+	// Receiver of build events, only be used in trie building
+	events func(BuildEvent)
+
+	// Error of trie building, e.g. ErrTooLarge. Nodes are not placed after it
+	// is set
+	buildErr error
+
+	// Lookup counters, only if published by PublishExpvar
+	metrics *lexiconMetrics
+
+	// Tracer of GetBatch, could be nil
+	tracer Tracer
 }
 
 // State keeps the state in traversing the trie
 type State struct {
 	state     int32
 	suffixId  int32
-	suffixPtr int32
+	suffixPtr int64
 }
 
 // slotT in one cell in double array trie, constsis of two values: base and
@@ -42,7 +146,7 @@ type slotT struct {
 	Check int32
 }
 
-// A block represents the information of 256 slots in reimu-trie
+// A block represents the information of blockSize slots in reimu-trie
 type blockT struct {
 	blockId   int
 	freeSlots int
@@ -57,6 +161,12 @@ func InitialState() State {
 	}
 }
 
+// Reset resets the state to the initial state, so that it could be reused
+// for another traversal, e.g. a State got from sync.Pool
+func (s *State) Reset() {
+	*s = InitialState()
+}
+
 // Valid returns if this state is valid
 func (s *State) Valid() bool {
 	return s.state >= 0 || s.suffixId >= 0
@@ -71,39 +181,95 @@ func (s *slotT) empty() bool {
 func newLexicon() *Lexicon {
 	t := &Lexicon{
 		slots:       []slotT{},
-		suffixIndex: []int32{},
+		suffixIndex: []int64{},
 		suffixValue: []int32{},
 		suffix:      []byte{},
 		freeBlocks:  []*blockT{},
+		blockSize:   256,
 	}
 	return t
 }
 
+// Clone returns a deep copy of Lexicon, which shares no memory with t. The
+// states of t could also be used in the copy
+func (t *Lexicon) Clone() *Lexicon {
+	c := &Lexicon{
+		slots:       append([]slotT{}, t.slots...),
+		suffixIndex: append([]int64{}, t.suffixIndex...),
+		suffixValue: append([]int32{}, t.suffixValue...),
+		suffix:      append([]byte{}, t.suffix...),
+		flags:       t.flags,
+		transforms:  append([]Transform{}, t.transforms...),
+		tracer:      t.tracer,
+	}
+	if t.phonetic != nil {
+		c.phonetic = &phoneticIndex{
+			algorithm: t.phonetic.algorithm,
+			codes:     t.phonetic.codes.Clone(),
+			keyIds:    append([]int32{}, t.phonetic.keyIds...),
+		}
+	}
+	if t.columns != nil {
+		c.columns = t.columns.clone()
+	}
+	if t.strings != nil {
+		c.strings = t.strings.clone()
+	}
+	if t.rows != nil {
+		c.rows = t.rows.clone()
+	}
+	if t.bloom != nil {
+		c.bloom = t.bloom.clone()
+	}
+	if t.values != nil {
+		c.values = t.values.clone()
+	}
+
+	return c
+}
+
 // addBlock adds a new block into reimu-trie, returns the index of created
 // block
 func (t *Lexicon) addBlock() int {
-	block := make([]slotT, 256)
+	block := make([]slotT, t.blockSize)
 	for i := range block {
 		block[i].Check = -1
 	}
 
-	numBlocks := len(t.slots) / 256
+	numBlocks := len(t.slots) / t.blockSize
 
 	t.slots = append(t.slots, block...)
+	if t.buildErr == nil {
+		t.buildErr = checkLength("slots", len(t.slots))
+	}
 	t.freeBlocks = append(t.freeBlocks, &blockT{
 		blockId:   numBlocks,
-		freeSlots: 256,
+		freeSlots: t.blockSize,
 	})
+	if t.events != nil {
+		t.events(BuildEvent{Kind: BuildBlockAllocated, Block: numBlocks})
+	}
 
 	return numBlocks
 }
 
+// emitNodesPlaced sends the BuildNodesPlaced event of the current progress
+func (t *Lexicon) emitNodesPlaced() {
+	if t.events != nil {
+		t.events(BuildEvent{
+			Kind:       BuildNodesPlaced,
+			Nodes:      t.processedNodes,
+			TotalNodes: t.totalNodes,
+		})
+	}
+}
+
 // findSuitableBase finds a base in slots to put child-nodes of given node
 func (t *Lexicon) findSuitableBase(node *_Trie) int {
 	assert(!node.isEmpty() && !node.hasSuffix, "findSuitableBase: invalid node")
 	children := make([]byte, 0, 256)
-	for child := range node.children {
-		children = append(children, child)
+	for _, child := range node.children {
+		children = append(children, child.label)
 	}
 	// Value node is in child 0
 	if node.hasValue {
@@ -114,7 +280,8 @@ func (t *Lexicon) findSuitableBase(node *_Trie) int {
 		if b.freeSlots >= len(children) {
 			// This node has adequate free slots for child-nodes. Then check
 			// whether all nodes could be placed well
-			for base := b.blockId * 256; base < (b.blockId+1)*256; base++ {
+			firstSlot := b.blockId * t.blockSize
+			for base := firstSlot; base < firstSlot+t.blockSize; base++ {
 				success := true
 				for _, child := range children {
 					s := base ^ int(child)
@@ -135,15 +302,12 @@ func (t *Lexicon) findSuitableBase(node *_Trie) int {
 	// Since the new added block is an empty block, we can use the first slot
 	// in this block directly
 	blockId := t.addBlock()
-	return blockId * 256
+	return blockId * t.blockSize
 }
 
-// build builds the reimu-trie from trie, returns the base value of this node in
-// double array trie
-func (t *Lexicon) build(
-	node *_Trie,
-	fromState int32,
-	progress func(int, int)) int32 {
+// visit counts a visited node in building, adds a new block when there's no
+// free block and displays the progress
+func (t *Lexicon) visit(progress func(int, int)) {
 	// Add a new block when didn't have free blocks
 	if len(t.freeBlocks) == 0 {
 		t.addBlock()
@@ -151,84 +315,211 @@ func (t *Lexicon) build(
 
 	// Display progress when needed
 	t.processedNodes++
-	if progress != nil && t.processedNodes%ProgressStep == 0 {
-		progress(t.processedNodes, t.totalNodes)
+	if t.processedNodes%ProgressStep == 0 {
+		if progress != nil {
+			progress(t.processedNodes, t.totalNodes)
+		}
+		t.emitNodesPlaced()
+	}
+}
+
+// place places the value and children of node into double array, returns
+// the base of node. The base of children are not set
+func (t *Lexicon) place(node *_Trie, fromState int32) int {
+	base := t.findSuitableBase(node)
+	slotsRequired := 0
+
+	// Value node
+	if node.hasValue {
+		assert(t.slots[base].empty(), "buildLexicon: invalid base value")
+		t.slots[base].Base = node.value
+		t.slots[base].Check = fromState
+		slotsRequired++
+	}
+
+	// Set 'check' array for children. This step also mark child-slots
+	// as 'used'
+	for _, child := range node.children {
+		s := base ^ int(child.label)
+		assert(t.slots[s].empty(), "buildLexicon: invalid base value")
+		t.slots[s].Check = fromState
+
+		slotsRequired++
+	}
+
+	// Update block state
+	blockId := base / t.blockSize
+	blockUpdated := false
+	for i, block := range t.freeBlocks {
+		if block.blockId == blockId {
+			blockUpdated = true
+			block.freeSlots -= slotsRequired
+			assert(block.freeSlots >= 0, "buildLexicon: invalid block.freeSlots")
+
+			if block.freeSlots == 0 {
+				// Ok, we need to remove this block from freeBlocks
+				t.freeBlocks = append(t.freeBlocks[:i], t.freeBlocks[i+1:]...)
+			}
+			break
+		}
+	}
+	assert(blockUpdated, "buildLexicon: block not exist")
+
+	return base
+}
+
+// build builds the reimu-trie from trie, returns the base value of this node in
+// double array trie. Descendants of node are released after placed, so node
+// couldn't be used after
+func (t *Lexicon) build(
+	node *_Trie,
+	fromState int32,
+	progress func(int, int)) int32 {
+	if t.buildErr != nil {
+		return 0
 	}
+	t.visit(progress)
 
 	if node.hasSuffix {
 		// If this node is a suffix node
 		suffixId := len(t.suffixValue)
 		t.suffixValue = append(t.suffixValue, node.value)
-		t.suffixIndex = append(t.suffixIndex, int32(len(t.suffix)))
+		t.suffixIndex = append(t.suffixIndex, int64(len(t.suffix)))
 
-		suffixBytes := make([]byte, len(node.suffix)+1)
-		copy(suffixBytes, node.suffix)
-		suffixBytes[len(suffixBytes)-1] = '\x00'
-		t.suffix = append(t.suffix, suffixBytes...)
+		t.suffix = append(t.suffix, node.suffix...)
+		t.suffix = append(t.suffix, '\x00')
+		node.suffix = nil
+		if err := checkLength("suffixes", len(t.suffixValue)); err != nil {
+			t.buildErr = err
+		}
 
 		// Negative value in base indicates its a index in suffixValue
 		// If index in suffixValue & suffixValue is i, then base = -i - 1
 		return int32(-suffixId - 1)
 	} else {
-		base := t.findSuitableBase(node)
-		slotsRequired := 0
-
-		// Value node
-		if node.hasValue {
-			assert(t.slots[base].empty(), "buildLexicon: invalid base value")
-			t.slots[base].Base = node.value
-			t.slots[base].Check = fromState
-			slotsRequired++
-		}
-
-		// Set 'check' array for children. This step also mark child-slots
-		// as 'used'
-		for b := range node.children {
-			s := base ^ int(b)
-			assert(t.slots[s].empty(), "buildLexicon: invalid base value")
-			t.slots[s].Check = fromState
-
-			slotsRequired++
-		}
-
-		// Update block state
-		blockId := base / 256
-		blockUpdated := false
-		for i, block := range t.freeBlocks {
-			if block.blockId == blockId {
-				blockUpdated = true
-				block.freeSlots -= slotsRequired
-				assert(block.freeSlots >= 0, "buildLexicon: invalid block.freeSlots")
-
-				if block.freeSlots == 0 {
-					// Ok, we need to remove this block from freeBlocks
-					t.freeBlocks = append(t.freeBlocks[:i], t.freeBlocks[i+1:]...)
-				}
-				break
-			}
-		}
-		assert(blockUpdated, "buildLexicon: block not exist")
+		base := t.place(node, fromState)
 
 		// Set 'base' array for children. Also recursively calling
-		// buildLexicon() for child-nodes
-		for b, childNode := range node.children {
-			s := base ^ int(b)
-			t.slots[s].Base = t.build(childNode, int32(s), progress)
+		// buildLexicon() for child-nodes. Placed children are dropped from
+		// node, so their memory could be reclaimed while building the rest
+		for i, child := range node.children {
+			s := base ^ int(child.label)
+			t.slots[s].Base = t.build(child.node, int32(s), progress)
+			node.children[i].node = nil
 		}
+		node.children = nil
 
 		return int32(base)
 	}
 }
 
+// maxLength is the max length of arrays in Lexicon, since their lengths and
+// indexes are int32
+const maxLength = 1<<31 - 1
+
+// checkLength returns ErrTooLarge if the array 'what' of n elements exceeds
+// maxLength
+func checkLength(what string, n int) error {
+	if n > maxLength {
+		return fmt.Errorf("%w: %d %s exceed the limit %d", ErrTooLarge, n, what, maxLength)
+	}
+	return nil
+}
+
 // Build builds the reimu-trie from dict
-func Build(dict map[string]int32, progress func(int, int)) (*Lexicon, error) {
-	trie, err := buildTrie(dict)
+func Build(
+	dict map[string]int32,
+	progress func(int, int),
+	opts ...Option) (t *Lexicon, err error) {
+	options := newBuildOptions(opts)
+	ctx, end := startSpan(options.traceContext, options.tracer, "lexicon.Build")
+	defer func() { end(err) }()
+
+	_, endPhase := startSpan(ctx, options.tracer, "lexicon.Build.trie")
+	options.emit(BuildEvent{Kind: BuildPhaseStarted, Phase: BuildPhaseTrie})
+	dict = transformKeys(dict, options.transforms)
+	if dict, err = limitKeyLength(dict, options); err != nil {
+		endPhase(err)
+		return nil, err
+	}
+	if options.validateUTF8 {
+		if err = checkUTF8(dict); err != nil {
+			endPhase(err)
+			return nil, err
+		}
+	}
+	var trie *_Trie
+	var spill *trieSpill
+	if options.memoryLimit > 0 {
+		trie, spill, err = buildSpilledTrie(
+			dict,
+			options.keepSuffix(),
+			options.memoryLimit)
+		defer spill.close()
+	} else {
+		trie, err = buildTrie(dict, options.keepSuffix())
+	}
+	endPhase(err)
 	if err != nil {
 		return nil, err
 	}
 
+	_, endPhase = startSpan(ctx, options.tracer, "lexicon.Build.doubleArray")
+	options.emit(BuildEvent{Kind: BuildPhaseStarted, Phase: BuildPhaseDoubleArray})
+	Lexicon, err := buildDoubleArray(trie, spill, dict, progress, options)
+	endPhase(err)
+	if err != nil || len(dict) == 0 {
+		return Lexicon, err
+	}
+
+	if options.validateUTF8 {
+		Lexicon.flags |= flagUTF8
+	}
+	if options.bloomBitsPerKey > 0 {
+		Lexicon.bloom = newBloomFilter(dict, options.bloomBitsPerKey)
+	}
+	if options.valueIndex {
+		Lexicon.values = buildValueIndex(Lexicon)
+	}
+
+	if options.phonetic != 0 {
+		_, endPhase = startSpan(ctx, options.tracer, "lexicon.Build.phonetic")
+		options.emit(BuildEvent{Kind: BuildPhaseStarted, Phase: BuildPhasePhonetic})
+		Lexicon.phonetic, err = buildPhoneticIndex(Lexicon, dict, options.phonetic)
+		endPhase(err)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	options.logf(
+		"lexicon: built %d keys into %d slots and %d suffix bytes",
+		len(dict),
+		len(Lexicon.slots),
+		len(Lexicon.suffix))
+	return Lexicon, nil
+}
+
+// buildDoubleArray builds the double array from trie of dict. spill has the
+// subtrees spilled out of trie, could be nil
+func buildDoubleArray(
+	trie *_Trie,
+	spill *trieSpill,
+	dict map[string]int32,
+	progress func(int, int),
+	options *buildOptions) (*Lexicon, error) {
+	var err error
 	Lexicon := newLexicon()
-	Lexicon.totalNodes = trie.countNode()
+	Lexicon.tracer = options.tracer
+	Lexicon.events = options.events
+	defer func() { Lexicon.events = nil }()
+	Lexicon.totalNodes = trie.countNode() + spill.countNode()
+	Lexicon.transforms = append([]Transform{}, options.transforms...)
+	Lexicon.blockSize, err = blockSizeOf(dict, options.blockSize)
+	if err != nil {
+		return nil, err
+	}
+	options.checkKeySet(dict, trie, Lexicon.totalNodes, Lexicon.blockSize)
 
 	// Prepare the root node in Lexicon
 	Lexicon.addBlock()
@@ -236,23 +527,70 @@ func Build(dict map[string]int32, progress func(int, int)) (*Lexicon, error) {
 		Base:  0,
 		Check: 0,
 	}
-	Lexicon.freeBlocks[0].freeSlots = 255
+	Lexicon.freeBlocks[0].freeSlots = Lexicon.blockSize - 1
 	if len(dict) == 0 {
 		// If it is an empty dict, just return an empty lexicon
 		return Lexicon, nil
 	}
 
-	rootBase := Lexicon.build(trie, 0, progress)
+	var rootBase int32
+	if spill == nil {
+		rootBase = Lexicon.build(trie, 0, progress)
+	} else if rootBase, err = Lexicon.buildSpilled(trie, spill, progress); err != nil {
+		return nil, err
+	}
+	if Lexicon.buildErr != nil {
+		return nil, Lexicon.buildErr
+	}
 	assert(rootBase == 0, "Build: invalid rootBase")
 
 	if progress != nil {
 		progress(Lexicon.totalNodes, Lexicon.totalNodes)
 	}
+	Lexicon.emitNodesPlaced()
+	options.emit(BuildEvent{
+		Kind:        BuildSuffixWritten,
+		SuffixBytes: len(Lexicon.suffix),
+	})
 
 	return Lexicon, nil
 }
 
-// Traverse traverses the Lexicon by character list 'key' from state 's'.
+// blockSizeOf checks the block size for keys in dict, or derives the block
+// size from the alphabet of keys when blockSize is 0
+func blockSizeOf(dict map[string]int32, blockSize int) (int, error) {
+	if blockSize < 0 || blockSize > 256 || blockSize&(blockSize-1) != 0 {
+		return 0, fmt.Errorf("%w: %d", ErrInvalidBlockSize, blockSize)
+	}
+
+	var maxByte byte
+	for key := range dict {
+		for i := 0; i < len(key); i++ {
+			if key[i] > maxByte {
+				maxByte = key[i]
+			}
+		}
+	}
+
+	if blockSize == 0 {
+		// The smallest power of 2 greater than all bytes
+		blockSize = 2
+		for blockSize <= int(maxByte) {
+			blockSize *= 2
+		}
+	} else if int(maxByte) >= blockSize {
+		return 0, fmt.Errorf(
+			"%w: %d is too small for byte 0x%02x in keys",
+			ErrInvalidBlockSize,
+			blockSize,
+			maxByte)
+	}
+
+	return blockSize, nil
+}
+
+// Traverse traverses the Lexicon by character list 'key' from state 's'. It
+// never allocates memory.
 // Returns values by different conditions are:
 //   - Traverse success & final state have value:
 //       value = <value>, ok = true, s.Valid() = true
@@ -261,49 +599,111 @@ func Build(dict map[string]int32, progress func(int, int)) (*Lexicon, error) {
 //   - Traverse failed
 //       value = UNDEFINED, ok = false, s.Valid() = false
 func (t *Lexicon) Traverse(key string, s *State) (value int32, ok bool) {
-	for i := 0; i < len(key); i++ {
-		// NULL char is not allowed in Reimu-trie
-		b := key[i]
-		if b == '\x00' {
-			return -1, false
-		}
+	// NULL char is not allowed in Reimu-trie. Check it once here instead of
+	// for each byte in loops below
+	if strings.IndexByte(key, '\x00') >= 0 {
+		s.state = -1
+		s.suffixId = -1
+		return -1, false
+	}
 
-		if s.state >= 0 {
-			// In double array
-			base := t.slots[s.state].Base
-			if base >= 0 {
-				nextState := base ^ int32(b)
-				// Still in double array
-				if t.slots[nextState].Check != s.state {
-					s.state = -1
-					s.suffixId = -1
-					return -1, false
-				}
-				s.state = nextState
-				continue
-			} else {
+	i := 0
+	if s.state >= 0 {
+		// In double array
+		slots := t.slots
+		state := s.state
+		for ; i < len(key); i++ {
+			base := slots[state].Base
+			if base < 0 {
 				// Switch to suffix
-				s.state = -1
 				s.suffixId = -base - 1
 				s.suffixPtr = t.suffixIndex[s.suffixId]
+				state = -1
+				break
 			}
+
+			nextState := base ^ int32(key[i])
+			if int(nextState) >= len(slots) || slots[nextState].Check != state {
+				s.state = -1
+				s.suffixId = -1
+				return -1, false
+			}
+			state = nextState
 		}
+		s.state = state
+	}
 
-		if s.suffixId >= 0 {
-			// In suffix
-			if b != t.suffix[s.suffixPtr] {
+	if s.suffixId >= 0 {
+		// In suffix. Suffix is terminated by '\x00' which never equals to a
+		// byte in key, so no need to check the bound
+		suffix := t.suffix
+		suffixPtr := s.suffixPtr
+		for ; i < len(key); i++ {
+			if key[i] != suffix[suffixPtr] {
 				s.state = -1
 				s.suffixId = -1
 				return -1, false
 			}
-			s.suffixPtr++
+			suffixPtr++
 		}
+		s.suffixPtr = suffixPtr
 	}
 
 	// Traverse finished, get values
+	return t.value(s)
+}
+
+// next moves state 's' forward by byte 'b'. Returns false and invalidates 's'
+// if there is no such transition in Lexicon
+func (t *Lexicon) next(s *State, b byte) bool {
+	// NULL char is not allowed in Reimu-trie
+	if b == '\x00' {
+		s.state = -1
+		s.suffixId = -1
+		return false
+	}
+
+	if s.state >= 0 {
+		// In double array
+		base := t.slots[s.state].Base
+		if base >= 0 {
+			nextState := base ^ int32(b)
+			// Still in double array. nextState may be out of slots when 'b'
+			// is not less than the block size or trailing slots are trimmed
+			if int(nextState) >= len(t.slots) || t.slots[nextState].Check != s.state {
+				s.state = -1
+				s.suffixId = -1
+				return false
+			}
+			s.state = nextState
+			return true
+		} else {
+			// Switch to suffix
+			s.state = -1
+			s.suffixId = -base - 1
+			s.suffixPtr = t.suffixIndex[s.suffixId]
+		}
+	}
+
+	if s.suffixId >= 0 {
+		// In suffix
+		if b != t.suffix[s.suffixPtr] {
+			s.state = -1
+			s.suffixId = -1
+			return false
+		}
+		s.suffixPtr++
+		return true
+	}
+
+	return false
+}
+
+// value gets the value of state 's'
+func (t *Lexicon) value(s *State) (value int32, ok bool) {
 	if s.state >= 0 {
 		base := t.slots[s.state].Base
-		if base < 0 || t.slots[base].Check != s.state {
+		if base < 0 || int(base) >= len(t.slots) || t.slots[base].Check != s.state {
 			return -1, false
 		} else {
 			return t.slots[base].Base, true
@@ -319,112 +719,699 @@ func (t *Lexicon) Traverse(key string, s *State) (value int32, ok bool) {
 	return -1, false
 }
 
+// children calls fn for each child of state 's' in byte order, together with
+// the byte leads to it. Stops once fn returns false, and returns false in that
+// case
+func (t *Lexicon) children(s *State, fn func(b byte, child State) bool) bool {
+	if s.state >= 0 {
+		base := t.slots[s.state].Base
+		if base >= 0 {
+			// Slot base ^ 0 is the value node, skip it
+			for b := 1; b < 256; b++ {
+				nextState := base ^ int32(b)
+				if int(nextState) < len(t.slots) && t.slots[nextState].Check == s.state {
+					child := State{state: nextState, suffixId: -1, suffixPtr: -1}
+					if !fn(byte(b), child) {
+						return false
+					}
+				}
+			}
+			return true
+		} else {
+			suffixId := -base - 1
+			suffixPtr := t.suffixIndex[suffixId]
+			child := State{state: -1, suffixId: suffixId, suffixPtr: suffixPtr + 1}
+			return fn(t.suffix[suffixPtr], child)
+		}
+	} else if s.suffixId >= 0 {
+		b := t.suffix[s.suffixPtr]
+		if b != '\x00' {
+			child := State{state: -1, suffixId: s.suffixId, suffixPtr: s.suffixPtr + 1}
+			return fn(b, child)
+		}
+	}
+
+	return true
+}
+
+// walk calls fn for each key-value pair in the subtree of state 's' in
+// lexicographical order. key is the bytes to reach 's' and it is reused
+// between calls, fn should copy it if needed. Stops once fn returns false,
+// and returns false in that case
+func (t *Lexicon) walk(
+	s *State,
+	key []byte,
+	fn func(key []byte, value int32) bool) bool {
+	// The root slot is checked by itself, it never has a value
+	if s.state != 0 {
+		if value, ok := t.value(s); ok && !fn(key, value) {
+			return false
+		}
+	}
+
+	return t.children(s, func(b byte, child State) bool {
+		return t.walk(&child, append(key, b), fn)
+	})
+}
+
+// keyId returns the id of key in Lexicon. The id of a key is the index of
+// its value slot when the key ends in double array, or the index of the slot
+// links to its suffix otherwise
+func (t *Lexicon) keyId(key string) (int32, bool) {
+	s := InitialState()
+	link := int32(-1)
+	for i := 0; i < len(key); i++ {
+		if s.state >= 0 && t.slots[s.state].Base < 0 {
+			link = s.state
+		}
+		if !t.next(&s, key[i]) {
+			return -1, false
+		}
+	}
+
+	if _, ok := t.value(&s); !ok || s.state == 0 {
+		return -1, false
+	}
+	if s.state >= 0 {
+		return t.slots[s.state].Base, true
+	}
+	return link, true
+}
+
+// isKeyId returns true if slot id is the id of a key, see keyId. ids out of
+// slots are not, so it could check the ids from file
+func (t *Lexicon) isKeyId(id int32) bool {
+	if id <= 0 || int(id) >= len(t.slots) || t.slots[id].empty() {
+		return false
+	}
+
+	parent := t.slots[id].Check
+	if int(parent) >= len(t.slots) {
+		return false
+	}
+	if t.slots[parent].Base == id {
+		// Value slot
+		return true
+	}
+	base := t.slots[id].Base
+	return base < 0 && int(-base-1) < len(t.suffixValue)
+}
+
+// keyAt reconstructs the key by its id
+func (t *Lexicon) keyAt(id int32) []byte {
+	key := []byte{}
+	state := id
+	parent := t.slots[state].Check
+	if t.slots[parent].Base == state {
+		// Value slot, the byte to it is '\x00'
+		state = parent
+	} else {
+		suffixId := -t.slots[state].Base - 1
+		for p := t.suffixIndex[suffixId]; t.suffix[p] != '\x00'; p++ {
+			key = append(key, t.suffix[p])
+		}
+	}
+
+	// Collect the bytes from state to root, in reversed order
+	tail := key
+	key = []byte{}
+	for state != 0 {
+		parent := t.slots[state].Check
+		key = append(key, byte(state^t.slots[parent].Base))
+		state = parent
+	}
+	for i, j := 0, len(key)-1; i < j; i, j = i+1, j-1 {
+		key[i], key[j] = key[j], key[i]
+	}
+
+	return append(key, tail...)
+}
+
+// valueAt gets the value of key by its id
+func (t *Lexicon) valueAt(id int32) int32 {
+	parent := t.slots[id].Check
+	if t.slots[parent].Base == id {
+		return t.slots[id].Base
+	}
+	return t.suffixValue[-t.slots[id].Base-1]
+}
+
 // Get gets the value by key in Lexicon. On success, returns (value, true).
-// On failed, returns (ok = false)
+// On failed, returns (ok = false). Transforms of Lexicon are applied to key
+// first. It never allocates memory unless key is changed by transforms
 func (t *Lexicon) Get(key string) (value int32, ok bool) {
+	value, ok = t.get(key)
+	if t.metrics != nil {
+		t.metrics.recordGet(ok)
+	}
+	return value, ok
+}
+
+// get is Get without counting in metrics
+func (t *Lexicon) get(key string) (value int32, ok bool) {
+	if len(t.transforms) > 0 {
+		key = t.Normalize(key)
+	}
+
+	// Same as Traverse from the initial state, but no need to keep the state
+	// resumable. Empty key is never in Lexicon
+	if len(key) == 0 || strings.IndexByte(key, '\x00') >= 0 {
+		return -1, false
+	}
+	if t.bloom != nil && !t.bloom.mayContain(key) {
+		return -1, false
+	}
+
+	slots := t.slots
+	state := int32(0)
+	for i := 0; i < len(key); i++ {
+		base := slots[state].Base
+		if base < 0 {
+			// The rest of key should be exactly the suffix
+			return t.getSuffix(-base-1, key[i:])
+		}
+
+		nextState := base ^ int32(key[i])
+		if int(nextState) >= len(slots) || slots[nextState].Check != state {
+			return -1, false
+		}
+		state = nextState
+	}
+
+	base := slots[state].Base
+	if base < 0 || int(base) >= len(slots) || slots[base].Check != state {
+		return -1, false
+	}
+	return slots[base].Base, true
+}
+
+// getSuffix returns the value of suffix 'suffixId' if it equals to 'rest'
+func (t *Lexicon) getSuffix(suffixId int32, rest string) (value int32, ok bool) {
+	begin := int(t.suffixIndex[suffixId])
+	end := begin + len(rest)
+	if end >= len(t.suffix) || string(t.suffix[begin:end]) != rest {
+		return -1, false
+	}
+	if t.suffix[end] != '\x00' {
+		return -1, false
+	}
+
+	return t.suffixValue[suffixId], true
+}
+
+// GetOrDefault gets the value by key in Lexicon, returns 'def' if key not
+// exists
+func (t *Lexicon) GetOrDefault(key string, def int32) int32 {
+	if value, ok := t.Get(key); ok {
+		return value
+	}
+	return def
+}
+
+// MustGet gets the value by key in Lexicon, panics if key not exists
+func (t *Lexicon) MustGet(key string) int32 {
+	value, ok := t.Get(key)
+	if !ok {
+		panic(fmt.Sprintf("MustGet: key not exist: %q", key))
+	}
+	return value
+}
+
+// LongestCommonPrefixLength returns the number of leading bytes of 'query'
+// which could be matched along the trie, no matter whether a key ends there
+func (t *Lexicon) LongestCommonPrefixLength(query string) int {
 	s := InitialState()
-	return t.Traverse(key, &s)
+	for i := 0; i < len(query); i++ {
+		if !t.next(&s, query[i]) {
+			return i
+		}
+	}
+	return len(query)
 }
 
-// Read reads reimu-trie from file
-func Read(filename string) (*Lexicon, error) {
-	t := new(Lexicon)
+// trimmedSlots returns slots without the trailing free slots. They are left
+// by build when the last block becomes full or has unused slots. Traversal
+// checks the index of slot, so it is safe to drop them
+func (t *Lexicon) trimmedSlots() []slotT {
+	numSlots := len(t.slots)
+	for numSlots > 1 && t.slots[numSlots-1].empty() {
+		numSlots--
+	}
+
+	return t.slots[:numSlots]
+}
+
+// suffixIndexOf returns suffixIndex in file, which is int32 unless suffix64
+func (t *Lexicon) suffixIndexOf(suffix64 bool) interface{} {
+	if suffix64 {
+		return t.suffixIndex
+	}
+	suffixIndex := make([]int32, len(t.suffixIndex))
+	for i, begin := range t.suffixIndex {
+		suffixIndex[i] = int32(begin)
+	}
+	return suffixIndex
+}
+
+// readSuffixIndex reads numSuffix suffixIndex from r, which is int32 in file
+// unless suffix64
+func readSuffixIndex(r io.Reader, numSuffix int32, suffix64 bool, previousErr error) ([]int64, error) {
+	if previousErr != nil {
+		return nil, previousErr
+	}
+
+	suffixIndex := make([]int64, numSuffix)
+	if suffix64 {
+		return suffixIndex, binary.Read(r, binary.LittleEndian, suffixIndex)
+	}
+	suffixIndex32 := make([]int32, numSuffix)
+	if err := binary.Read(r, binary.LittleEndian, suffixIndex32); err != nil {
+		return nil, err
+	}
+	for i, begin := range suffixIndex32 {
+		suffixIndex[i] = int64(begin)
+	}
+	return suffixIndex, nil
+}
+
+// Read reads reimu-trie from file. Sizes in file are checked against the
+// file length before allocating, so a corrupted file could not demand more
+// memory than its length, or the limit set by WithMemoryLimit
+func Read(filename string, opts ...ReadOption) (t *Lexicon, err error) {
+	options := newReadOptions(opts)
+	_, end := startSpan(options.traceContext, options.tracer, "lexicon.Read")
+	defer func() { end(err) }()
+
 	fd, err := os.Open(filename)
 	if err != nil {
 		return nil, err
 	}
 	defer fd.Close()
 
+	info, err := fd.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	budget := &readBudget{size: info.Size(), memoryLimit: options.memoryLimit}
+	t, err = readLexicon(bufio.NewReader(fd), budget)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	t.tracer = options.tracer
+	return t, nil
+}
+
+// readBudget limits the bytes allocated in reading a lexicon by the size of
+// input, since each allocated byte is read from input, and by the memory limit
+type readBudget struct {
+	size        int64
+	memoryLimit int64
+	allocated   int64
+}
+
+// alloc checks whether n more bytes could be allocated
+func (b *readBudget) alloc(n int64) error {
+	b.allocated += n
+	if n < 0 || b.allocated > b.size {
+		return ErrCorrupted
+	}
+	if b.memoryLimit > 0 && b.allocated > b.memoryLimit {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+// decode checks whether n bytes decoded from allocated ones fit in the
+// memory limit. They are not counted in allocated
+func (b *readBudget) decode(n int64) error {
+	if b.memoryLimit > 0 && b.allocated+n > b.memoryLimit {
+		return ErrMemoryLimit
+	}
+	return nil
+}
+
+// readLexicon reads reimu-trie from reader, with sizes in it checked by budget
+func readLexicon(r io.Reader, budget *readBudget) (*Lexicon, error) {
+	t := new(Lexicon)
+	var err error
+
+	checksum := crc32.NewIEEE()
+	r = io.TeeReader(r, checksum)
+
 	// Function to call binary.Read
 	binaryRead := func(dataPtr interface{}, previousErr error) error {
 		if previousErr != nil {
 			return previousErr
 		}
 
-		err := binary.Read(fd, binary.LittleEndian, dataPtr)
+		err := binary.Read(r, binary.LittleEndian, dataPtr)
 		return err
 	}
 
 	header := make([]byte, len(Header))
 	err = binaryRead(&header, err)
-	if err == nil && string(header) != Header {
-		return nil, errors.New(fmt.Sprintf("Corrupted file: %s", filename))
+	if err == nil && string(header) != Header && string(header) != headerV1 {
+		if strings.HasPrefix(string(header), headerPrefix) {
+			return nil, ErrUnsupportedVersion
+		}
+		return nil, ErrCorruptHeader
 	}
+	if err == nil && string(header) == Header {
+		err = binaryRead(&t.flags, err)
+	}
+	if err == nil && t.flags&^knownFlags != 0 {
+		return nil, ErrUnsupportedVersion
+	}
+	packed := t.flags&flagPackedSuffix != 0
+	varintSlots := t.flags&flagVarintSlots != 0
+	smallSlots := t.flags&flagSmallSlots != 0
+	suffix64 := t.flags&flagSuffix64 != 0
+	t.flags &^= encodingFlags | flagSuffix64
 
 	var numSlots int32
 	var numSuffix int32
-	var numSuffixBytes int32
+	var numSuffixBytes int64
 	err = binaryRead(&numSlots, err)
 	err = binaryRead(&numSuffix, err)
-	err = binaryRead(&numSuffixBytes, err)
+	if suffix64 {
+		err = binaryRead(&numSuffixBytes, err)
+	} else {
+		var n int32
+		err = binaryRead(&n, err)
+		numSuffixBytes = int64(n)
+	}
+	if err == nil && (numSlots < 0 || numSuffix < 0 || numSuffixBytes < 0) {
+		err = ErrCorrupted
+	}
+	if err == nil {
+		// Packed arrays are checked after their lengths are read
+		allocated := numSuffixBytes
+		if !varintSlots && !smallSlots {
+			allocated += int64(numSlots) * 8
+		}
+		if !packed && suffix64 {
+			allocated += int64(numSuffix) * 12
+		} else if !packed {
+			// suffixIndex is int64 in memory
+			allocated += int64(numSuffix) * 8
+			err = budget.decode(int64(numSuffix) * 4)
+		}
+		if err == nil {
+			err = budget.alloc(allocated)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	t.slots = make([]slotT, numSlots)
-	t.suffixIndex = make([]int32, numSuffix)
-	t.suffixValue = make([]int32, numSuffix)
 	t.suffix = make([]byte, numSuffixBytes)
-	err = binaryRead(&t.slots, err)
-	err = binaryRead(&t.suffixIndex, err)
-	err = binaryRead(&t.suffixValue, err)
+	if varintSlots {
+		t.slots, err = readPackedSlots(r, numSlots, budget, err)
+	} else if smallSlots {
+		t.slots, err = readSmallSlots(r, numSlots, budget, err)
+	} else {
+		t.slots = make([]slotT, numSlots)
+		err = binaryRead(&t.slots, err)
+	}
+	if packed {
+		t.suffixIndex, t.suffixValue, err = readPackedSuffix(
+			r,
+			numSuffix,
+			t.flags&flagSet != 0,
+			budget,
+			err)
+	} else {
+		t.suffixValue = make([]int32, numSuffix)
+		t.suffixIndex, err = readSuffixIndex(r, numSuffix, suffix64, err)
+		if t.flags&flagSet == 0 {
+			err = binaryRead(&t.suffixValue, err)
+		}
+	}
 	err = binaryRead(&t.suffix, err)
+	if err == nil {
+		err = t.verifySuffix()
+	}
+	if err == nil {
+		err = t.readSections(r, checksum, budget)
+	}
+	if err != nil {
+		return nil, err
+	}
 
-	return t, err
+	return t, nil
 }
 
-// Save saves the reimu-trie to file
-func (t *Lexicon) Save(filename string) error {
+// readSections reads optional sections from r until the end of it. checksum
+// is the hash of all bytes read from r, including the bytes before sections
+func (t *Lexicon) readSections(r io.Reader, checksum hash.Hash32, budget *readBudget) error {
+	var err error
+	binaryRead := func(dataPtr interface{}, previousErr error) error {
+		if previousErr != nil {
+			return previousErr
+		}
+		return binary.Read(r, binary.LittleEndian, dataPtr)
+	}
+
+	for {
+		sum := checksum.Sum32()
+		tag := make([]byte, sectionTagSize)
+		var length int32
+		_, err = io.ReadFull(r, tag)
+		if err == io.EOF {
+			break
+		}
+		err = binaryRead(&length, err)
+		if err == nil {
+			err = budget.alloc(int64(length))
+		}
+		if err != nil {
+			return err
+		}
+
+		payload := make([]byte, length)
+		err = binaryRead(&payload, err)
+		if err != nil {
+			return err
+		}
+
+		switch string(tag) {
+		case sectionPhonetic:
+			t.phonetic, err = readPhoneticIndex(payload)
+		case sectionColumns:
+			t.columns, err = readColumnTable(payload)
+		case sectionStrings:
+			t.strings, err = readStringTable(payload)
+		case sectionRows:
+			t.rows, err = readRowTable(payload)
+		case sectionTransforms:
+			t.transforms, err = readTransforms(payload)
+		case sectionBloom:
+			t.bloom, err = readBloomFilter(payload)
+		case sectionValueIndex:
+			t.values, err = readValueIndex(payload, len(t.slots))
+		case sectionChecksum:
+			if len(payload) != 4 || binary.LittleEndian.Uint32(payload) != sum {
+				err = ErrChecksum
+			}
+		default:
+			// Unknown sections are from newer writers, just skip them
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return t.verifySections()
+}
+
+// Save saves the reimu-trie to file. opts select the encoding of file, e.g.
+// WithPackedSuffix
+func (t *Lexicon) Save(filename string, opts ...SaveOption) error {
 	fd, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
 	defer fd.Close()
 
+	w := bufio.NewWriter(fd)
+	err = t.write(w, newSaveOptions(opts))
+	if err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+// Write writes the reimu-trie to w in the same format as Save
+func (t *Lexicon) Write(w io.Writer, opts ...SaveOption) error {
+	return t.write(w, newSaveOptions(opts))
+}
+
+// write writes the reimu-trie to writer in the encoding of options
+func (t *Lexicon) write(w io.Writer, options *saveOptions) error {
+	var err error
+
+	checksum := crc32.NewIEEE()
+	w = io.MultiWriter(w, checksum)
+
 	// function to call binary.Write
 	binaryWrite := func(data interface{}, previousErr error) error {
 		if previousErr != nil {
 			return previousErr
 		}
 
-		err := binary.Write(fd, binary.LittleEndian, data)
+		err := binary.Write(w, binary.LittleEndian, data)
 		return err
 	}
 
+	slots := t.trimmedSlots()
+	err = checkLength("slots", len(slots))
+	if err == nil {
+		err = checkLength("suffixes", len(t.suffixIndex))
+	}
+	flags := t.flags | options.flags(slots)
+	if checkLength("suffix bytes", len(t.suffix)) != nil {
+		flags |= flagSuffix64
+	}
+	suffix64 := flags&flagSuffix64 != 0
 	err = binaryWrite([]byte(Header), err)
-	err = binaryWrite(int32(len(t.slots)), err)
+	err = binaryWrite(flags, err)
+	err = binaryWrite(int32(len(slots)), err)
 	err = binaryWrite(int32(len(t.suffixIndex)), err)
-	err = binaryWrite(int32(len(t.suffix)), err)
-	err = binaryWrite(t.slots, err)
-	err = binaryWrite(t.suffixIndex, err)
-	err = binaryWrite(t.suffixValue, err)
+	if suffix64 {
+		err = binaryWrite(int64(len(t.suffix)), err)
+	} else {
+		err = binaryWrite(int32(len(t.suffix)), err)
+	}
+	if options.varintSlots {
+		packed := packSlots(slots)
+		err = binaryWrite(int32(len(packed)), err)
+		err = binaryWrite(packed, err)
+	} else if options.flags(slots)&flagSmallSlots != 0 {
+		err = binaryWrite(smallSlotsOf(slots), err)
+	} else {
+		err = binaryWrite(slots, err)
+	}
+	if options.packedSuffix {
+		packed := packSuffix(t.suffixIndex, t.suffixValue, t.flags&flagSet != 0)
+		err = binaryWrite(int32(len(packed)), err)
+		err = binaryWrite(packed, err)
+	} else {
+		err = binaryWrite(t.suffixIndexOf(suffix64), err)
+		if t.flags&flagSet == 0 {
+			err = binaryWrite(t.suffixValue, err)
+		}
+	}
 	err = binaryWrite(t.suffix, err)
+	if err != nil {
+		return err
+	}
+
+	return t.writeSections(w, checksum)
+}
+
+// writeSections writes optional sections to w, and then the checksum section.
+// checksum is the hash of all bytes written to w, including the bytes before
+// sections
+func (t *Lexicon) writeSections(w io.Writer, checksum hash.Hash32) error {
+	var err error
+	binaryWrite := func(data interface{}, previousErr error) error {
+		if previousErr != nil {
+			return previousErr
+		}
+		return binary.Write(w, binary.LittleEndian, data)
+	}
+
+	writeSection := func(tag string, payload []byte, previousErr error) error {
+		if previousErr == nil {
+			previousErr = checkLength("section "+tag, len(payload))
+		}
+		err := binaryWrite([]byte(tag), previousErr)
+		err = binaryWrite(int32(len(payload)), err)
+		err = binaryWrite(payload, err)
+		return err
+	}
+
+	if t.phonetic != nil {
+		var payload []byte
+		payload, err = t.phonetic.marshal()
+		err = writeSection(sectionPhonetic, payload, err)
+	}
+	if t.columns != nil && err == nil {
+		var payload []byte
+		payload, err = t.columns.marshal()
+		err = writeSection(sectionColumns, payload, err)
+	}
+	if t.strings != nil && err == nil {
+		var payload []byte
+		payload, err = t.strings.marshal()
+		err = writeSection(sectionStrings, payload, err)
+	}
+	if t.rows != nil && err == nil {
+		var payload []byte
+		payload, err = t.rows.marshal()
+		err = writeSection(sectionRows, payload, err)
+	}
+	if len(t.transforms) > 0 && err == nil {
+		var payload []byte
+		payload, err = marshalTransforms(t.transforms)
+		err = writeSection(sectionTransforms, payload, err)
+	}
+	if t.bloom != nil {
+		err = writeSection(sectionBloom, t.bloom.marshal(), err)
+	}
+	if t.values != nil && err == nil {
+		var payload []byte
+		payload, err = t.values.marshal()
+		err = writeSection(sectionValueIndex, payload, err)
+	}
+
+	// Checksum should be the last one
+	sum := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sum, checksum.Sum32())
+	err = writeSection(sectionChecksum, sum, err)
 
 	return err
 }
 
-// ProgressBar prints a progress bar with processed and total
+// ProgressBar prints a progress bar with processed and total to stdout. Use
+// NewProgressBar to print it elsewhere
 func ProgressBar(processed, total int) {
+	writeProgressBar(os.Stdout, processed, total)
+}
+
+// NewProgressBar returns the progress function of Build printing the progress
+// bar to w
+func NewProgressBar(w io.Writer) func(processed, total int) {
+	return func(processed, total int) {
+		writeProgressBar(w, processed, total)
+	}
+}
+
+// writeProgressBar prints the progress bar with processed and total to w
+func writeProgressBar(w io.Writer, processed, total int) {
 	const barWidth = 64
 
 	if processed >= total {
-		fmt.Printf("\r[%s] Done     \n", strings.Repeat("=", barWidth))
+		fmt.Fprintf(w, "\r[%s] Done     \n", strings.Repeat("=", barWidth))
 	} else {
-		fmt.Printf("\r[")
+		fmt.Fprintf(w, "\r[")
 		pos := barWidth * processed / total
-		fmt.Printf("%s", strings.Repeat("=", pos))
+		fmt.Fprintf(w, "%s", strings.Repeat("=", pos))
 		switch processed / ProgressStep % 4 {
 		case 0:
-			fmt.Printf("-")
+			fmt.Fprintf(w, "-")
 		case 1:
-			fmt.Printf("\\")
+			fmt.Fprintf(w, "\\")
 		case 2:
-			fmt.Printf("|")
+			fmt.Fprintf(w, "|")
 		case 3:
-			fmt.Printf("/")
+			fmt.Fprintf(w, "/")
 		}
 		precentage := float64(processed) / float64(total) * 100.0
-		fmt.Printf(
+		fmt.Fprintf(
+			w,
 			"%s] % 3.2f%%\r",
 			strings.Repeat(" ", barWidth-pos-1),
 			precentage)
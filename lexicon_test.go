@@ -1,8 +1,34 @@
 package lexicon
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"expvar"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"log"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 )
 
 const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -47,37 +73,37 @@ func prepareData(N, maxLen int) (dict map[string]int32, testData []sampleT) {
 }
 
 func BenchmarkLexicon(b *testing.B) {
-	kMaxLen := 25
+	const N = 100000
+	const kMaxLen = 25
 
-	b.StopTimer()
 	// Prepare testing data
-	dict, testData := prepareData(b.N, kMaxLen)
+	dict, testData := prepareData(N, kMaxLen)
 
 	// Build lexicon
 	lexicon, err := Build(dict, nil)
 	if err != nil {
 		b.FailNow()
 	}
-	b.StartTimer()
+	b.ResetTimer()
 
 	sum := int32(0)
-	for _, sample := range testData {
-		v, _ := lexicon.Get(sample.key)
+	for i := 0; i < b.N; i++ {
+		v, _ := lexicon.Get(testData[i%len(testData)].key)
 		sum += v
 	}
 }
 
 func BenchmarkGoMap(b *testing.B) {
-	kMaxLen := 25
+	const N = 100000
+	const kMaxLen = 25
 
-	b.StopTimer()
 	// Prepare testing data
-	dict, testData := prepareData(b.N, kMaxLen)
-	b.StartTimer()
+	dict, testData := prepareData(N, kMaxLen)
+	b.ResetTimer()
 
 	sum := int32(0)
-	for _, sample := range testData {
-		sum += dict[sample.key]
+	for i := 0; i < b.N; i++ {
+		sum += dict[testData[i%len(testData)].key]
 	}
 }
 
@@ -113,3 +139,2772 @@ func TestRandomString(t *testing.T) {
 		}
 	}
 }
+
+func TestGetOrDefault(t *testing.T) {
+	lexicon, err := Build(map[string]int32{"ab": 1, "abc": 2}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	if lexicon.GetOrDefault("ab", -1) != 1 || lexicon.GetOrDefault("abc", -1) != 2 {
+		t.FailNow()
+	}
+	if lexicon.GetOrDefault("a", -1) != -1 || lexicon.GetOrDefault("abcd", 7) != 7 {
+		t.FailNow()
+	}
+
+	if lexicon.MustGet("abc") != 2 {
+		t.FailNow()
+	}
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), `"xyz"`) {
+			t.FailNow()
+		}
+	}()
+	lexicon.MustGet("xyz")
+}
+
+func TestLongestCommonPrefixLength(t *testing.T) {
+	lexicon, err := Build(map[string]int32{"abc": 1, "abd": 2, "world": 3}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	// "world" is the only key starting with 'w', so "orld" is in suffix
+	s := InitialState()
+	lexicon.Traverse("wo", &s)
+	if s.suffixId < 0 {
+		t.FailNow()
+	}
+
+	cases := map[string]int{
+		"":       0,
+		"x":      0,
+		"abc":    3,
+		"abcdef": 3,
+		"abx":    2,
+		"worm":   3,
+		"worlds": 5,
+	}
+	for query, expected := range cases {
+		if lexicon.LongestCommonPrefixLength(query) != expected {
+			t.FailNow()
+		}
+	}
+}
+
+func TestTraverseClasses(t *testing.T) {
+	dict := map[string]int32{"ad": 1, "be": 2, "bf": 3, "cfg": 4}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	candidates := lexicon.TraverseClasses([][]byte{[]byte("abc"), []byte("def")})
+	found := map[string]int32{}
+	for _, c := range candidates {
+		if c.HasValue {
+			found[c.Key] = c.Value
+		}
+	}
+	if len(candidates) != 4 || len(found) != 3 {
+		t.FailNow()
+	}
+	if found["ad"] != 1 || found["be"] != 2 || found["bf"] != 3 {
+		t.FailNow()
+	}
+
+	// The only candidate of empty classes is the initial state without value
+	candidates = lexicon.TraverseClasses(nil)
+	if len(candidates) != 1 || candidates[0].HasValue || candidates[0].Key != "" {
+		t.FailNow()
+	}
+}
+
+func TestStepAll(t *testing.T) {
+	lexicon, err := Build(map[string]int32{"ab": 1, "b": 2, "xa": 3}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	a, x, invalid := InitialState(), InitialState(), InitialState()
+	lexicon.Traverse("a", &a)
+	lexicon.Traverse("x", &x)
+	lexicon.Traverse("z", &invalid)
+	if !a.Valid() || !x.Valid() || invalid.Valid() {
+		t.FailNow()
+	}
+
+	states := []State{a, invalid, InitialState(), x}
+	original := append([]State{}, states...)
+	next := lexicon.StepAll(states, 'b')
+	if len(next) != len(states) {
+		t.FailNow()
+	}
+	for i := range states {
+		if states[i] != original[i] {
+			t.FailNow()
+		}
+	}
+
+	expected := []struct {
+		valid bool
+		value int32
+	}{{true, 1}, {false, 0}, {true, 2}, {false, 0}}
+	for i, e := range expected {
+		if next[i].Valid() != e.valid {
+			t.FailNow()
+		}
+		if value, ok := lexicon.value(&next[i]); e.valid && (!ok || value != e.value) {
+			t.FailNow()
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	dict := map[string]int32{"receive": 1, "received": 2, "recipe": 3, "deceive": 4}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	entries := lexicon.Complete("rec", 0)
+	if len(entries) != 3 || entries[0].Key != "receive" || entries[2].Key != "recipe" {
+		t.FailNow()
+	}
+
+	entries = lexicon.Complete("recieve", 2)
+	if len(entries) < 2 || entries[0].Key != "receive" || entries[1].Key != "received" {
+		t.FailNow()
+	}
+	for _, e := range entries {
+		if e.Key == "deceive" {
+			t.FailNow()
+		}
+	}
+
+	// Filter is applied during the walk, which stops at the limit
+	visited := 0
+	entries = lexicon.CompleteFiltered("rec", 1, func(key string, value int32) bool {
+		visited++
+		return value >= 2
+	})
+	if fmt.Sprint(entries) != "[{received 2}]" || visited != 2 {
+		t.FailNow()
+	}
+	entries = lexicon.CompleteFiltered("", 0, func(key string, value int32) bool {
+		return value%2 == 1
+	})
+	if fmt.Sprint(entries) != "[{receive 1} {recipe 3}]" {
+		t.FailNow()
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	dict := map[string]int32{"the": 100, "then": 20, "they": 50, "hello": 10, "help": 30, "held": 5}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	// Ranked by edit distance, then by value
+	if fmt.Sprint(lexicon.Suggest("thw", 3)) != "[{the 100} {they 50} {then 20}]" {
+		t.FailNow()
+	}
+	if fmt.Sprint(lexicon.Suggest("helo", 2)) != "[{help 30} {hello 10}]" {
+		t.FailNow()
+	}
+	if fmt.Sprint(lexicon.Suggest("the", 2)) != "[{the 100} {they 50}]" {
+		t.FailNow()
+	}
+	if len(lexicon.Suggest("xyzzy", 3)) != 0 || len(lexicon.Suggest("the", 0)) != 0 {
+		t.FailNow()
+	}
+
+	// Plausible substitutions rank before the more frequent ones
+	lexicon, err = Build(map[string]int32{"the": 1, "thy": 100, "ten": 50}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	if lexicon.Suggest("thw", 1)[0].Key != "thy" {
+		t.FailNow()
+	}
+	if lexicon.Suggest("thw", 1, WithCostMatrix(QWERTYCosts(0.5)))[0].Key != "the" {
+		t.FailNow()
+	}
+	costs := NewCostMatrix()
+	costs.Set('e', 'h', 0.3)
+	if fmt.Sprint(lexicon.Suggest("teh", 2, WithCostMatrix(costs))) != "[{the 1} {ten 50}]" {
+		t.FailNow()
+	}
+	if QWERTYCosts(0.5).Cost('G', 'B') != 0.5 || QWERTYCosts(0.5).Cost('q', 'p') != 1 {
+		t.FailNow()
+	}
+}
+
+func TestDidYouMean(t *testing.T) {
+	dict := map[string]int32{"new": 10, "news": 50, "newspaper": 30, "network": 40, "nexus": 5, "york": 1}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	// Exact key, then completions by value
+	if fmt.Sprint(lexicon.DidYouMean("new", 3)) != "[{new 10} {news 50} {newspaper 30}]" {
+		t.FailNow()
+	}
+	if fmt.Sprint(lexicon.DidYouMean("ne", 2)) != "[{news 50} {network 40}]" {
+		t.FailNow()
+	}
+
+	// Fuzzy completions fill up the rest
+	if fmt.Sprint(lexicon.DidYouMean("newz", 3)) != "[{news 50} {newspaper 30} {new 10}]" {
+		t.FailNow()
+	}
+	if fmt.Sprint(lexicon.DidYouMean("nexu", 3)) != "[{nexus 5}]" {
+		t.FailNow()
+	}
+	if fmt.Sprint(lexicon.DidYouMean("netwrk", 2)) != "[{network 40}]" {
+		t.FailNow()
+	}
+	if len(lexicon.DidYouMean("new", 0)) != 0 || len(lexicon.DidYouMean("qq", 3)) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestPhonetic(t *testing.T) {
+	if Soundex("Robert") != "R163" || Soundex("Rupert") != "R163" ||
+		Soundex("Ashcraft") != "A261" || Soundex("Tymczak") != "T522" {
+		t.FailNow()
+	}
+	if Metaphone("Smith") != "SM0" || Metaphone("Knight") != "NT" {
+		t.FailNow()
+	}
+
+	dict := map[string]int32{"Smith": 1, "Smyth": 2, "Schmidt": 3, "Jones": 4}
+	for _, algorithm := range []PhoneticAlgorithm{PhoneticSoundex, PhoneticMetaphone} {
+		lexicon, err := Build(dict, nil, WithPhoneticIndex(algorithm))
+		if err != nil {
+			t.FailNow()
+		}
+		err = lexicon.Save("lexicon.reimu")
+		if err != nil {
+			t.FailNow()
+		}
+		lexicon, err = Read("lexicon.reimu")
+		if err != nil {
+			t.FailNow()
+		}
+
+		found := map[string]int32{}
+		for _, e := range lexicon.GetPhonetic("Smyth") {
+			found[e.Key] = e.Value
+		}
+		if found["Smith"] != 1 || found["Smyth"] != 2 {
+			t.FailNow()
+		}
+		if _, ok := found["Jones"]; ok {
+			t.FailNow()
+		}
+	}
+
+	// Tampered counts and key ids are rejected
+	lexicon, err := Build(dict, nil, WithPhoneticIndex(PhoneticSoundex))
+	if err != nil {
+		t.FailNow()
+	}
+	data, err := lexicon.MarshalBinary()
+	if err != nil {
+		t.FailNow()
+	}
+	for _, tamper := range []func(payload []byte){
+		func(payload []byte) { binary.LittleEndian.PutUint32(payload[0:], 7) },
+		func(payload []byte) { binary.LittleEndian.PutUint32(payload[8:], 1<<20) },
+		func(payload []byte) { binary.LittleEndian.PutUint32(payload[12:], 1<<30) },
+	} {
+		read := &Lexicon{}
+		err = read.UnmarshalBinary(tamperSection(data, sectionPhonetic, tamper))
+		if !errors.Is(err, ErrCorrupted) {
+			t.FailNow()
+		}
+	}
+}
+
+func TestFindAll(t *testing.T) {
+	dict := map[string]int32{"he": 1, "hers": 2, "she": 3, "his": 4}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	matches := lexicon.FindAll("ushers")
+	expected := []Match{{1, 4, "she", 3}, {2, 4, "he", 1}, {2, 6, "hers", 2}}
+	if len(matches) != len(expected) {
+		t.FailNow()
+	}
+	for i := range expected {
+		if matches[i] != expected[i] {
+			t.FailNow()
+		}
+	}
+
+	match, ok := lexicon.LongestPrefix("hersh")
+	if !ok || match != (Match{0, 4, "hers", 2}) {
+		t.FailNow()
+	}
+	if _, ok = lexicon.LongestPrefix("ushers"); ok {
+		t.FailNow()
+	}
+}
+
+func TestContainsAny(t *testing.T) {
+	dict := map[string]int32{"he": 1, "hers": 2, "she": 3, "ers": 4}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	// "she" starts before "he" and "hers", and "he" is the shortest at 3
+	match, ok := lexicon.ContainsAny("ushers")
+	if !ok || match != (Match{1, 4, "she", 3}) {
+		t.FailNow()
+	}
+	match, ok = lexicon.ContainsAny("xhers")
+	if !ok || match != (Match{1, 3, "he", 1}) {
+		t.FailNow()
+	}
+
+	for _, text := range []string{"", "h", "hxe", "shx"} {
+		if match, ok = lexicon.ContainsAny(text); ok || match != (Match{}) {
+			t.FailNow()
+		}
+	}
+}
+
+func TestScanner(t *testing.T) {
+	dict := map[string]int32{"he": 1, "hers": 2, "she": 3, "his": 4}
+	for i := 0; i < 100; i++ {
+		dict[strings.Repeat("x", i+1)+"y"] = int32(i)
+	}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	// Matches straddle the buffer boundaries
+	text := strings.Repeat("ushers his "+strings.Repeat("x", 80)+"y ", 200)
+	expected := lexicon.FindAll(text)
+	for _, r := range []io.Reader{strings.NewReader(text), iotest.OneByteReader(strings.NewReader(text))} {
+		scanner := NewScanner(lexicon, r)
+		matches := []Match{}
+		for scanner.Scan() {
+			matches = append(matches, scanner.Match())
+		}
+		if scanner.Err() != nil || len(matches) != len(expected) {
+			t.FailNow()
+		}
+		for i := range expected {
+			if matches[i] != expected[i] {
+				t.FailNow()
+			}
+		}
+	}
+
+	r := io.MultiReader(strings.NewReader("ushers"), iotest.ErrReader(io.ErrUnexpectedEOF))
+	scanner := NewScanner(lexicon, r)
+	for scanner.Scan() {
+	}
+	if scanner.Err() != io.ErrUnexpectedEOF {
+		t.FailNow()
+	}
+}
+
+func TestReplacer(t *testing.T) {
+	dict := map[string]int32{"new": 1, "new york": 2, "york": 3}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	replacer := NewReplacer(lexicon, func(key string, value int32) string {
+		return "<" + key + ">"
+	})
+	if replacer.Replace("a new york newspaper") != "a <new york> <new>spaper" {
+		t.FailNow()
+	}
+}
+
+func TestNGrams(t *testing.T) {
+	dict := map[string]int32{"new": 1, "new york": 2, "york": 3, "new york city": 4}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	tokens := []string{"new", "york", "city", "is", "new", "york"}
+	ngrams := lexicon.NGrams(tokens, 3, " ")
+	expected := []NGram{
+		{"new", 1, 2},
+		{"new york", 2, 2},
+		{"new york city", 4, 1},
+		{"york", 3, 2},
+	}
+	if len(ngrams) != len(expected) {
+		t.FailNow()
+	}
+	for i := range expected {
+		if ngrams[i] != expected[i] {
+			t.FailNow()
+		}
+	}
+
+	// Empty tokens are not n-grams by themselves
+	ngrams = lexicon.NGrams([]string{"", "york", ""}, 2, " ")
+	if len(ngrams) != 1 || ngrams[0] != (NGram{"york", 3, 1}) {
+		t.FailNow()
+	}
+}
+
+func TestBlockSize(t *testing.T) {
+	dict := map[string]int32{}
+	for i := 0; i < 1000; i++ {
+		dict[fmt.Sprintf("%d", i*7919)] = int32(i)
+	}
+
+	lexicon, err := Build(dict, nil, WithBlockSize(0))
+	if err != nil || lexicon.blockSize != 64 {
+		t.FailNow()
+	}
+	for key, value := range dict {
+		if v, ok := lexicon.Get(key); !ok || v != value {
+			t.FailNow()
+		}
+	}
+	if _, ok := lexicon.Get("12z"); ok {
+		t.FailNow()
+	}
+
+	_, err = Build(map[string]int32{"abc": 1}, nil, WithBlockSize(64))
+	if err == nil {
+		t.FailNow()
+	}
+}
+
+func TestZeroAlloc(t *testing.T) {
+	dict, testData := prepareData(1000, 25)
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		s := InitialState()
+		for _, sample := range testData {
+			lexicon.Get(sample.key)
+			lexicon.Traverse(sample.key, &s)
+			s.Reset()
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("%f allocations in Get and Traverse", allocs)
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	base, err := Build(map[string]int32{"a": 1, "b": 2, "c": 3}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	overlay := NewOverlay(base)
+	overlay.Set("b", 20)
+	overlay.Set("d", 4)
+	overlay.Delete("c")
+	overlay.Delete("e")
+	if v, ok := overlay.Get("b"); !ok || v != 20 {
+		t.FailNow()
+	}
+	if _, ok := overlay.Get("c"); ok {
+		t.FailNow()
+	}
+	if overlay.DeltaSize() != 3 {
+		t.FailNow()
+	}
+
+	lexicon, err := overlay.Compact(nil)
+	if err != nil {
+		t.FailNow()
+	}
+	expected := []Entry{{"a", 1}, {"b", 20}, {"d", 4}}
+	entries := lexicon.Complete("", 0)
+	if len(entries) != len(expected) {
+		t.FailNow()
+	}
+	for i := range expected {
+		if entries[i] != expected[i] {
+			t.FailNow()
+		}
+	}
+
+	// Keys are normalized like the base
+	base, err = Build(map[string]int32{"foo": 1, "bar": 2}, nil, WithTransforms(LowerASCII()))
+	if err != nil {
+		t.FailNow()
+	}
+	overlay = NewOverlay(base)
+	if overlay.Set("Foo", 10) != nil || overlay.Delete("BAR") != nil || overlay.DeltaSize() != 2 {
+		t.FailNow()
+	}
+	if v, ok := overlay.Get("foo"); !ok || v != 10 {
+		t.FailNow()
+	}
+	if v, ok := overlay.Get("FOO"); !ok || v != 10 {
+		t.FailNow()
+	}
+	if _, ok := overlay.Get("bar"); ok {
+		t.FailNow()
+	}
+}
+
+func TestChain(t *testing.T) {
+	user, _ := Build(map[string]int32{"apple": 10}, nil)
+	base, _ := Build(map[string]int32{"apple": 1, "apply": 2, "banana": 3}, nil)
+	chain := NewChain([]*Lexicon{user, base})
+
+	if v, ok := chain.Get("apple"); !ok || v != 10 {
+		t.FailNow()
+	}
+	if v, ok := chain.Get("banana"); !ok || v != 3 {
+		t.FailNow()
+	}
+
+	entries := chain.Complete("app", 0)
+	if len(entries) != 2 || entries[0] != (Entry{"apple", 10}) || entries[1] != (Entry{"apply", 2}) {
+		t.FailNow()
+	}
+}
+
+func TestSingleKey(t *testing.T) {
+	lexicon, err := Build(map[string]int32{"a": 1}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	data, err := lexicon.MarshalBinary()
+	if err != nil {
+		t.FailNow()
+	}
+	read := &Lexicon{}
+	if read.UnmarshalBinary(data) != nil || read.Verify() != nil {
+		t.FailNow()
+	}
+	if v, ok := read.Get("a"); !ok || v != 1 {
+		t.FailNow()
+	}
+	for _, key := range []string{"", "b", "ab"} {
+		if _, ok := read.Get(key); ok {
+			t.FailNow()
+		}
+	}
+}
+
+func TestReloader(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "lexicon.reimu")
+	lexicon, _ := Build(map[string]int32{"a": 1}, nil)
+	if lexicon.Save(filename) != nil {
+		t.FailNow()
+	}
+
+	reloader, err := NewReloader(filename, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	if reloaded, _ := reloader.Reload(); reloaded {
+		t.FailNow()
+	}
+
+	lexicon, _ = Build(map[string]int32{"a": 1, "bb": 2}, nil)
+	if lexicon.Save(filename) != nil {
+		t.FailNow()
+	}
+	if reloaded, err := reloader.Reload(); !reloaded || err != nil {
+		t.FailNow()
+	}
+	if v, ok := reloader.Lexicon().Get("bb"); !ok || v != 2 {
+		t.FailNow()
+	}
+}
+
+func TestFloat32(t *testing.T) {
+	dict := map[string]float32{"the": -2.5, "of": -3.25, "lexicon": -12.125}
+	lexicon, err := BuildFloat32(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = lexicon.Save("lexicon.reimu")
+	if err != nil {
+		t.FailNow()
+	}
+	lexicon, err = Read("lexicon.reimu")
+	if err != nil || !lexicon.HasFloatValues() {
+		t.FailNow()
+	}
+	for key, value := range dict {
+		if v, ok := lexicon.GetFloat(key); !ok || v != value {
+			t.FailNow()
+		}
+	}
+}
+
+func TestColumns(t *testing.T) {
+	dict := map[string][]int32{"run": {1, 500}, "ran": {2, 120}, "running": {3, 80}}
+	lexicon, err := BuildColumns([]string{"pos", "freq"}, dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = lexicon.Save("lexicon.reimu")
+	if err != nil {
+		t.FailNow()
+	}
+	lexicon, err = Read("lexicon.reimu")
+	if err != nil || len(lexicon.Columns()) != 2 {
+		t.FailNow()
+	}
+	for key, values := range dict {
+		row, ok := lexicon.GetRow(key)
+		if !ok || row.Len() != 2 || row.Column(0) != values[0] {
+			t.FailNow()
+		}
+		if freq, ok := row.Int("freq"); !ok || freq != values[1] {
+			t.FailNow()
+		}
+	}
+
+	// Fewer rows in table than the indexes in trie
+	for i := range lexicon.columns.values {
+		lexicon.columns.values[i] = lexicon.columns.values[i][:1]
+	}
+	data, _ := lexicon.MarshalBinary()
+	if err = lexicon.UnmarshalBinary(data); !errors.Is(err, ErrCorrupted) {
+		t.FailNow()
+	}
+
+	// Huge number of columns without rows
+	payload := make([]byte, 12)
+	binary.LittleEndian.PutUint32(payload, 1<<30)
+	if _, err = readColumnTable(payload); !errors.Is(err, ErrCorrupted) {
+		t.FailNow()
+	}
+}
+
+func TestRows(t *testing.T) {
+	data := `pos,word,reading,freq
+noun,東京,とうきょう,500
+verb,"run, ran",ラン,120
+`
+	columns, dict, err := ReadCSVRows(strings.NewReader(data), "word")
+	if err != nil || strings.Join(columns, ",") != "pos,reading,freq" {
+		t.FailNow()
+	}
+	lexicon, err := BuildRows(columns, dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	payload, _ := lexicon.MarshalBinary()
+	lexicon = &Lexicon{}
+	if lexicon.UnmarshalBinary(payload) != nil || len(lexicon.Columns()) != 3 {
+		t.FailNow()
+	}
+
+	row, ok := lexicon.GetRow("run, ran")
+	if !ok || row.Len() != 3 || row.Field(0) != "verb" || row.Column(2) != 120 {
+		t.FailNow()
+	}
+	if reading, ok := row.String("reading"); !ok || reading != "ラン" {
+		t.FailNow()
+	}
+	if freq, ok := row.Int("freq"); !ok || freq != 120 {
+		t.FailNow()
+	}
+	if _, ok := row.Int("pos"); ok {
+		t.FailNow()
+	}
+	if _, ok := lexicon.GetRow("run"); ok {
+		t.FailNow()
+	}
+
+	lexicon.rows.offsets = lexicon.rows.offsets[:2]
+	payload, _ = lexicon.MarshalBinary()
+	if err = lexicon.UnmarshalBinary(payload); !errors.Is(err, ErrCorrupted) {
+		t.FailNow()
+	}
+
+	_, _, err = ReadCSVRows(strings.NewReader(data+"noun,東京,x,1\n"), "word")
+	if err == nil {
+		t.FailNow()
+	}
+	_, err = BuildRows(columns, map[string][]string{"a": {"x"}}, nil)
+	if !errors.Is(err, ErrColumnCount) {
+		t.FailNow()
+	}
+}
+
+func TestSchema(t *testing.T) {
+	schema := Schema{
+		{Name: "pos", Type: ColumnEnum},
+		{Name: "freq", Type: ColumnInt32},
+		{Name: "score", Type: ColumnFloat32},
+		{Name: "lemma", Type: ColumnString},
+	}
+	dict := map[string][]string{
+		"ran":     {"verb", "120", "0.5", "run"},
+		"runs":    {"verb", "80", "-1.25", "run"},
+		"running": {"noun", "-3", "2", ""},
+	}
+	lexicon, err := BuildSchema(schema, dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	payload, _ := lexicon.MarshalBinary()
+	lexicon = &Lexicon{}
+	if lexicon.UnmarshalBinary(payload) != nil {
+		t.FailNow()
+	}
+	read := lexicon.Schema()
+	if len(read) != 4 || read[0].Type != ColumnEnum || strings.Join(read[0].Values, ",") != "noun,verb" {
+		t.FailNow()
+	}
+
+	pos, err := lexicon.EnumField("pos")
+	if err != nil {
+		t.FailNow()
+	}
+	freq, _ := lexicon.Int32Field("freq")
+	score, _ := lexicon.Float32Field("score")
+	lemma, _ := lexicon.StringField("lemma")
+	if v, ok := pos.Get("running"); !ok || v != "noun" {
+		t.FailNow()
+	}
+	if i, ok := pos.Index("ran"); !ok || i != 1 {
+		t.FailNow()
+	}
+	if v, ok := freq.Get("running"); !ok || v != -3 {
+		t.FailNow()
+	}
+	if v, ok := score.Get("runs"); !ok || v != -1.25 {
+		t.FailNow()
+	}
+	if v, ok := lemma.Get("ran"); !ok || v != "run" {
+		t.FailNow()
+	}
+	if _, ok := lemma.Get("run"); ok {
+		t.FailNow()
+	}
+	row, _ := lexicon.GetRow("runs")
+	if row.Field(0) != "verb" || row.Field(2) != "-1.25" {
+		t.FailNow()
+	}
+
+	if _, err = lexicon.Int32Field("lemma"); !errors.Is(err, ErrColumnType) {
+		t.FailNow()
+	}
+	if _, err = lexicon.Int32Field("count"); !errors.Is(err, ErrUnknownColumn) {
+		t.FailNow()
+	}
+	schema[0].Values = []string{"verb"}
+	if _, err = BuildSchema(schema, dict, nil); !errors.Is(err, ErrColumnValue) {
+		t.FailNow()
+	}
+	dict["ran"][1] = "x"
+	if _, err = BuildSchema(schema[1:], map[string][]string{"ran": dict["ran"][1:]}, nil); !errors.Is(err, ErrColumnValue) {
+		t.FailNow()
+	}
+}
+
+func TestStrings(t *testing.T) {
+	dict := map[string]string{"ran": "run", "running": "run", "went": "go", "empty": ""}
+	lexicon, err := BuildStrings(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = lexicon.Save("lexicon.reimu")
+	if err != nil {
+		t.FailNow()
+	}
+	lexicon, err = Read("lexicon.reimu")
+	if err != nil {
+		t.FailNow()
+	}
+	for key, value := range dict {
+		if s, ok := lexicon.GetString(key); !ok || s != value {
+			t.FailNow()
+		}
+	}
+	if _, ok := lexicon.GetString("go"); ok {
+		t.FailNow()
+	}
+
+	// Fewer strings in table than the indexes in trie
+	data, _ := lexicon.MarshalBinary()
+	tampered := tamperSection(data, sectionStrings, func(payload []byte) {
+		binary.LittleEndian.PutUint32(payload, 2)
+	})
+	if err = lexicon.UnmarshalBinary(tampered); !errors.Is(err, ErrCorrupted) {
+		t.FailNow()
+	}
+}
+
+func TestPayloads(t *testing.T) {
+	type entry struct {
+		Pinyin string
+		Gloss  []string
+	}
+	dict := map[string]interface{}{
+		"中国": entry{"zhong1 guo2", []string{"China"}},
+		"你好": entry{"ni3 hao3", []string{"hello", "hi"}},
+	}
+	lexicon, err := BuildPayloads(dict, JSONCodec{}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	var e entry
+	ok, err := lexicon.GetPayload("你好", JSONCodec{}, &e)
+	if !ok || err != nil || e.Pinyin != "ni3 hao3" || len(e.Gloss) != 2 {
+		t.FailNow()
+	}
+	ok, _ = lexicon.GetPayload("你", JSONCodec{}, &e)
+	if ok {
+		t.FailNow()
+	}
+}
+
+func TestTransforms(t *testing.T) {
+	var table [256]byte
+	for i := range table {
+		table[i] = byte(i)
+	}
+	table['_'] = '-'
+
+	dict := map[string]int32{" Hello ": 1, "WORLD": 2, "foo_bar": 3}
+	lexicon, err := Build(dict, nil, WithTransforms(TrimSpace(), LowerASCII(), ByteMap(table)))
+	if err != nil {
+		t.FailNow()
+	}
+
+	err = lexicon.Save("lexicon.reimu")
+	if err != nil {
+		t.FailNow()
+	}
+	lexicon, err = Read("lexicon.reimu")
+	if err != nil || len(lexicon.Transforms()) != 3 {
+		t.FailNow()
+	}
+	if v, ok := lexicon.Get("hello"); !ok || v != 1 {
+		t.FailNow()
+	}
+	if v, ok := lexicon.Get("World "); !ok || v != 2 {
+		t.FailNow()
+	}
+	if v, ok := lexicon.Get("FOO_BAR"); !ok || v != 3 {
+		t.FailNow()
+	}
+	if lexicon.Normalize(" FOO_BAR") != "foo-bar" {
+		t.FailNow()
+	}
+}
+
+func TestFoldCase(t *testing.T) {
+	dict := map[string]int32{"ΣΊΣΥΦΟΣ": 1, "Москва": 2, "ISTANBUL": 3}
+	lexicon, err := Build(dict, nil, WithTransforms(FoldCase("")))
+	if err != nil {
+		t.FailNow()
+	}
+	data, _ := lexicon.MarshalBinary()
+	read := &Lexicon{}
+	if read.UnmarshalBinary(data) != nil {
+		t.FailNow()
+	}
+	for key, value := range map[string]int32{"σίσυφος": 1, "σίσυφοσ": 1, "МОСКВА": 2, "istanbul": 3} {
+		if v, ok := read.Get(key); !ok || v != value {
+			t.FailNow()
+		}
+	}
+	if _, ok := read.Get("ıstanbul"); ok {
+		t.FailNow()
+	}
+
+	turkish, err := Build(dict, nil, WithTransforms(FoldCase("tr")))
+	if err != nil {
+		t.FailNow()
+	}
+	data, _ = turkish.MarshalBinary()
+	if read.UnmarshalBinary(data) != nil || read.Transforms()[0].Apply("İI") != "iı" {
+		t.FailNow()
+	}
+	if _, ok := read.Get("ıstanbul"); !ok {
+		t.FailNow()
+	}
+	if _, ok := read.Get("istanbul"); ok {
+		t.FailNow()
+	}
+}
+
+func TestWalkPrefix(t *testing.T) {
+	dict := map[string]int32{"a": 1, "ab": 2, "abc": 3, "abd": 4, "b": 5}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	keys := []string{}
+	lexicon.WalkPrefix("ab", func(key string, value int32) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	if len(keys) != 2 || keys[0] != "ab" || keys[1] != "abc" {
+		t.FailNow()
+	}
+}
+
+func TestEqual(t *testing.T) {
+	dict := map[string]int32{"a": 1, "ab": 2, "abc": 3, "bcd": 4}
+	lex1, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	lex2, err := Build(dict, nil, WithBlockSize(0))
+	if err != nil {
+		t.FailNow()
+	}
+	if lex1.NumSlots() == lex2.NumSlots() || !lex1.Equal(lex2) || !lex2.Equal(lex1) {
+		t.FailNow()
+	}
+
+	dict["bcd"] = 5
+	lex3, err := Build(dict, nil)
+	if err != nil || lex1.Equal(lex3) {
+		t.FailNow()
+	}
+	if lex1.ContentHash() != lex2.ContentHash() ||
+		lex1.ContentHash() == lex3.ContentHash() {
+		t.FailNow()
+	}
+	delete(dict, "bcd")
+	lex4, err := Build(dict, nil)
+	if err != nil || lex1.Equal(lex4) || lex4.Equal(lex1) {
+		t.FailNow()
+	}
+}
+
+func TestErrors(t *testing.T) {
+	_, err := Build(map[string]int32{"a\x00b": 1}, nil)
+	var keyErr *KeyError
+	if !errors.Is(err, ErrKeyContainsNUL) || !errors.As(err, &keyErr) || keyErr.Key != "a\x00b" {
+		t.FailNow()
+	}
+	_, err = Build(map[string]int32{"": 1}, nil)
+	if !errors.Is(err, ErrEmptyKey) {
+		t.FailNow()
+	}
+	_, err = Build(map[string]int32{"a": 1}, nil, WithBlockSize(3))
+	if !errors.Is(err, ErrInvalidBlockSize) {
+		t.FailNow()
+	}
+
+	filename := filepath.Join(t.TempDir(), "lexicon.reimu")
+	for header, expected := range map[string]error{
+		"NOT_A_LEXICON": ErrCorruptHeader,
+		"REIMU_Lex.v9":  ErrUnsupportedVersion,
+	} {
+		if os.WriteFile(filename, []byte(header), 0644) != nil {
+			t.FailNow()
+		}
+		if _, err = Read(filename); !errors.Is(err, expected) {
+			t.FailNow()
+		}
+	}
+
+	// Sizes larger than the file, or the memory limit
+	huge := append([]byte(Header), 0, 0, 0, 0, 0xff, 0xff, 0xff, 0x7f, 0, 0, 0, 0, 0, 0, 0, 0)
+	if os.WriteFile(filename, huge, 0644) != nil {
+		t.FailNow()
+	}
+	if _, err = Read(filename); !errors.Is(err, ErrCorrupted) {
+		t.FailNow()
+	}
+	lexicon, err := Build(map[string]int32{"a": 1, "bc": 2}, nil)
+	if err != nil || lexicon.Save(filename) != nil {
+		t.FailNow()
+	}
+	if _, err = Read(filename, WithMemoryLimit(64)); !errors.Is(err, ErrMemoryLimit) {
+		t.FailNow()
+	}
+	if _, err = Read(filename, WithMemoryLimit(1<<20)); err != nil {
+		t.FailNow()
+	}
+
+	// Checksum
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.FailNow()
+	}
+	data[len(Header)+20] ^= 1
+	if os.WriteFile(filename, data, 0644) != nil {
+		t.FailNow()
+	}
+	if _, err = Read(filename); !errors.Is(err, ErrChecksum) {
+		t.FailNow()
+	}
+
+	// Suffix links out of suffixes, negative values are not links
+	linked, err := Build(map[string]int32{"a": -5, "ab": -6, "xyz": 3}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	data, err = linked.MarshalBinary()
+	if err != nil || (&Lexicon{}).UnmarshalBinary(data) != nil {
+		t.FailNow()
+	}
+	s := InitialState()
+	linked.Traverse("x", &s)
+	if linked.slots[s.state].Base >= 0 {
+		t.FailNow()
+	}
+	linked.slots[s.state].Base = -int32(len(linked.suffixIndex)) - 1
+	data, err = linked.MarshalBinary()
+	if err != nil {
+		t.FailNow()
+	}
+	if err = (&Lexicon{}).UnmarshalBinary(data); !errors.Is(err, ErrCorrupted) {
+		t.FailNow()
+	}
+
+	// Structure of lexicon
+	if lexicon.Verify() != nil {
+		t.FailNow()
+	}
+	lexicon.suffix[len(lexicon.suffix)-1] = 'x'
+	if !errors.Is(lexicon.Verify(), ErrCorrupted) {
+		t.FailNow()
+	}
+}
+
+func TestReadTSV(t *testing.T) {
+	dict, err := ReadTSV(strings.NewReader("a\t1\r\n\nb c\t-2\n"))
+	if err != nil || len(dict) != 2 || dict["a"] != 1 || dict["b c"] != -2 {
+		t.FailNow()
+	}
+	for _, bad := range []string{"a\n", "a\tx\n", "a\t1\na\t2\n"} {
+		if _, err = ReadTSV(strings.NewReader(bad)); err == nil {
+			t.FailNow()
+		}
+	}
+}
+
+func TestSegment(t *testing.T) {
+	dict := map[string]int32{"北京": 1, "北京大学": 2, "大学生": 3, "学生": 4}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	tokens := lexicon.Segment("北京大学生 go1.2 活动")
+	expected := []string{"北京大学", "生", "go1", ".", "2", "活", "动"}
+	if fmt.Sprint(tokens) != fmt.Sprint(expected) {
+		t.FailNow()
+	}
+
+	// SplitFunc gets the same tokens even if input is read byte by byte
+	text := strings.Repeat("北京大学生 go1.2 活动\n", 100)
+	for _, r := range []io.Reader{strings.NewReader(text), iotest.OneByteReader(strings.NewReader(text))} {
+		scanner := bufio.NewScanner(r)
+		scanner.Split(lexicon.SplitFunc())
+		tokens = []string{}
+		for scanner.Scan() {
+			tokens = append(tokens, scanner.Text())
+		}
+		if scanner.Err() != nil || fmt.Sprint(tokens) != fmt.Sprint(lexicon.Segment(text)) {
+			t.FailNow()
+		}
+	}
+}
+
+func TestConvert(t *testing.T) {
+	dict := map[string]int32{"a": 1, "ab": 2, "b c": 3, "bcd": 4}
+	lexicon, err := Build(dict, nil, WithBlockSize(0))
+	if err != nil {
+		t.FailNow()
+	}
+
+	// Round trip of TSV
+	buf := &strings.Builder{}
+	if lexicon.WriteTSV(buf) != nil || buf.String() != "a\t1\nab\t2\nb c\t3\nbcd\t4\n" {
+		t.FailNow()
+	}
+
+	// Round trip of front-coded text
+	buf.Reset()
+	if lexicon.WriteFrontCoded(buf) != nil || buf.String() != "0\ta\t1\n1\tb\t2\n0\tb c\t3\n1\tcd\t4\n" {
+		t.FailNow()
+	}
+	dict2, err := ReadFrontCoded(strings.NewReader(buf.String()))
+	if err != nil || fmt.Sprint(dict2) != fmt.Sprint(dict) {
+		t.FailNow()
+	}
+
+	// Version 1 with slots padded for any byte
+	filename := filepath.Join(t.TempDir(), "lexicon.reimu")
+	fd, err := os.Create(filename)
+	if err != nil || lexicon.WriteV1(fd) != nil || fd.Close() != nil {
+		t.FailNow()
+	}
+	lexiconV1, err := Read(filename)
+	if err != nil || !lexiconV1.Equal(lexicon) {
+		t.FailNow()
+	}
+	for _, slot := range lexiconV1.slots {
+		if slot.Check >= 0 && slot.Base >= 0 && int(slot.Base|0xff) >= len(lexiconV1.slots) {
+			t.FailNow()
+		}
+	}
+}
+
+func TestSuffixCompression(t *testing.T) {
+	dict := map[string]int32{"a": 1, "abcdef": 2, "bcd": 3, "xyz": 4}
+	lexicon, err := Build(dict, nil, WithSuffixCompression(false))
+	if err != nil || len(lexicon.suffixIndex) != 0 || lexicon.Verify() != nil {
+		t.FailNow()
+	}
+	for key, value := range dict {
+		if v, ok := lexicon.Get(key); !ok || v != value {
+			t.FailNow()
+		}
+	}
+	if _, ok := lexicon.Get("abc"); ok {
+		t.FailNow()
+	}
+
+	// Only "cdef" of "abcdef" is kept as suffix
+	lexicon, err = Build(dict, nil, WithSuffixLength(4, 4))
+	if err != nil || len(lexicon.suffixIndex) != 1 || lexicon.Verify() != nil {
+		t.FailNow()
+	}
+	if string(lexicon.suffix) != "cdef\x00" || lexicon.MustGet("abcdef") != 2 {
+		t.FailNow()
+	}
+}
+
+func TestSplit(t *testing.T) {
+	dict := map[string]int32{"a": 1, "abcdef": 2, "bcd": 3}
+	lexicon, err := BuildStrings(map[string]string{"a": "x", "abcdef": "y"}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	dir := t.TempDir()
+	if lexicon.SaveSplit(dir) != nil {
+		t.FailNow()
+	}
+	lexicon2, err := ReadSplit(dir)
+	if err != nil || !lexicon2.Equal(lexicon) {
+		t.FailNow()
+	}
+	if s, ok := lexicon2.GetString("abcdef"); !ok || s != "y" {
+		t.FailNow()
+	}
+
+	// Corrupted suffix file
+	lexicon, err = Build(dict, nil)
+	if err != nil || lexicon.SaveSplit(dir) != nil {
+		t.FailNow()
+	}
+	filename := filepath.Join(dir, "suffix.bin")
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.FailNow()
+	}
+	data[len(data)-2] ^= 1
+	if os.WriteFile(filename, data, 0644) != nil {
+		t.FailNow()
+	}
+	if _, err = ReadSplit(dir); !errors.Is(err, ErrChecksum) {
+		t.FailNow()
+	}
+}
+
+func TestReadZip(t *testing.T) {
+	lexicon, err := Build(map[string]int32{"a": 1, "bcd": 2}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	filename := filepath.Join(t.TempDir(), "lexicons.zip")
+	fd, err := os.Create(filename)
+	if err != nil {
+		t.FailNow()
+	}
+	zw := zip.NewWriter(fd)
+	w, err := zw.Create("dict/a.reimu")
+	if err != nil || lexicon.Write(w) != nil || zw.Close() != nil || fd.Close() != nil {
+		t.FailNow()
+	}
+
+	lexicon2, err := ReadZip(filename, "dict/a.reimu")
+	if err != nil || !lexicon2.Equal(lexicon) {
+		t.FailNow()
+	}
+	if _, err = ReadZip(filename, "b.reimu"); !errors.Is(err, fs.ErrNotExist) {
+		t.FailNow()
+	}
+}
+
+func TestDiskLexicon(t *testing.T) {
+	dict := map[string]int32{"a": 1, "ab": 2, "abcdef": 3, "bcd": 4}
+	lexicon, err := Build(dict, nil, WithTransforms(LowerASCII()))
+	if err != nil {
+		t.FailNow()
+	}
+	buf := &bytes.Buffer{}
+	if lexicon.Write(buf) != nil {
+		t.FailNow()
+	}
+
+	// Serves the file with range requests
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "lexicon.reimu", time.Time{}, bytes.NewReader(buf.Bytes()))
+	}))
+	defer server.Close()
+	remote, err := NewHTTPReaderAt(nil, server.URL)
+	if err != nil || remote.Size() != int64(buf.Len()) {
+		t.FailNow()
+	}
+
+	d, err := OpenDisk(NewCachedReaderAt(remote, remote.Size(), 64, 4), remote.Size())
+	if err != nil {
+		t.FailNow()
+	}
+	for key, value := range dict {
+		if v, ok, err := d.Get(key); err != nil || !ok || v != value {
+			t.FailNow()
+		}
+	}
+	if v, ok, err := d.Get("ABCDEF"); err != nil || !ok || v != 3 {
+		t.FailNow()
+	}
+	for _, key := range []string{"abc", "abcdefg", "b", "bc", "x", ""} {
+		if _, ok, err := d.Get(key); err != nil || ok {
+			t.FailNow()
+		}
+	}
+}
+
+func TestCachedReaderAt(t *testing.T) {
+	data := []byte(strings.Repeat(letters, 10))
+	r := bytes.NewReader(data)
+
+	// Non-positive block size and number of blocks are the defaults
+	c := NewCachedReaderAt(r, int64(len(data)), 0, -1)
+	if c.blockSize != defaultCachedBlockSize || c.maxBlocks != defaultCachedMaxBlocks {
+		t.FailNow()
+	}
+	p := make([]byte, 10)
+	if n, err := c.ReadAt(p, 100); n != 10 || err != nil || string(p) != string(data[100:110]) {
+		t.FailNow()
+	}
+
+	// Least recently used blocks are evicted
+	c = NewCachedReaderAt(r, int64(len(data)), 16, 2)
+	for off := int64(0); off < int64(len(data)); off += 7 {
+		if n, err := c.ReadAt(p[:1], off); n != 1 || err != nil || p[0] != data[off] {
+			t.FailNow()
+		}
+		if c.lru.Len() > 2 || len(c.blocks) != c.lru.Len() {
+			t.FailNow()
+		}
+	}
+	if n, err := c.ReadAt(p, int64(len(data))-5); n != 5 || err != io.EOF {
+		t.FailNow()
+	}
+}
+
+func TestDelta(t *testing.T) {
+	lexicon, err := Build(map[string]int32{"a": 1, "bc": 2, "def": 3}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	filename := filepath.Join(t.TempDir(), "lexicon.reimu")
+	if lexicon.Save(filename) != nil {
+		t.FailNow()
+	}
+
+	// Without delta file
+	o, err := Open(filename)
+	if err != nil || o.DeltaSize() != 0 {
+		t.FailNow()
+	}
+
+	// Appended by two writers
+	for _, change := range []func(dw *DeltaWriter) error{
+		func(dw *DeltaWriter) error { return dw.Set("bc", 5) },
+		func(dw *DeltaWriter) error { return dw.Delete("a") },
+	} {
+		dw, err := OpenDeltaWriter(filename + DeltaSuffix)
+		if err != nil || change(dw) != nil || dw.Set("xyz", 6) != nil || dw.Close() != nil {
+			t.FailNow()
+		}
+	}
+	o, err = Open(filename)
+	if err != nil {
+		t.FailNow()
+	}
+	expected := map[string]int32{"bc": 5, "def": 3, "xyz": 6}
+	for _, key := range []string{"a", "bc", "def", "xyz"} {
+		value, ok := o.Get(key)
+		if v, exists := expected[key]; ok != exists || (ok && value != v) {
+			t.FailNow()
+		}
+	}
+
+	// Truncated record
+	data, err := os.ReadFile(filename + DeltaSuffix)
+	if err != nil || os.WriteFile(filename+DeltaSuffix, data[:len(data)-1], 0644) != nil {
+		t.FailNow()
+	}
+	o, err = Open(filename)
+	if err != nil || o.DeltaSize() != 3 {
+		t.FailNow()
+	}
+	if v, ok := o.Get("bc"); !ok || v != 5 {
+		t.FailNow()
+	}
+
+	// The torn record is truncated before appending
+	dw, err := OpenDeltaWriter(filename + DeltaSuffix)
+	if err != nil || dw.Set("a", 7) != nil || dw.Close() != nil {
+		t.FailNow()
+	}
+	o, err = Open(filename)
+	if err != nil {
+		t.FailNow()
+	}
+	if v, ok := o.Get("a"); !ok || v != 7 {
+		t.FailNow()
+	}
+	if v, ok := o.Get("xyz"); !ok || v != 6 {
+		t.FailNow()
+	}
+
+	// A corrupted length is bounded by the file size before allocating
+	record := []byte{deltaSet, 0, 0, 0, 0x20, 'a', 'b', 'c'}
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	valid, err := readDeltaRecords(
+		bytes.NewReader(record),
+		int64(len(record)),
+		func(byte, string, int32) error { return nil })
+	runtime.ReadMemStats(&after)
+	if valid != 0 || err != ErrCorrupted || after.TotalAlloc-before.TotalAlloc > 1<<20 {
+		t.FailNow()
+	}
+
+	// Errors of applying records are returned
+	dw, err = OpenDeltaWriter(filename + DeltaSuffix)
+	if err != nil || dw.write(deltaSet, "", 8) != nil || dw.Close() != nil {
+		t.FailNow()
+	}
+	if _, err = Open(filename); !errors.Is(err, ErrEmptyKey) {
+		t.FailNow()
+	}
+}
+
+func TestWAL(t *testing.T) {
+	lexicon, err := Build(map[string]int32{"a": 1, "bc": 2}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	filename := filepath.Join(t.TempDir(), "lexicon.wal")
+
+	o := NewOverlay(lexicon)
+	if o.EnableWAL(filename, true) != nil || o.Set("xyz", 3) != nil || o.Delete("a") != nil {
+		t.FailNow()
+	}
+	if o.Set("uvw", 4) != nil || o.CloseWAL() != nil {
+		t.FailNow()
+	}
+
+	// A torn record at the end is discarded in replay
+	fd, err := os.OpenFile(filename, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.FailNow()
+	}
+	fd.Write([]byte{'S', 3, 0})
+	fd.Close()
+
+	o = NewOverlay(lexicon)
+	if o.EnableWAL(filename, false) != nil || o.DeltaSize() != 3 {
+		t.FailNow()
+	}
+	if _, ok := o.Get("a"); ok || o.Set("a", 5) != nil || o.CloseWAL() != nil {
+		t.FailNow()
+	}
+	o = NewOverlay(lexicon)
+	if o.EnableWAL(filename, false) != nil {
+		t.FailNow()
+	}
+	if value, ok := o.Get("a"); !ok || value != 5 {
+		t.FailNow()
+	}
+
+	// Reset after compaction
+	if o.ResetWAL() != nil || o.CloseWAL() != nil {
+		t.FailNow()
+	}
+	o = NewOverlay(lexicon)
+	if o.EnableWAL(filename, false) != nil || o.DeltaSize() != 0 {
+		t.FailNow()
+	}
+	o.CloseWAL()
+}
+
+func TestReadHunspell(t *testing.T) {
+	aff := `SET UTF-8
+NEEDAFFIX X
+
+PFX U Y 1
+PFX U 0 un .
+
+SFX S Y 2
+SFX S y ies [^aeiou]y
+SFX S 0 s [^y]
+`
+	dic := "4\ndo/U\ntry/SU po:verb\nhouse/S\nflurr/XS\n"
+
+	dict, stems, err := ReadHunspell(strings.NewReader(dic), strings.NewReader(aff))
+	if err != nil || len(stems) != 4 {
+		t.FailNow()
+	}
+	expected := map[string]int32{
+		"do": 0, "undo": 0,
+		"try": 1, "tries": 1, "untry": 1, "untries": 1,
+		"house": 2, "houses": 2,
+		"flurrs": 3,
+	}
+	if fmt.Sprint(dict) != fmt.Sprint(expected) {
+		t.FailNow()
+	}
+
+	// Stems only
+	dict, _, err = ReadHunspell(strings.NewReader(dic), nil)
+	if err != nil || len(dict) != 4 || dict["flurr"] != 3 {
+		t.FailNow()
+	}
+}
+
+func TestReadMeCabCSV(t *testing.T) {
+	data := `東京,1293,1293,3003,名詞,固有名詞,地域,一般,*,*,東京,トウキョウ,トーキョー
+行く,992,992,8409,動詞,自立,*,*,五段・カ行促音便,基本形,行く,イク,イク
+行く,993,993,9000,動詞,自立,*,*,五段・カ行促音便,基本形,行く,ユク,ユク
+",",5,5,100,記号,読点,*,*,*,*,",",、,、
+`
+	dict, err := ReadMeCabCSV(strings.NewReader(data), MeCabCost)
+	if err != nil || len(dict) != 3 || dict["行く"] != 8409 || dict[","] != 100 {
+		t.FailNow()
+	}
+	readings, err := ReadMeCabCSVStrings(strings.NewReader(data), 11)
+	if err != nil || readings["東京"] != "トウキョウ" || readings["行く"] != "イク" {
+		t.FailNow()
+	}
+	if _, err = ReadMeCabCSV(strings.NewReader(data), 4); err == nil {
+		t.FailNow()
+	}
+}
+
+func TestReadJieba(t *testing.T) {
+	data := "\ufeff北京 34488 ns\n大学生 12 n\n云计算\n大学生 5\n"
+	freqs, tags, err := ReadJieba(strings.NewReader(data))
+	if err != nil || len(freqs) != 3 || freqs["北京"] != 34488 || freqs["云计算"] != 0 {
+		t.FailNow()
+	}
+	if freqs["大学生"] != 5 || len(tags) != 1 || tags["北京"] != "ns" {
+		t.FailNow()
+	}
+	if _, _, err = ReadJieba(strings.NewReader("北京 x ns\n")); err == nil {
+		t.FailNow()
+	}
+}
+
+// stubDB is an in-memory database for testing ReadSQL and ExportSQL. It
+// keeps the statements executed and the rows inserted into the only table
+type stubDB struct {
+	mutex     sync.Mutex
+	queries   []string
+	rows      [][]driver.Value
+	committed bool
+}
+
+func (db *stubDB) Connect(context.Context) (driver.Conn, error) { return stubConn{db}, nil }
+func (db *stubDB) Driver() driver.Driver                        { return nil }
+
+type stubConn struct {
+	db *stubDB
+}
+
+func (c stubConn) Prepare(query string) (driver.Stmt, error) { return stubStmt{c.db, query}, nil }
+func (c stubConn) Close() error                              { return nil }
+func (c stubConn) Begin() (driver.Tx, error)                 { return stubTx{c.db}, nil }
+
+type stubTx struct {
+	db *stubDB
+}
+
+func (tx stubTx) Commit() error {
+	tx.db.mutex.Lock()
+	defer tx.db.mutex.Unlock()
+	tx.db.committed = true
+	return nil
+}
+
+func (tx stubTx) Rollback() error { return nil }
+
+type stubStmt struct {
+	db    *stubDB
+	query string
+}
+
+func (s stubStmt) Close() error  { return nil }
+func (s stubStmt) NumInput() int { return strings.Count(s.query, "?") }
+
+func (s stubStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mutex.Lock()
+	defer s.db.mutex.Unlock()
+	s.db.queries = append(s.db.queries, s.query)
+	if strings.HasPrefix(s.query, "INSERT") {
+		s.db.rows = append(s.db.rows, args)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s stubStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mutex.Lock()
+	defer s.db.mutex.Unlock()
+	s.db.queries = append(s.db.queries, s.query)
+	return &stubRows{rows: s.db.rows}, nil
+}
+
+type stubRows struct {
+	rows [][]driver.Value
+}
+
+func (r *stubRows) Columns() []string { return []string{"key", "value"} }
+func (r *stubRows) Close() error      { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestSQL(t *testing.T) {
+	dict := map[string]int32{"a": 1, "bc": 2, "中文": 3}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	stub := &stubDB{}
+	db := sql.OpenDB(stub)
+	defer db.Close()
+	if lexicon.ExportSQL(db, `my"table`) != nil || !stub.committed || len(stub.rows) != 3 {
+		t.FailNow()
+	}
+	if stub.queries[0] != `CREATE TABLE "my""table" (key TEXT PRIMARY KEY, value INTEGER NOT NULL)` {
+		t.FailNow()
+	}
+
+	read, err := ReadSQL(db, `my"table`, "key", `val"ue`)
+	if err != nil || !reflect.DeepEqual(read, dict) {
+		t.FailNow()
+	}
+	if stub.queries[len(stub.queries)-1] != `SELECT "key", "val""ue" FROM "my""table"` {
+		t.FailNow()
+	}
+}
+
+func TestCedict(t *testing.T) {
+	data := `# CC-CEDICT
+中國 中国 [Zhong1 guo2] /China/Middle Kingdom/
+行 行 [xing2] /to walk/to go/
+行 行 [hang2] /row/line/
+`
+	lexicon, err := BuildCedict(strings.NewReader(data), CedictSimplified|CedictTraditional, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	entries := []CedictEntry{}
+	ok, err := lexicon.GetPayload("中國", JSONCodec{}, &entries)
+	if !ok || err != nil || len(entries) != 1 || entries[0].Simplified != "中国" {
+		t.FailNow()
+	}
+	if entries[0].Pinyin != "Zhong1 guo2" || len(entries[0].Definitions) != 2 {
+		t.FailNow()
+	}
+	ok, err = lexicon.GetPayload("行", JSONCodec{}, &entries)
+	if !ok || err != nil || len(entries) != 2 || entries[1].Pinyin != "hang2" {
+		t.FailNow()
+	}
+
+	dict, err := ReadCedict(strings.NewReader(data), CedictTraditional)
+	if err != nil || len(dict) != 2 || len(dict["中国"]) != 0 {
+		t.FailNow()
+	}
+}
+
+func TestDartsClone(t *testing.T) {
+	// Units of "a" = 1 and "ab" = 2: root at 0, 'a' at 353 and 'b' at 771,
+	// with their leaf units at 865 and 1795
+	units := make([]uint32, 2048)
+	units[0] = 256 << 10
+	units[353] = 512<<10 | 1<<8 | 'a'
+	units[865] = 1<<31 | 1
+	units[771] = 1024<<10 | 1<<8 | 'b'
+	units[1795] = 1<<31 | 2
+	buf := &bytes.Buffer{}
+	if binary.Write(buf, binary.LittleEndian, units) != nil {
+		t.FailNow()
+	}
+
+	d, err := readDartsClone(buf, int64(buf.Len()))
+	if err != nil {
+		t.FailNow()
+	}
+	if value, ok := d.Get("ab"); !ok || value != 2 {
+		t.FailNow()
+	}
+	if _, ok := d.Get("b"); ok {
+		t.FailNow()
+	}
+
+	lexicon, err := d.ToLexicon(nil)
+	if err != nil || fmt.Sprint(lexicon.Complete("", 0)) != "[{a 1} {ab 2}]" {
+		t.FailNow()
+	}
+}
+
+func TestMarshalBinary(t *testing.T) {
+	lexicon, err := BuildStrings(map[string]string{"a": "x", "bcd": "y"}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	var _ encoding.BinaryMarshaler = lexicon
+
+	data, err := lexicon.MarshalBinary()
+	if err != nil {
+		t.FailNow()
+	}
+	lexicon2 := &Lexicon{}
+	if lexicon2.UnmarshalBinary(data) != nil || !lexicon2.Equal(lexicon) {
+		t.FailNow()
+	}
+	if s, ok := lexicon2.GetString("bcd"); !ok || s != "y" {
+		t.FailNow()
+	}
+	if lexicon2.UnmarshalBinary(data[:len(data)-1]) == nil {
+		t.FailNow()
+	}
+}
+
+func TestGob(t *testing.T) {
+	type state struct {
+		Name    string
+		Lexicon *Lexicon
+	}
+	lexicon, err := Build(map[string]int32{"a": 1, "bcd": 2}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	buf := &bytes.Buffer{}
+	if gob.NewEncoder(buf).Encode(state{"dict", lexicon}) != nil {
+		t.FailNow()
+	}
+	decoded := state{}
+	if gob.NewDecoder(buf).Decode(&decoded) != nil {
+		t.FailNow()
+	}
+	if decoded.Name != "dict" || !decoded.Lexicon.Equal(lexicon) {
+		t.FailNow()
+	}
+}
+
+func TestPublishExpvar(t *testing.T) {
+	lexicon, err := Build(map[string]int32{"a": 1, "bcd": 2}, nil)
+	if err != nil || lexicon.PublishExpvar("lexicon_test") != nil {
+		t.FailNow()
+	}
+	if lexicon.PublishExpvar("lexicon_test") == nil {
+		t.FailNow()
+	}
+
+	lexicon.Get("a")
+	lexicon.Get("b")
+	lexicon.Get("bcd")
+	lexicon.Complete("b", 0)
+	lexicon.Equal(lexicon)
+
+	vars := expvar.Get("lexicon_test").(*expvar.Map)
+	for name, expected := range map[string]string{
+		"gets": "3", "hits": "2", "misses": "1", "prefix_searches": "1",
+	} {
+		if vars.Get(name).String() != expected {
+			t.FailNow()
+		}
+	}
+}
+
+// recordTracer records the names of ended spans
+type recordTracer struct {
+	spans []string
+}
+
+func (r *recordTracer) StartSpan(ctx context.Context, name string) (context.Context, func(error)) {
+	return ctx, func(error) { r.spans = append(r.spans, name) }
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &recordTracer{}
+	lexicon, err := Build(
+		map[string]int32{"a": 1, "bcd": 2},
+		nil,
+		WithTracer(context.Background(), tracer),
+		WithPhoneticIndex(PhoneticSoundex))
+	if err != nil {
+		t.FailNow()
+	}
+	values, found := lexicon.GetBatch(context.Background(), []string{"bcd", "b"})
+	if values[0] != 2 || !found[0] || found[1] {
+		t.FailNow()
+	}
+
+	expected := "[lexicon.Build.trie lexicon.Build.doubleArray lexicon.Build.phonetic lexicon.Build lexicon.GetBatch]"
+	if fmt.Sprint(tracer.spans) != expected {
+		t.FailNow()
+	}
+}
+
+func TestLogger(t *testing.T) {
+	logs := &bytes.Buffer{}
+	bar := &bytes.Buffer{}
+	_, err := Build(
+		map[string]int32{"a": 1, "bcd": 2},
+		NewProgressBar(bar),
+		WithLogger(log.New(logs, "", 0)))
+	if err != nil {
+		t.FailNow()
+	}
+	if !strings.Contains(logs.String(), "built 2 keys") {
+		t.FailNow()
+	}
+	if !strings.Contains(bar.String(), "] Done") {
+		t.FailNow()
+	}
+}
+
+func TestBuildEvents(t *testing.T) {
+	events := []BuildEvent{}
+	lexicon, err := Build(
+		map[string]int32{"a": 1, "bcd": 2, "bcefg": 3},
+		nil,
+		WithBuildEvents(func(e BuildEvent) { events = append(events, e) }),
+		WithPhoneticIndex(PhoneticSoundex))
+	if err != nil {
+		t.FailNow()
+	}
+
+	phases := []string{}
+	blocks := 0
+	var placed, suffix BuildEvent
+	for _, e := range events {
+		switch e.Kind {
+		case BuildPhaseStarted:
+			phases = append(phases, e.Phase)
+		case BuildBlockAllocated:
+			blocks++
+		case BuildNodesPlaced:
+			placed = e
+		case BuildSuffixWritten:
+			suffix = e
+		}
+	}
+	if fmt.Sprint(phases) != "[trie doubleArray phonetic]" || blocks == 0 {
+		t.FailNow()
+	}
+	if placed.Nodes == 0 || placed.Nodes != placed.TotalNodes {
+		t.FailNow()
+	}
+	if suffix.SuffixBytes == 0 || suffix.SuffixBytes != len(lexicon.suffix) {
+		t.FailNow()
+	}
+}
+
+func TestBuildReleasesTrie(t *testing.T) {
+	dict := map[string]int32{"abc": 1, "abd": 2, "b": 3, "bcdef": 4}
+	trie, err := buildTrie(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	child := trie.child('a')
+	lexicon, err := buildDoubleArray(trie, nil, dict, nil, newBuildOptions(nil))
+	if err != nil || trie.children != nil || child.children != nil {
+		t.FailNow()
+	}
+	for key, value := range dict {
+		if v, ok := lexicon.Get(key); !ok || v != value {
+			t.FailNow()
+		}
+	}
+}
+
+func TestPackedSuffix(t *testing.T) {
+	dict := map[string]int32{}
+	for i := 0; i < 1000; i++ {
+		dict[fmt.Sprintf("key%d-suffix", i)] = int32(i%5 - 2)
+	}
+	set, err := BuildSet([]string{"abc", "abd", "bcd"}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	for _, l := range []*Lexicon{lexicon, set} {
+		plain, packed := &bytes.Buffer{}, &bytes.Buffer{}
+		if l.Write(plain) != nil || l.Write(packed, WithPackedSuffix()) != nil {
+			t.FailNow()
+		}
+		if len(l.suffixIndex) > 100 && packed.Len() >= plain.Len()-len(l.suffixIndex)*5 {
+			t.FailNow()
+		}
+
+		read := &Lexicon{}
+		if read.UnmarshalBinary(packed.Bytes()) != nil || read.flags != l.flags {
+			t.FailNow()
+		}
+		data, _ := read.MarshalBinary()
+		if !bytes.Equal(data, plain.Bytes()) {
+			t.FailNow()
+		}
+		if _, err = OpenDisk(bytes.NewReader(packed.Bytes()), int64(packed.Len())); !errors.Is(err, ErrUnsupportedVersion) {
+			t.FailNow()
+		}
+		if read.UnmarshalBinary(packed.Bytes()[:packed.Len()-40]) == nil {
+			t.FailNow()
+		}
+	}
+
+	// Too many distinct values are stored as they are
+	suffixIndex := make([]int64, 70000)
+	suffixValue := make([]int32, 70000)
+	for i := range suffixIndex {
+		suffixIndex[i] = 1<<32 + int64(i*3)
+		suffixValue[i] = int32(i*7919 - 1000000)
+	}
+	index, values, err := unpackSuffix(packSuffix(suffixIndex, suffixValue, false), 70000, false)
+	if err != nil || index[69999] != suffixIndex[69999] || values[12345] != suffixValue[12345] {
+		t.FailNow()
+	}
+
+	// Unknown flags are from newer writers
+	data, _ := lexicon.MarshalBinary()
+	binary.LittleEndian.PutUint32(data[len(Header):], 1<<31)
+	if !errors.Is((&Lexicon{}).UnmarshalBinary(data), ErrUnsupportedVersion) {
+		t.FailNow()
+	}
+}
+
+func TestCompactEncoding(t *testing.T) {
+	dict := map[string]int32{}
+	for i := 0; i < 2000; i++ {
+		dict[fmt.Sprintf("%x/%d", i*7919, i)] = int32(i * 31)
+	}
+	lexicon, err := Build(dict, nil, WithBloomFilter(10))
+	if err != nil {
+		t.FailNow()
+	}
+
+	plain, compact := &bytes.Buffer{}, &bytes.Buffer{}
+	if lexicon.Write(plain) != nil || lexicon.Write(compact, WithCompactEncoding()) != nil {
+		t.FailNow()
+	}
+	if compact.Len() >= plain.Len()*3/4 {
+		t.FailNow()
+	}
+	read := &Lexicon{}
+	if read.UnmarshalBinary(compact.Bytes()) != nil {
+		t.FailNow()
+	}
+	data, _ := read.MarshalBinary()
+	if !bytes.Equal(data, plain.Bytes()) {
+		t.FailNow()
+	}
+
+	// Slots are lossless in any content
+	slots := []slotT{{0, 0}, {5, -1}, {-3, 7}, {0, -1}, {1 << 30, 1}, {-1 << 31, 1<<31 - 1}}
+	for i := 0; i < 1000; i++ {
+		slots = append(slots, slotT{0, -1})
+	}
+	slots = append(slots, slotT{2, 0})
+	unpacked, err := unpackSlots(packSlots(slots), int32(len(slots)))
+	if err != nil || len(unpacked) != len(slots) {
+		t.FailNow()
+	}
+	for i := range slots {
+		if unpacked[i] != slots[i] {
+			t.FailNow()
+		}
+	}
+	if _, err = unpackSlots(packSlots(slots), int32(len(slots)+1)); err == nil {
+		t.FailNow()
+	}
+	budget := &readBudget{size: int64(compact.Len()), memoryLimit: int64(compact.Len())}
+	if _, err = readLexicon(bytes.NewReader(compact.Bytes()), budget); !errors.Is(err, ErrMemoryLimit) {
+		t.FailNow()
+	}
+}
+
+func TestSmallSlots(t *testing.T) {
+	dict := map[string]int32{}
+	for i := 0; i < 2000; i++ {
+		dict[fmt.Sprintf("%x", i*7919)] = int32(i)
+	}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	plain, small := &bytes.Buffer{}, &bytes.Buffer{}
+	if lexicon.Write(plain) != nil || lexicon.Write(small, WithSmallSlots()) != nil {
+		t.FailNow()
+	}
+	if small.Len() != plain.Len()-len(lexicon.trimmedSlots())*4 {
+		t.FailNow()
+	}
+	read := &Lexicon{}
+	if read.UnmarshalBinary(small.Bytes()) != nil {
+		t.FailNow()
+	}
+	data, _ := read.MarshalBinary()
+	if !bytes.Equal(data, plain.Bytes()) {
+		t.FailNow()
+	}
+	// Decoded slots are counted in memory limit
+	limit := int64(len(lexicon.trimmedSlots()))*8 - 1
+	budget := &readBudget{size: int64(small.Len()), memoryLimit: limit}
+	if _, err = readLexicon(bytes.NewReader(small.Bytes()), budget); !errors.Is(err, ErrMemoryLimit) {
+		t.FailNow()
+	}
+
+	// Slots out of int16 are saved as usual
+	for i := 0; i < 40000; i++ {
+		dict[fmt.Sprintf("%x/%d", i*7919, i)] = int32(i)
+	}
+	lexicon, err = Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	plain.Reset()
+	small.Reset()
+	if lexicon.Write(plain) != nil || lexicon.Write(small, WithSmallSlots()) != nil {
+		t.FailNow()
+	}
+	if !bytes.Equal(small.Bytes(), plain.Bytes()) {
+		t.FailNow()
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	if checkLength("slots", maxLength) != nil {
+		t.FailNow()
+	}
+	err := checkLength("slots", maxLength+1)
+	if !errors.Is(err, ErrTooLarge) || !strings.Contains(err.Error(), "slots") {
+		t.FailNow()
+	}
+
+	// Build stops placing nodes after the error
+	lexicon, err := Build(map[string]int32{"a": 1}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	lexicon.buildErr = ErrTooLarge
+	processed := lexicon.processedNodes
+	if lexicon.build(&_Trie{}, 0, nil) != 0 || lexicon.processedNodes != processed {
+		t.FailNow()
+	}
+}
+
+func TestSuffix64(t *testing.T) {
+	dict := map[string]int32{}
+	for i := 0; i < 2000; i++ {
+		dict[fmt.Sprintf("%x", i*7919)] = int32(i)
+	}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	// Lexicons with suffix bytes exceed int32 are saved in the same way
+	suffix64 := func(o *saveOptions) { o.suffix64 = true }
+	plain, wide := &bytes.Buffer{}, &bytes.Buffer{}
+	if lexicon.Write(plain) != nil || lexicon.Write(wide, suffix64) != nil {
+		t.FailNow()
+	}
+	if wide.Len() != plain.Len()+4+len(lexicon.suffixIndex)*4 {
+		t.FailNow()
+	}
+	read := &Lexicon{}
+	if read.UnmarshalBinary(wide.Bytes()) != nil {
+		t.FailNow()
+	}
+	data, _ := read.MarshalBinary()
+	if !bytes.Equal(data, plain.Bytes()) {
+		t.FailNow()
+	}
+	disk, err := OpenDisk(bytes.NewReader(wide.Bytes()), int64(wide.Len()))
+	if err != nil {
+		t.FailNow()
+	}
+	for key, expected := range dict {
+		value, ok, err := disk.Get(key)
+		if err != nil || !ok || value != expected {
+			t.FailNow()
+		}
+	}
+	packed := &bytes.Buffer{}
+	if lexicon.Write(packed, suffix64, WithCompactEncoding()) != nil || read.UnmarshalBinary(packed.Bytes()) != nil {
+		t.FailNow()
+	}
+	if data, _ = read.MarshalBinary(); !bytes.Equal(data, plain.Bytes()) {
+		t.FailNow()
+	}
+}
+
+func TestBuildMemoryLimit(t *testing.T) {
+	dict := map[string]int32{}
+	for i := 0; i < 2000; i++ {
+		dict[fmt.Sprintf("%x", i*7919)] = int32(i)
+	}
+
+	expected, err := Build(dict, nil, WithSuffixLength(0, 2))
+	if err != nil {
+		t.FailNow()
+	}
+	for _, limit := range []int64{1, 4096, 1 << 30} {
+		lexicon, err := Build(
+			dict,
+			nil,
+			WithSuffixLength(0, 2),
+			WithBuildMemoryLimit(limit))
+		if err != nil || lexicon.Verify() != nil {
+			t.FailNow()
+		}
+
+		a, _ := expected.MarshalBinary()
+		b, _ := lexicon.MarshalBinary()
+		if !bytes.Equal(a, b) {
+			t.FailNow()
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	dict := map[string]int32{}
+	for i := 0; i < 100; i++ {
+		dict[fmt.Sprint(i)] = int32(i)
+	}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	in := make(chan string)
+	go func() {
+		for i := 0; i < 1000; i++ {
+			in <- fmt.Sprint(i)
+		}
+		close(in)
+	}()
+	i := 0
+	for r := range lexicon.Lookup(context.Background(), in) {
+		if r.Key != fmt.Sprint(i) || r.Found != (i < 100) || r.Found && r.Value != int32(i) {
+			t.FailNow()
+		}
+		i++
+	}
+	if i != 1000 {
+		t.FailNow()
+	}
+
+	// The output is closed once ctx is done, even if in is never closed
+	ctx, cancel := context.WithCancel(context.Background())
+	out := lexicon.Lookup(ctx, make(chan string))
+	cancel()
+	if _, ok := <-out; ok {
+		t.FailNow()
+	}
+}
+
+func TestBuildSet(t *testing.T) {
+	keys := []string{"the", "then", "there", "a", "abc"}
+	set, err := BuildSet(keys, nil)
+	if err != nil || !set.IsSet() {
+		t.FailNow()
+	}
+	setData, err := set.MarshalBinary()
+	if err != nil {
+		t.FailNow()
+	}
+
+	dict := map[string]int32{}
+	for _, key := range keys {
+		dict[key] = 0
+	}
+	lexicon, _ := Build(dict, nil)
+	data, _ := lexicon.MarshalBinary()
+	if len(setData) >= len(data) {
+		t.FailNow()
+	}
+
+	read := &Lexicon{}
+	if read.UnmarshalBinary(setData) != nil || !read.IsSet() {
+		t.FailNow()
+	}
+	d, err := OpenDisk(bytes.NewReader(setData), int64(len(setData)))
+	if err != nil {
+		t.FailNow()
+	}
+	for _, key := range keys {
+		if !read.Contains(key) {
+			t.FailNow()
+		}
+		if v, ok, err := d.Get(key); err != nil || !ok || v != 0 {
+			t.FailNow()
+		}
+	}
+	for _, key := range []string{"th", "therefore", "ab", "b"} {
+		if read.Contains(key) {
+			t.FailNow()
+		}
+		if _, ok, _ := d.Get(key); ok {
+			t.FailNow()
+		}
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	dict := map[string]int32{}
+	for i := 0; i < 1000; i++ {
+		dict[fmt.Sprint(i*3)] = int32(i)
+	}
+	lexicon, err := Build(dict, nil, WithBloomFilter(10))
+	if err != nil {
+		t.FailNow()
+	}
+	data, _ := lexicon.MarshalBinary()
+	read := &Lexicon{}
+	if read.UnmarshalBinary(data) != nil || read.bloom == nil {
+		t.FailNow()
+	}
+
+	falsePositives := 0
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprint(i)
+		value, ok := read.Get(key)
+		if ok != (i%3 == 0) || ok && value != int32(i/3) {
+			t.FailNow()
+		}
+		if !ok && read.bloom.mayContain(key) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 100 {
+		t.FailNow()
+	}
+}
+
+func TestCachedLexicon(t *testing.T) {
+	lexicon, err := Build(map[string]int32{"a": 1, "bcd": 2}, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	lexicon.PublishExpvar("lexicon_test_cache")
+
+	c := NewCachedLexicon(lexicon, 2)
+	for i := 0; i < 3; i++ {
+		if v, ok := c.Get("bcd"); !ok || v != 2 {
+			t.FailNow()
+		}
+		if _, ok := c.Get("b"); ok {
+			t.FailNow()
+		}
+	}
+	if lexicon.metrics.gets.Value() != 2 || c.Len() != 2 {
+		t.FailNow()
+	}
+
+	// "bcd" is the least recently used one
+	c.Get("a")
+	c.Get("b")
+	c.Get("bcd")
+	if lexicon.metrics.gets.Value() != 4 || c.Len() != 2 {
+		t.FailNow()
+	}
+}
+
+func TestItems(t *testing.T) {
+	dict := map[string]int32{"a": 1, "ab": 2, "abcdef": 3, "b": 4, "bcd": 5, "x": 6}
+	lexicon, err := Build(dict, nil, WithSuffixLength(0, 3))
+	if err != nil {
+		t.FailNow()
+	}
+
+	entries := []Entry{}
+	it := lexicon.Items()
+	for it.Next() {
+		entries = append(entries, Entry{it.Key(), it.Value()})
+	}
+	if fmt.Sprint(entries) != fmt.Sprint(lexicon.Complete("", 0)) || len(entries) != len(dict) {
+		t.FailNow()
+	}
+
+	empty, _ := Build(map[string]int32{}, nil)
+	if empty.Items().Next() {
+		t.FailNow()
+	}
+}
+
+// tamperSection calls fn to modify the payload of section 'tag' in data of
+// a lexicon file, and then fixes the checksum, so only the section is corrupt
+func tamperSection(data []byte, tag string, fn func(payload []byte)) []byte {
+	data = append([]byte{}, data...)
+	i := bytes.LastIndex(data, []byte(tag))
+	length := binary.LittleEndian.Uint32(data[i+sectionTagSize:])
+	fn(data[i+sectionTagSize+4 : i+sectionTagSize+4+int(length)])
+
+	// Checksum section is the last 12 bytes
+	sum := crc32.ChecksumIEEE(data[:len(data)-12])
+	binary.LittleEndian.PutUint32(data[len(data)-4:], sum)
+	return data
+}
+
+func TestKeysForValue(t *testing.T) {
+	dict := map[string]int32{"a": 1, "ab": 2, "abcdef": 1, "b": 3, "bcd": 1, "x": -5}
+	plain, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+	indexed, err := Build(dict, nil, WithValueIndex())
+	if err != nil {
+		t.FailNow()
+	}
+	data, _ := indexed.MarshalBinary()
+	read := &Lexicon{}
+	if read.UnmarshalBinary(data) != nil || read.values == nil {
+		t.FailNow()
+	}
+
+	for _, lexicon := range []*Lexicon{plain, indexed, read, read.Clone()} {
+		if fmt.Sprint(lexicon.KeysForValue(1)) != "[a abcdef bcd]" {
+			t.FailNow()
+		}
+		if fmt.Sprint(lexicon.KeysForValue(-5)) != "[x]" {
+			t.FailNow()
+		}
+		if len(lexicon.KeysForValue(4)) != 0 {
+			t.FailNow()
+		}
+		if fmt.Sprint(lexicon.KeysWithValueIn(0, 2)) != "[{a 1} {abcdef 1} {bcd 1} {ab 2}]" {
+			t.FailNow()
+		}
+		if len(lexicon.KeysWithValueIn(4, 100)) != 0 || len(lexicon.KeysWithValueIn(2, 1)) != 0 {
+			t.FailNow()
+		}
+		if fmt.Sprint(lexicon.TopKByValue(4)) != "[{b 3} {ab 2} {a 1} {abcdef 1}]" {
+			t.FailNow()
+		}
+		if len(lexicon.TopKByValue(10)) != len(dict) || len(lexicon.TopKByValue(0)) != 0 {
+			t.FailNow()
+		}
+	}
+
+	// Key ids in index should be keys, e.g. not the root or inner nodes
+	if read.UnmarshalBinary(tamperSection(data, sectionValueIndex, func(payload []byte) {})) != nil {
+		t.FailNow()
+	}
+	nonKey := int32(1)
+	for indexed.isKeyId(nonKey) {
+		nonKey++
+	}
+	if int(nonKey) >= len(indexed.trimmedSlots()) {
+		t.FailNow()
+	}
+	tampered := tamperSection(data, sectionValueIndex, func(payload []byte) {
+		binary.LittleEndian.PutUint32(payload[len(payload)-4:], uint32(nonKey))
+	})
+	if err = read.UnmarshalBinary(tampered); !errors.Is(err, ErrCorrupted) {
+		t.FailNow()
+	}
+}
+
+func TestRandomKey(t *testing.T) {
+	dict := map[string]int32{"a": 1, "ab": -2, "abcdef": 3, "b": 4, "bcd": 5}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	counts := map[string]int{}
+	for i := 0; i < 5000; i++ {
+		key, value, ok := lexicon.RandomKey(rng)
+		if !ok || dict[key] != value {
+			t.FailNow()
+		}
+		counts[key]++
+	}
+	for key := range dict {
+		if counts[key] < 800 || counts[key] > 1200 {
+			t.FailNow()
+		}
+	}
+
+	empty, _ := Build(map[string]int32{}, nil)
+	if _, _, ok := empty.RandomKey(rng); ok {
+		t.FailNow()
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	dict := map[string]int32{"a": 1, "ab": 2, "abcdef": 3, "b": 4, "bcd": 5}
+	lexicon, err := Build(dict, nil, WithBlockSize(128))
+	if err != nil {
+		t.FailNow()
+	}
+
+	a := lexicon.Analyze()
+	if a.NumKeys != 5 || a.BlockSize != 128 || len(a.BlockFillRates) != 1 {
+		t.FailNow()
+	}
+	if fmt.Sprint(a.KeyLengths) != "map[1:2 2:1 3:1 6:1]" {
+		t.FailNow()
+	}
+	// Root has 'a' and 'b', "a" has 'b', "ab" and "b" have 'c' linking to
+	// suffixes "def" and "d"
+	if fmt.Sprint(a.Branching) != "map[1:3 2:1]" {
+		t.FailNow()
+	}
+	if fmt.Sprint(a.SuffixLengths) != "map[1:1 3:1]" {
+		t.FailNow()
+	}
+
+	data, _ := lexicon.MarshalBinary()
+	read := &Lexicon{}
+	if read.UnmarshalBinary(data) != nil || read.Analyze().BlockSize != 256 {
+		t.FailNow()
+	}
+}
+
+func TestVisitNodes(t *testing.T) {
+	dict := map[string]int32{"a": 1, "ab": 2, "abcdef": 3, "b": 4, "bcd": 5}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	visited := 0
+	last := int32(-1)
+	lexicon.VisitNodes(func(state int32, base, check int32) {
+		if state <= last || check < 0 || lexicon.slots[state] != (slotT{base, check}) {
+			t.FailNow()
+		}
+		last = state
+		visited++
+	})
+	if visited == 0 || visited != lexicon.Analyze().UsedSlots {
+		t.FailNow()
+	}
+}
+
+func TestExportDOT(t *testing.T) {
+	dict := map[string]int32{"a": 1, "ab": 2, "b": 3, "bcd": 4}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	expected := []string{
+		`digraph lexicon {
+  node [shape=circle];
+  s0 [label="0"];
+  s0 -> s97 [label="a"];
+  s97 [shape=doublecircle, label="97\n=1"];
+  s97 -> s99 [label="b"];
+  s99 [shape=doublecircle, label="99\n=2"];
+  s0 -> s98 [label="b"];
+  s98 [shape=doublecircle, label="98\n=3"];
+  s98 -> s96 [label="c"];
+  s96 [label="96"];
+  x0 [shape=box, label="d\n=4"];
+  s96 -> x0 [style=dashed];
+}
+`,
+		`digraph lexicon {
+  node [shape=circle];
+  s0 [label="0"];
+  s0 -> s97 [label="a"];
+  s97 [shape=doublecircle, label="97\n=1"];
+  s0 -> s98 [label="b"];
+  s98 [shape=doublecircle, label="98\n=3"];
+}
+`,
+	}
+	for i, maxDepth := range []int{0, 1} {
+		buf := &bytes.Buffer{}
+		if lexicon.ExportDOT(buf, maxDepth) != nil || buf.String() != expected[i] {
+			t.FailNow()
+		}
+	}
+
+	expected = []string{
+		`digraph trie {
+  node [shape=circle];
+  n0 [label=""];
+  n0 -> n1 [label="a"];
+  n1 [shape=doublecircle, label="=1"];
+  n1 -> n2 [label="b"];
+  n2 [shape=doublecircle, label="=2"];
+  n0 -> n3 [label="b"];
+  n3 [shape=doublecircle, label="=3"];
+  n3 -> n4 [label="c"];
+  n4 [shape=box, label="d\n=4"];
+}
+`,
+		`digraph trie {
+  node [shape=circle];
+  n0 [label=""];
+  n0 -> n1 [label="a"];
+  n1 [shape=doublecircle, label="=1"];
+  n0 -> n2 [label="b"];
+  n2 [shape=doublecircle, label="=3"];
+}
+`,
+	}
+	for i, maxDepth := range []int{0, 1} {
+		buf := &bytes.Buffer{}
+		if ExportTrieDOT(buf, dict, maxDepth) != nil || buf.String() != expected[i] {
+			t.FailNow()
+		}
+	}
+}
+
+// failingWriter fails once more than n bytes are written
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return 0, io.ErrShortWrite
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestDumpStructure(t *testing.T) {
+	dict := map[string]int32{"a": 1, "ab": 2, "b": 3, "bcd": 4}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	expected := `LEXICON slots=256 suffixes=1 suffix_bytes=2
+ROOT [0]
+|- 'a' [97]
+|  |- 'b' [99]
+|  |  +- VALUE(2) [2]
+|  +- VALUE(1) [1]
++- 'b' [98]
+   |- 'c' [96]
+   |  +- SUFFIX("d", 4) [suffix 0]
+   +- VALUE(3) [3]
+`
+	buf := &bytes.Buffer{}
+	if lexicon.DumpStructure(buf) != nil || buf.String() != expected {
+		t.FailNow()
+	}
+
+	// The first error of writer is returned
+	if err = lexicon.DumpStructure(&failingWriter{60}); err != io.ErrShortWrite {
+		t.FailNow()
+	}
+}
+
+func TestBuildWarnings(t *testing.T) {
+	warnings := func(dict map[string]int32, opts ...Option) []string {
+		w := []string{}
+		opts = append(opts, WithBuildEvents(func(e BuildEvent) {
+			if e.Kind == BuildWarning {
+				w = append(w, e.Warning)
+			}
+		}))
+		if _, err := Build(dict, nil, opts...); err != nil {
+			t.FailNow()
+		}
+		return w
+	}
+
+	// Digits only
+	dict := map[string]int32{}
+	for i := 0; i < 100; i++ {
+		dict[fmt.Sprint(i)] = int32(i)
+	}
+	if len(warnings(dict)) != 1 || len(warnings(dict, WithBlockSize(0))) != 0 {
+		t.FailNow()
+	}
+
+	// Long keys stored as nodes
+	dict = map[string]int32{}
+	for i := 0; i < 100; i++ {
+		dict[fmt.Sprintf("https://example.com/%04d/index.html?q=%d", i, i)] = int32(i)
+	}
+	if !strings.Contains(fmt.Sprint(warnings(dict, WithSuffixCompression(false))), "nodes per key") {
+		t.FailNow()
+	}
+	if strings.Contains(fmt.Sprint(warnings(dict)), "nodes per key") {
+		t.FailNow()
+	}
+}
+
+func TestMaxKeyLength(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	dict := map[string]int32{"abc": 1, long + "a": 2, long + "b": 3}
+
+	_, err := Build(dict, nil, WithMaxKeyLength(100, RejectLongKeys))
+	var keyErr *KeyError
+	if !errors.Is(err, ErrKeyTooLong) || !errors.As(err, &keyErr) || keyErr.Key != long+"a" {
+		t.FailNow()
+	}
+	if len(err.Error()) > 200 {
+		t.FailNow()
+	}
+
+	lexicon, err := Build(dict, nil, WithMaxKeyLength(100, TruncateLongKeys))
+	if err != nil {
+		t.FailNow()
+	}
+	if v, ok := lexicon.Get(long); !ok || v != 2 || lexicon.MustGet("abc") != 1 {
+		t.FailNow()
+	}
+	if _, ok := lexicon.Get(long + "b"); ok {
+		t.FailNow()
+	}
+	if _, err = Build(dict, nil, WithMaxKeyLength(101, RejectLongKeys)); err != nil {
+		t.FailNow()
+	}
+
+	// UTF-8 keys are not truncated in the middle of a rune
+	lexicon, err = Build(
+		map[string]int32{"中文": 1},
+		nil,
+		WithMaxKeyLength(4, TruncateLongKeys),
+		WithUTF8Validation())
+	if err != nil || lexicon.MustGet("中") != 1 {
+		t.FailNow()
+	}
+	_, err = Build(map[string]int32{"中文": 1}, nil, WithMaxKeyLength(2, TruncateLongKeys))
+	if !errors.Is(err, ErrKeyTooLong) || !errors.As(err, &keyErr) || keyErr.Key != "中文" {
+		t.FailNow()
+	}
+}
+
+func TestUTF8Validation(t *testing.T) {
+	_, err := Build(map[string]int32{"中文": 1, "\xe4\xb8": 2}, nil, WithUTF8Validation())
+	if !errors.Is(err, ErrInvalidUTF8) {
+		t.FailNow()
+	}
+
+	lexicon, err := Build(map[string]int32{"中文": 1}, nil, WithUTF8Validation())
+	if err != nil || !lexicon.IsUTF8Validated() {
+		t.FailNow()
+	}
+	data, _ := lexicon.MarshalBinary()
+	read := &Lexicon{}
+	if read.UnmarshalBinary(data) != nil || !read.IsUTF8Validated() {
+		t.FailNow()
+	}
+	if v, ok, err := read.GetUTF8("中文"); err != nil || !ok || v != 1 {
+		t.FailNow()
+	}
+	if _, ok, err := read.GetUTF8("中"); err != nil || ok {
+		t.FailNow()
+	}
+	if _, _, err := read.GetUTF8("\xd6\xd0\xce\xc4"); !errors.Is(err, ErrInvalidUTF8) {
+		t.FailNow()
+	}
+}
+
+// foldCollator orders strings case-insensitively
+type foldCollator struct{}
+
+func (foldCollator) CompareString(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func TestCollated(t *testing.T) {
+	dict := map[string]int32{"Berlin": 1, "apple": 2, "Apple": 3, "banana": 4, "Cherry": 5}
+	lexicon, err := Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	entries := lexicon.CollatedEntries("", foldCollator{})
+	if fmt.Sprint(entries) != "[{Apple 3} {apple 2} {banana 4} {Berlin 1} {Cherry 5}]" {
+		t.FailNow()
+	}
+	entries = lexicon.CollatedRange("b", "c", foldCollator{})
+	if fmt.Sprint(entries) != "[{banana 4} {Berlin 1}]" {
+		t.FailNow()
+	}
+}
+
+func TestNormalizeWidth(t *testing.T) {
+	dict := map[string]int32{"ABC123": 1, "ガギパ": 2, `"中文"`: 3, "ヴ、。": 4}
+	lexicon, err := Build(dict, nil, WithTransforms(NormalizeWidth()))
+	if err != nil {
+		t.FailNow()
+	}
+	data, _ := lexicon.MarshalBinary()
+	read := &Lexicon{}
+	if read.UnmarshalBinary(data) != nil {
+		t.FailNow()
+	}
+
+	for key, value := range map[string]int32{
+		"ＡＢＣ１２３": 1, "ABC１23": 1, "ｶﾞｷﾞﾊﾟ": 2, "ガギパ": 2, "“中文”": 3, "ｳﾞ､｡": 4,
+	} {
+		if v, ok := read.Get(key); !ok || v != value {
+			t.FailNow()
+		}
+	}
+	if read.Normalize("ｱﾞﾟ　x") != "ア゛゜ x" {
+		t.FailNow()
+	}
+}
+
+func TestCedictRuneMap(t *testing.T) {
+	data := `中國 中国 [Zhong1 guo2] /China/
+頭髮 头发 [tou2 fa5] /hair/
+發現 发现 [fa1 xian4] /to discover/
+發生 发生 [fa1 sheng1] /to happen/
+`
+	toSimplified, err := CedictRuneMap(strings.NewReader(data), CedictSimplified)
+	if err != nil || len(toSimplified) != 5 || toSimplified['髮'] != '发' {
+		t.FailNow()
+	}
+	toTraditional, err := CedictRuneMap(strings.NewReader(data), CedictTraditional)
+	if err != nil || toTraditional['发'] != '發' {
+		t.FailNow()
+	}
+
+	lexicon, err := Build(
+		map[string]int32{"中国": 1, "头发": 2},
+		nil,
+		WithTransforms(RuneMap(toSimplified)))
+	if err != nil {
+		t.FailNow()
+	}
+	buf := &bytes.Buffer{}
+	if lexicon.Write(buf) != nil {
+		t.FailNow()
+	}
+	read, err := readLexicon(buf, &readBudget{size: int64(buf.Len())})
+	if err != nil {
+		t.FailNow()
+	}
+	if read.MustGet("中國") != 1 || read.MustGet("頭髮") != 2 || read.MustGet("头发") != 2 {
+		t.FailNow()
+	}
+}
+
+func TestExpandPinyin(t *testing.T) {
+	data := `中 中 [zhong1] /middle/
+中 中 [zhong4] /to hit/
+國 国 [guo2] /country/
+女 女 [nu:3] /female/
+`
+	table, err := CedictPinyinTable(strings.NewReader(data))
+	if err != nil || fmt.Sprint(table['中']) != "[zhong1 zhong4]" || table['女'][0] != "nv3" {
+		t.FailNow()
+	}
+
+	keys := ExpandPinyin([]string{"中国", "中國", "女"}, table, PinyinTone|PinyinToneless, "")
+	if fmt.Sprint(keys["zhongguo"]) != "[中国 中國]" || fmt.Sprint(keys["zhong4guo2"]) != "[中国 中國]" {
+		t.FailNow()
+	}
+	if len(keys) != 5 || fmt.Sprint(keys["nv"]) != "[女]" {
+		t.FailNow()
+	}
+
+	keys = ExpandPinyin([]string{"中A"}, table, PinyinToneless, " ")
+	if len(keys) != 1 || keys["zhong A"] == nil {
+		t.FailNow()
+	}
+}
+
+func TestKana(t *testing.T) {
+	if HiraganaToKatakana("とうきょう, ok") != "トウキョウ, ok" ||
+		KatakanaToHiragana("トウキョウ, ok") != "とうきょう, ok" {
+		t.FailNow()
+	}
+	for kana, romaji := range map[string]string{
+		"とうきょう": "toukyou", "ざっし": "zasshi", "まっちゃ": "matcha",
+		"きんえん": "kin'en", "コーヒー": "koohii", "しんぶん": "shinbun",
+	} {
+		if KanaToRomaji(kana) != romaji {
+			t.FailNow()
+		}
+	}
+	for romaji, kana := range map[string]string{
+		"Toukyou": "とうきょう", "zassi": "ざっし", "matcha": "まっちゃ",
+		"kin'en": "きんえん", "kinnen": "きんねん", "shinbun": "しんぶん",
+		"tyotto": "ちょっと", "ko-hi-": "こーひー",
+	} {
+		if RomajiToHiragana(romaji) != kana {
+			t.FailNow()
+		}
+	}
+
+	dict := map[string]int32{"とうきょう": 1, "コーヒー": 2}
+	lexicon, err := Build(dict, nil, WithTransforms(KanaReading()))
+	if err != nil {
+		t.FailNow()
+	}
+	data, _ := lexicon.MarshalBinary()
+	read := &Lexicon{}
+	if read.UnmarshalBinary(data) != nil {
+		t.FailNow()
+	}
+	for key, value := range map[string]int32{
+		"とうきょう": 1, "トウキョウ": 1, "toukyou": 1, "こーひー": 2, "ko-hi-": 2,
+	} {
+		if v, ok := read.Get(key); !ok || v != value {
+			t.FailNow()
+		}
+	}
+}
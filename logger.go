@@ -0,0 +1,22 @@
+package lexicon
+
+// Logger is where the library writes its diagnostic messages. *log.Logger
+// implements it, and slog could be adapted by a one-line wrapper. Without a
+// Logger the library is silent
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger sets the logger of Build for the summary of built Lexicon
+func WithLogger(l Logger) Option {
+	return func(o *buildOptions) {
+		o.logger = l
+	}
+}
+
+// logf writes message to the logger of build options, if any
+func (o *buildOptions) logf(format string, v ...interface{}) {
+	if o.logger != nil {
+		o.logger.Printf(format, v...)
+	}
+}
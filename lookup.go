@@ -0,0 +1,86 @@
+package lexicon
+
+import (
+	"context"
+	"runtime"
+)
+
+// Result is the result of a key in Lookup
+type Result struct {
+	Key   string
+	Value int32
+	Found bool
+}
+
+// lookupJob is a key to look up by workers of Lookup, whose result is sent
+// to the result channel
+type lookupJob struct {
+	key    string
+	result chan Result
+}
+
+// Lookup gets the value of each key from 'in' by a pool of GOMAXPROCS
+// workers, and sends the results to the returned channel in the order of
+// keys. The returned channel is closed after 'in' is closed and all results
+// are sent, or once ctx is done
+func (t *Lexicon) Lookup(ctx context.Context, in <-chan string) <-chan Result {
+	workers := runtime.GOMAXPROCS(0)
+	jobs := make(chan lookupJob, workers)
+	pending := make(chan chan Result, workers*4)
+	out := make(chan Result, workers)
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				value, ok := t.Get(job.key)
+				job.result <- Result{job.key, value, ok}
+			}
+		}()
+	}
+
+	// Dispatches keys to workers, the result channels are queued in pending
+	// in the order of keys
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		for {
+			var key string
+			var ok bool
+			select {
+			case key, ok = <-in:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+
+			job := lookupJob{key, make(chan Result, 1)}
+			select {
+			case pending <- job.result:
+				jobs <- job
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Collects results in order
+	go func() {
+		defer close(out)
+		for result := range pending {
+			select {
+			case r := <-result:
+				select {
+				case out <- r:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out
+}
@@ -0,0 +1,28 @@
+package lexicon
+
+import (
+	"bytes"
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler, the data is the same as
+// the file saved by Save. encoding/gob uses it as well, so Lexicon could be
+// a field of gob encoded values
+func (t *Lexicon) MarshalBinary() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	if err := t.write(buf, newSaveOptions(nil)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, it reads the data
+// from MarshalBinary or a lexicon file into t
+func (t *Lexicon) UnmarshalBinary(data []byte) error {
+	read, err := readLexicon(bytes.NewReader(data), &readBudget{size: int64(len(data))})
+	if err != nil {
+		return err
+	}
+
+	*t = *read
+	return nil
+}
@@ -0,0 +1,86 @@
+package lexicon
+
+// Match is an occurrence of key in text, where text[Start:End] == Key
+type Match struct {
+	Start int
+	End   int
+	Key   string
+	Value int32
+}
+
+// prefixesAt calls fn for each key which is a prefix of text[start:], from
+// the shortest to the longest. Stops once fn returns false, and returns false
+// in that case
+func (t *Lexicon) prefixesAt(
+	text string,
+	start int,
+	fn func(end int, value int32) bool) bool {
+	s := InitialState()
+	for i := start; i < len(text); i++ {
+		if !t.next(&s, text[i]) {
+			break
+		}
+		if value, ok := t.value(&s); ok {
+			if !fn(i+1, value) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// FindAll returns every occurrence of keys in text, ordered by start offset
+// then by end offset. Occurrences may overlap. It traverses the Lexicon from
+// each byte of text, so the cost is O(len(text) * max-key-length). It is
+// good enough for short texts without building an Aho-Corasick automaton
+func (t *Lexicon) FindAll(text string) []Match {
+	matches := []Match{}
+	for start := 0; start < len(text); start++ {
+		t.prefixesAt(text, start, func(end int, value int32) bool {
+			matches = append(matches, Match{start, end, text[start:end], value})
+			return true
+		})
+	}
+
+	return matches
+}
+
+// ContainsAny returns the first occurrence of keys in text, that is, the one
+// with the smallest start offset and the shortest among them. It stops
+// scanning at the first hit, which is cheaper than FindAll for blocklist or
+// allowlist screening
+func (t *Lexicon) ContainsAny(text string) (Match, bool) {
+	for start := 0; start < len(text); start++ {
+		var match Match
+		found := false
+		t.prefixesAt(text, start, func(end int, value int32) bool {
+			match = Match{start, end, text[start:end], value}
+			found = true
+			return false
+		})
+		if found {
+			return match, true
+		}
+	}
+
+	return Match{}, false
+}
+
+// longestAt returns the longest key which is a prefix of text[start:]
+func (t *Lexicon) longestAt(text string, start int) (end int, value int32, ok bool) {
+	t.prefixesAt(text, start, func(e int, v int32) bool {
+		end, value, ok = e, v, true
+		return true
+	})
+	return
+}
+
+// LongestPrefix returns the longest key which is a prefix of text
+func (t *Lexicon) LongestPrefix(text string) (Match, bool) {
+	end, value, ok := t.longestAt(text, 0)
+	if !ok {
+		return Match{}, false
+	}
+	return Match{0, end, text[:end], value}, true
+}
@@ -0,0 +1,85 @@
+package lexicon
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// MeCab dictionary CSV columns used commonly as value
+const (
+	MeCabLeftId  = 1
+	MeCabRightId = 2
+	MeCabCost    = 3
+)
+
+// ReadMeCabCSV reads a MeCab dictionary CSV (e.g. IPADIC) for Build. Each
+// record is: surface form, left context id, right context id, cost and
+// then features like POS. The key is the surface form and the value is the
+// integer in 'column', e.g. MeCabCost. A surface form may appear in several
+// records, the first one is kept. Files should be encoded in UTF-8, IPADIC
+// in EUC-JP should be converted first
+func ReadMeCabCSV(r io.Reader, column int) (map[string]int32, error) {
+	dict := map[string]int32{}
+	err := readMeCabCSV(r, column, func(surface, field string) error {
+		value, err := strconv.ParseInt(field, 10, 32)
+		if err != nil {
+			return err
+		}
+		if _, ok := dict[surface]; !ok {
+			dict[surface] = int32(value)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dict, nil
+}
+
+// ReadMeCabCSVStrings is like ReadMeCabCSV, but the value is the string in
+// 'column', e.g. POS or reading, for BuildStrings
+func ReadMeCabCSVStrings(r io.Reader, column int) (map[string]string, error) {
+	dict := map[string]string{}
+	err := readMeCabCSV(r, column, func(surface, field string) error {
+		if _, ok := dict[surface]; !ok {
+			dict[surface] = field
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return dict, nil
+}
+
+// readMeCabCSV calls fn with the surface form and the field of 'column' for
+// each record
+func readMeCabCSV(r io.Reader, column int, fn func(surface, field string) error) error {
+	if column < 1 {
+		return fmt.Errorf("invalid column: %d", column)
+	}
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) <= column {
+			return fmt.Errorf("line %d: no column %d", line, column)
+		}
+		if err = fn(record[0], record[column]); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package lexicon
+
+import (
+	"strings"
+)
+
+// NGram is an n-gram of tokens which exists in Lexicon, Key is the tokens
+// joined by separator and Count is the number of its occurrences
+type NGram struct {
+	Key   string
+	Value int32
+	Count int
+}
+
+// NGrams slides over tokens and returns every n-gram (1 <= n <= maxN), whose
+// tokens joined by 'sep' is a key in Lexicon, with counts. N-grams are
+// ordered by their first occurrence. Since n-grams starting at the same token
+// share a traversal, it stops extending an n-gram as soon as no key has it
+// as prefix
+func (t *Lexicon) NGrams(tokens []string, maxN int, sep string) []NGram {
+	ngrams := []NGram{}
+	index := map[string]int{}
+	for i := range tokens {
+		s := InitialState()
+		for n := 1; n <= maxN && i+n <= len(tokens); n++ {
+			if n > 1 {
+				t.Traverse(sep, &s)
+			}
+			value, ok := t.Traverse(tokens[i+n-1], &s)
+			if !s.Valid() {
+				break
+			}
+			// An empty first token leaves s at the initial state, which is
+			// not a key
+			if !ok || s.state == 0 {
+				continue
+			}
+
+			key := strings.Join(tokens[i:i+n], sep)
+			if j, ok := index[key]; ok {
+				ngrams[j].Count++
+			} else {
+				index[key] = len(ngrams)
+				ngrams = append(ngrams, NGram{key, value, 1})
+			}
+		}
+	}
+
+	return ngrams
+}
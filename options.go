@@ -0,0 +1,185 @@
+package lexicon
+
+import (
+	"context"
+)
+
+// Option is the option of Build
+type Option func(*buildOptions)
+
+// buildOptions stores all options of Build
+type buildOptions struct {
+	phonetic   PhoneticAlgorithm
+	blockSize  int
+	transforms []Transform
+	noSuffix   bool
+	minSuffix  int
+	maxSuffix  int
+
+	// Memory limit of the intermediate trie, 0 means no limit
+	memoryLimit int64
+
+	bloomBitsPerKey int
+	valueIndex      bool
+
+	maxKeyLength    int
+	keyLengthPolicy KeyLengthPolicy
+	validateUTF8    bool
+
+	traceContext context.Context
+	tracer       Tracer
+	logger       Logger
+	events       func(BuildEvent)
+}
+
+// newBuildOptions creates build options with default values, then applies
+// opts on it
+func newBuildOptions(opts []Option) *buildOptions {
+	o := &buildOptions{
+		blockSize: 256,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithPhoneticIndex additionally indexes the phonetic code of each key
+// encoded by algorithm 'p', which could be queried by GetPhonetic
+func WithPhoneticIndex(p PhoneticAlgorithm) Option {
+	return func(o *buildOptions) {
+		o.phonetic = p
+	}
+}
+
+// WithBlockSize sets the number of slots in a block, which is the unit of
+// slot allocation in Build. It should be a power of 2, no more than 256 and
+// greater than any byte in keys. Smaller blocks improve the fill rate for
+// small alphabets, like digits or DNA sequences. 0 means the smallest valid
+// size for the keys. The default is 256
+func WithBlockSize(n int) Option {
+	return func(o *buildOptions) {
+		o.blockSize = n
+	}
+}
+
+// WithTransforms applies transforms on each key in Build, in order. They are
+// recorded in Lexicon, and Get applies them on queries too
+func WithTransforms(transforms ...Transform) Option {
+	return func(o *buildOptions) {
+		o.transforms = append(o.transforms, transforms...)
+	}
+}
+
+// WithSuffixCompression sets whether single-child tails of keys are stored
+// as suffixes, which is the default. Without it all keys are stored as nodes
+// in double array, lookups never take the suffix path, at the cost of more
+// slots
+func WithSuffixCompression(enabled bool) Option {
+	return func(o *buildOptions) {
+		o.noSuffix = !enabled
+	}
+}
+
+// WithSuffixLength sets the length of suffixes in bytes. Tails shorter than
+// minLength are stored as nodes in double array, and tails longer than
+// maxLength are stored as nodes until the rest is maxLength bytes. 0 means
+// no limit
+func WithSuffixLength(minLength, maxLength int) Option {
+	return func(o *buildOptions) {
+		o.minSuffix = minLength
+		o.maxSuffix = maxLength
+	}
+}
+
+// keepSuffix returns the function to decide whether a suffix is kept in
+// trie, or nil to keep all suffixes
+func (o *buildOptions) keepSuffix() func(suffix []byte) bool {
+	if o.noSuffix {
+		return func([]byte) bool { return false }
+	}
+	if o.minSuffix <= 0 && o.maxSuffix <= 0 {
+		return nil
+	}
+
+	return func(suffix []byte) bool {
+		if o.minSuffix > 0 && len(suffix) < o.minSuffix {
+			return false
+		}
+		return o.maxSuffix <= 0 || len(suffix) <= o.maxSuffix
+	}
+}
+
+// ReadOption is the option of Read
+type ReadOption func(*readOptions)
+
+// readOptions stores all options of Read
+type readOptions struct {
+	memoryLimit int64
+
+	traceContext context.Context
+	tracer       Tracer
+}
+
+// newReadOptions creates read options with default values, then applies opts
+// on it
+func newReadOptions(opts []ReadOption) *readOptions {
+	o := &readOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithMemoryLimit limits the bytes allocated for the double array, suffixes
+// and sections in Read, Read fails with ErrMemoryLimit if the file needs
+// more. 0 means no limit other than the file length
+func WithMemoryLimit(n int64) ReadOption {
+	return func(o *readOptions) {
+		o.memoryLimit = n
+	}
+}
+
+// SaveOption is the option of Save and Write, which selects the encoding of
+// file. Files in any encoding are read by Read
+type SaveOption func(*saveOptions)
+
+// saveOptions stores all options of Save
+type saveOptions struct {
+	packedSuffix bool
+	varintSlots  bool
+	smallSlots   bool
+
+	// suffixIndex is int64 in file even if suffix bytes fit in int32, only
+	// for tests
+	suffix64 bool
+}
+
+// newSaveOptions creates save options with default values, then applies opts
+// on it
+func newSaveOptions(opts []SaveOption) *saveOptions {
+	o := &saveOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// flags returns the flags of file encoding selected by options for slots.
+// Varint slots take precedence over small slots, which are used only if
+// slots fit
+func (o *saveOptions) flags(slots []slotT) uint32 {
+	var flags uint32
+	if o.packedSuffix {
+		flags |= flagPackedSuffix
+	}
+	if o.varintSlots {
+		flags |= flagVarintSlots
+	} else if o.smallSlots && fitSmallSlots(slots) {
+		flags |= flagSmallSlots
+	}
+	if o.suffix64 {
+		flags |= flagSuffix64
+	}
+	return flags
+}
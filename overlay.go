@@ -0,0 +1,136 @@
+package lexicon
+
+import (
+	"sync"
+)
+
+// Overlay layers a small mutable delta (adds, deletes and value overrides)
+// over an immutable base Lexicon. Lookups consult the delta first. It is
+// safe for concurrent use
+type Overlay struct {
+	base *Lexicon
+
+	mutex sync.RWMutex
+
+	// Keys added or overridden, and keys deleted from base. A key is never
+	// in both of them
+	added   map[string]int32
+	deleted map[string]bool
+
+	// Write-ahead log of changes, see EnableWAL
+	wal     *DeltaWriter
+	walSync bool
+}
+
+// NewOverlay creates an empty Overlay over base
+func NewOverlay(base *Lexicon) *Overlay {
+	return &Overlay{
+		base:    base,
+		added:   map[string]int32{},
+		deleted: map[string]bool{},
+	}
+}
+
+// Base returns the base Lexicon of overlay
+func (o *Overlay) Base() *Lexicon {
+	return o.base
+}
+
+// normalize applies the transforms of base on key, so keys in the delta are
+// the same as those in base
+func (o *Overlay) normalize(key string) string {
+	if len(o.base.transforms) > 0 {
+		return o.base.Normalize(key)
+	}
+	return key
+}
+
+// Get gets the value by key, from the delta first and then the base. Keys
+// are normalized by the transforms of base, like Get of Lexicon
+func (o *Overlay) Get(key string) (value int32, ok bool) {
+	key = o.normalize(key)
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	if value, ok := o.added[key]; ok {
+		return value, true
+	}
+	if o.deleted[key] {
+		return -1, false
+	}
+	return o.base.Get(key)
+}
+
+// Set adds the key, or overrides its value if it already exists
+func (o *Overlay) Set(key string, value int32) error {
+	key = o.normalize(key)
+	if err := checkKey(key); err != nil {
+		return err
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if err := o.logChange(deltaSet, key, value); err != nil {
+		return err
+	}
+	o.set(key, value)
+	return nil
+}
+
+// set sets the value of key in delta
+func (o *Overlay) set(key string, value int32) {
+	delete(o.deleted, key)
+	o.added[key] = value
+}
+
+// Delete deletes the key. It is a no-op if key not exists. Returns the error
+// of writing WAL if enabled
+func (o *Overlay) Delete(key string) error {
+	key = o.normalize(key)
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if err := o.logChange(deltaDelete, key, 0); err != nil {
+		return err
+	}
+	o.delete(key)
+	return nil
+}
+
+// delete deletes the key in delta
+func (o *Overlay) delete(key string) {
+	delete(o.added, key)
+	if _, ok := o.base.Get(key); ok {
+		o.deleted[key] = true
+	}
+}
+
+// DeltaSize returns the number of keys changed in the delta
+func (o *Overlay) DeltaSize() int {
+	o.mutex.RLock()
+	defer o.mutex.RUnlock()
+
+	return len(o.added) + len(o.deleted)
+}
+
+// Compact builds a new Lexicon with the delta merged into base
+func (o *Overlay) Compact(
+	progress func(int, int),
+	opts ...Option) (*Lexicon, error) {
+	o.mutex.RLock()
+	dict := map[string]int32{}
+	s := InitialState()
+	o.base.walk(&s, []byte{}, func(key []byte, value int32) bool {
+		if !o.deleted[string(key)] {
+			dict[string(key)] = value
+		}
+		return true
+	})
+	for key, value := range o.added {
+		dict[key] = value
+	}
+	o.mutex.RUnlock()
+
+	return Build(dict, progress, opts...)
+}
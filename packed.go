@@ -0,0 +1,389 @@
+package lexicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+)
+
+// WithPackedSuffix saves suffixIndex as deltas and suffixValue as codes of
+// distinct values, which are usually much fewer than suffixes, e.g.
+// frequency ranks or POS ids. They are decoded into plain arrays by Read, so
+// lookups are not affected. OpenDisk could not read the packed file since it
+// needs random access to the arrays
+func WithPackedSuffix() SaveOption {
+	return func(o *saveOptions) {
+		o.packedSuffix = true
+	}
+}
+
+// maxPackedDistinct is the max number of distinct values coded in packed
+// suffixValue, more values are stored as they are
+const maxPackedDistinct = 1 << 16
+
+// packedCodeSize returns the bytes of a code of numDistinct values
+func packedCodeSize(numDistinct int) int {
+	if numDistinct <= 1<<8 {
+		return 1
+	}
+	return 2
+}
+
+// packSuffix encodes suffixIndex and suffixValue into bytes: deltas of
+// suffixIndex (uvarint), then if not a set, number of distinct values
+// (uvarint), distinct values in ascending order (varint) and the code of
+// each suffix value in 1 or 2 bytes by the number of distinct values. If
+// there are more than 65536 distinct values, the number is 0 and each suffix
+// value is a varint instead
+func packSuffix(suffixIndex []int64, suffixValue []int32, isSet bool) []byte {
+	buf := &bytes.Buffer{}
+	varint := make([]byte, binary.MaxVarintLen64)
+	previous := int64(0)
+	for _, begin := range suffixIndex {
+		buf.Write(varint[:binary.PutUvarint(varint, uint64(begin-previous))])
+		previous = begin
+	}
+	if isSet {
+		return buf.Bytes()
+	}
+
+	codes := map[int32]uint32{}
+	for _, value := range suffixValue {
+		codes[value] = 0
+	}
+	distinct := make([]int32, 0, len(codes))
+	for value := range codes {
+		distinct = append(distinct, value)
+	}
+	if len(distinct) > maxPackedDistinct {
+		buf.WriteByte(0)
+		for _, value := range suffixValue {
+			buf.Write(varint[:binary.PutVarint(varint, int64(value))])
+		}
+		return buf.Bytes()
+	}
+	sort.Slice(distinct, func(i, j int) bool {
+		return distinct[i] < distinct[j]
+	})
+	buf.Write(varint[:binary.PutUvarint(varint, uint64(len(distinct)))])
+	for i, value := range distinct {
+		codes[value] = uint32(i)
+		buf.Write(varint[:binary.PutVarint(varint, int64(value))])
+	}
+
+	codeSize := packedCodeSize(len(distinct))
+	code := make([]byte, 4)
+	for _, value := range suffixValue {
+		binary.LittleEndian.PutUint32(code, codes[value])
+		buf.Write(code[:codeSize])
+	}
+	return buf.Bytes()
+}
+
+// unpackSuffix decodes numSuffix suffixIndex and suffixValue from the data of
+// packSuffix
+func unpackSuffix(data []byte, numSuffix int32, isSet bool) ([]int64, []int32, error) {
+	// Each delta takes at least one byte
+	if int64(numSuffix) > int64(len(data)) {
+		return nil, nil, ErrCorrupted
+	}
+
+	r := bytes.NewReader(data)
+	suffixIndex := make([]int64, numSuffix)
+	suffixValue := make([]int32, numSuffix)
+	begin := uint64(0)
+	for i := range suffixIndex {
+		delta, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, nil, ErrCorrupted
+		}
+		begin += delta
+		if begin < delta || begin > 1<<63-1 {
+			return nil, nil, ErrCorrupted
+		}
+		suffixIndex[i] = int64(begin)
+	}
+	if isSet {
+		if r.Len() != 0 {
+			return nil, nil, ErrCorrupted
+		}
+		return suffixIndex, suffixValue, nil
+	}
+
+	numDistinct, err := binary.ReadUvarint(r)
+	if err != nil || numDistinct > maxPackedDistinct || numDistinct > uint64(r.Len()) {
+		return nil, nil, ErrCorrupted
+	}
+	if numDistinct == 0 {
+		for i := range suffixValue {
+			value, err := binary.ReadVarint(r)
+			if err != nil || value < -1<<31 || value > 1<<31-1 {
+				return nil, nil, ErrCorrupted
+			}
+			suffixValue[i] = int32(value)
+		}
+		if r.Len() != 0 {
+			return nil, nil, ErrCorrupted
+		}
+		return suffixIndex, suffixValue, nil
+	}
+	distinct := make([]int32, numDistinct)
+	for i := range distinct {
+		value, err := binary.ReadVarint(r)
+		if err != nil || value < -1<<31 || value > 1<<31-1 {
+			return nil, nil, ErrCorrupted
+		}
+		distinct[i] = int32(value)
+	}
+
+	codeSize := packedCodeSize(len(distinct))
+	if r.Len() != int(numSuffix)*codeSize {
+		return nil, nil, ErrCorrupted
+	}
+	code := make([]byte, 4)
+	for i := range suffixValue {
+		io.ReadFull(r, code[:codeSize])
+		index := binary.LittleEndian.Uint32(code)
+		if uint64(index) >= numDistinct {
+			return nil, nil, ErrCorrupted
+		}
+		suffixValue[i] = distinct[index]
+	}
+
+	return suffixIndex, suffixValue, nil
+}
+
+// readPacked reads the length and data of a packed array from r, which is
+// decoded into 'decoded' bytes
+func readPacked(r io.Reader, budget *readBudget, decoded int64) ([]byte, error) {
+	var length int32
+	err := binary.Read(r, binary.LittleEndian, &length)
+	if err == nil {
+		err = budget.alloc(int64(length))
+	}
+	if err == nil {
+		err = budget.decode(decoded)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, length)
+	if _, err = io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// readPackedSuffix reads the data of packSuffix from r, and decodes
+// numSuffix suffixIndex and suffixValue from it
+func readPackedSuffix(
+	r io.Reader,
+	numSuffix int32,
+	isSet bool,
+	budget *readBudget,
+	previousErr error) ([]int64, []int32, error) {
+	if previousErr != nil {
+		return nil, nil, previousErr
+	}
+
+	data, err := readPacked(r, budget, int64(numSuffix)*12)
+	if err != nil {
+		return nil, nil, err
+	}
+	return unpackSuffix(data, numSuffix, isSet)
+}
+
+// WithCompactEncoding saves slots as varints relative to their indexes,
+// which are mostly one or two bytes, and runs of empty slots as their
+// lengths. Suffixes are packed as WithPackedSuffix. Slots are decoded into
+// the plain array by Read, so lookups are not affected. OpenDisk could not
+// read the compact file since it needs random access to the arrays
+func WithCompactEncoding() SaveOption {
+	return func(o *saveOptions) {
+		o.varintSlots = true
+		o.packedSuffix = true
+	}
+}
+
+// maxEmptyRun is the max length of a run of empty slots in packed slots, so
+// the slots decoded from n bytes are bounded
+const maxEmptyRun = 256
+
+// Flag in the tag of packed slot, base is stored as it is rather than
+// relative to the index of slot
+const packedRawBase = 1
+
+// packSlots encodes slots into bytes. Each run of empty slots is 0 and its
+// length (uvarint), other slots are a tag (uvarint) and base (varint). The
+// tag is (zigzag(index - check) << 1 | packedRawBase) + 1, and base is raw
+// for value slots and suffix links, or relative to index for the others
+func packSlots(slots []slotT) []byte {
+	buf := &bytes.Buffer{}
+	varint := make([]byte, binary.MaxVarintLen64)
+	for i := 0; i < len(slots); i++ {
+		slot := slots[i]
+		if slot == (slotT{Base: 0, Check: -1}) {
+			run := 1
+			for i+run < len(slots) && run < maxEmptyRun && slots[i+run] == slot {
+				run++
+			}
+			buf.WriteByte(0)
+			buf.Write(varint[:binary.PutUvarint(varint, uint64(run))])
+			i += run - 1
+			continue
+		}
+
+		raw := slot.Base < 0 ||
+			slot.Check >= 0 && int(slot.Check) < len(slots) && slots[slot.Check].Base == int32(i)
+		tag := zigzag(int64(i)-int64(slot.Check)) << 1
+		base := int64(slot.Base) - int64(i)
+		if raw {
+			tag |= packedRawBase
+			base = int64(slot.Base)
+		}
+		buf.Write(varint[:binary.PutUvarint(varint, tag+1)])
+		buf.Write(varint[:binary.PutVarint(varint, base)])
+	}
+	return buf.Bytes()
+}
+
+// zigzag maps signed integers to unsigned integers, so small magnitudes
+// are small
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// unzigzag is the inverse of zigzag
+func unzigzag(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+// unpackSlots decodes numSlots slots from the data of packSlots
+func unpackSlots(data []byte, numSlots int32) ([]slotT, error) {
+	if int64(numSlots) > int64(len(data))*maxEmptyRun/2+1 {
+		return nil, ErrCorrupted
+	}
+
+	r := bytes.NewReader(data)
+	slots := make([]slotT, numSlots)
+	for i := 0; i < len(slots); {
+		tag, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, ErrCorrupted
+		}
+		if tag == 0 {
+			run, err := binary.ReadUvarint(r)
+			if err != nil || run == 0 || run > maxEmptyRun || run > uint64(len(slots)-i) {
+				return nil, ErrCorrupted
+			}
+			for end := i + int(run); i < end; i++ {
+				slots[i] = slotT{Base: 0, Check: -1}
+			}
+			continue
+		}
+
+		base, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, ErrCorrupted
+		}
+		tag--
+		check := int64(i) - unzigzag(tag>>1)
+		if tag&packedRawBase == 0 {
+			base += int64(i)
+		}
+		if check < -1<<31 || check > 1<<31-1 || base < -1<<31 || base > 1<<31-1 {
+			return nil, ErrCorrupted
+		}
+		slots[i] = slotT{Base: int32(base), Check: int32(check)}
+		i++
+	}
+	if r.Len() != 0 {
+		return nil, ErrCorrupted
+	}
+
+	return slots, nil
+}
+
+// readPackedSlots reads the data of packSlots from r, and decodes numSlots
+// slots from it
+func readPackedSlots(
+	r io.Reader,
+	numSlots int32,
+	budget *readBudget,
+	previousErr error) ([]slotT, error) {
+	if previousErr != nil {
+		return nil, previousErr
+	}
+
+	data, err := readPacked(r, budget, int64(numSlots)*8)
+	if err != nil {
+		return nil, err
+	}
+	return unpackSlots(data, numSlots)
+}
+
+// WithSmallSlots saves slots as int16 pairs if all bases, checks and values
+// fit in int16, which halves the slot array in file of small lexicons. Larger
+// lexicons are saved as usual. Slots are decoded into the plain array by
+// Read, so the memory use is unchanged. OpenDisk could not read the file
+func WithSmallSlots() SaveOption {
+	return func(o *saveOptions) {
+		o.smallSlots = true
+	}
+}
+
+// smallSlot is the int16 slot in file, see WithSmallSlots
+type smallSlot struct {
+	Base  int16
+	Check int16
+}
+
+// fitSmallSlots returns true if all slots fit in smallSlot
+func fitSmallSlots(slots []slotT) bool {
+	for _, slot := range slots {
+		if slot.Base < -1<<15 || slot.Base > 1<<15-1 || slot.Check < -1<<15 || slot.Check > 1<<15-1 {
+			return false
+		}
+	}
+	return true
+}
+
+// smallSlotsOf converts slots into smallSlot, which should fit
+func smallSlotsOf(slots []slotT) []smallSlot {
+	small := make([]smallSlot, len(slots))
+	for i, slot := range slots {
+		small[i] = smallSlot{int16(slot.Base), int16(slot.Check)}
+	}
+	return small
+}
+
+// readSmallSlots reads numSlots smallSlot from r into slots
+func readSmallSlots(
+	r io.Reader,
+	numSlots int32,
+	budget *readBudget,
+	previousErr error) ([]slotT, error) {
+	if previousErr != nil {
+		return nil, previousErr
+	}
+
+	// Small slots are in file, and decoded into the plain slots
+	err := budget.alloc(int64(numSlots) * 4)
+	if err == nil {
+		err = budget.decode(int64(numSlots) * 8)
+	}
+	if err != nil {
+		return nil, err
+	}
+	small := make([]smallSlot, numSlots)
+	if err := binary.Read(r, binary.LittleEndian, small); err != nil {
+		return nil, err
+	}
+	slots := make([]slotT, numSlots)
+	for i, slot := range small {
+		slots[i] = slotT{int32(slot.Base), int32(slot.Check)}
+	}
+	return slots, nil
+}
@@ -0,0 +1,66 @@
+package lexicon
+
+import (
+	"encoding/json"
+)
+
+// PayloadCodec encodes and decodes per-key payloads, e.g. by protobuf or
+// msgpack. Its methods have the same signature as json.Marshal and
+// json.Unmarshal, so most codec packages could be adapted easily
+type PayloadCodec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec is the PayloadCodec by encoding/json
+type JSONCodec struct{}
+
+// Marshal encodes v into JSON
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into v
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// BuildPayloads builds the reimu-trie from dict where each key carries an
+// arbitrary payload, which is encoded by codec and stored in the string table
+// section. Payloads are decoded lazily by GetPayload, so all metadata of
+// entries could be kept inside one artifact
+func BuildPayloads(
+	dict map[string]interface{},
+	codec PayloadCodec,
+	progress func(int, int),
+	opts ...Option) (*Lexicon, error) {
+	encoded := make(map[string]string, len(dict))
+	for key, v := range dict {
+		data, err := codec.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		encoded[key] = string(data)
+	}
+
+	return BuildStrings(encoded, progress, opts...)
+}
+
+// GetPayload gets the payload by key in Lexicon built by BuildPayloads and
+// decodes it into v by codec, which should be the same codec in building.
+// Returns false if key not exists
+func (t *Lexicon) GetPayload(
+	key string,
+	codec PayloadCodec,
+	v interface{}) (bool, error) {
+	data, ok := t.GetString(key)
+	if !ok {
+		return false, nil
+	}
+
+	err := codec.Unmarshal([]byte(data), v)
+	if err != nil {
+		return true, err
+	}
+	return true, nil
+}
@@ -0,0 +1,374 @@
+package lexicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// PhoneticAlgorithm is the algorithm to encode a word into its phonetic code
+type PhoneticAlgorithm int32
+
+const (
+	PhoneticSoundex PhoneticAlgorithm = iota + 1
+	PhoneticMetaphone
+)
+
+// phoneticIndex maps phonetic codes to ids of keys having that code
+type phoneticIndex struct {
+	algorithm PhoneticAlgorithm
+
+	// codes maps phonetic code to the offset of its group in keyIds
+	codes *Lexicon
+
+	// Each group in keyIds is the number of keys followed by their ids
+	keyIds []int32
+}
+
+// Encode encodes word by algorithm p. Returns "" when word has no letters
+func (p PhoneticAlgorithm) Encode(word string) string {
+	switch p {
+	case PhoneticSoundex:
+		return Soundex(word)
+	case PhoneticMetaphone:
+		return Metaphone(word)
+	default:
+		return ""
+	}
+}
+
+// buildPhoneticIndex builds the phonetic index of keys in dict, the keys
+// should be already in Lexicon t
+func buildPhoneticIndex(
+	t *Lexicon,
+	dict map[string]int32,
+	algorithm PhoneticAlgorithm) (*phoneticIndex, error) {
+	if algorithm != PhoneticSoundex && algorithm != PhoneticMetaphone {
+		return nil, ErrUnknownPhonetic
+	}
+
+	groups := map[string][]string{}
+	for key := range dict {
+		code := algorithm.Encode(key)
+		if code != "" {
+			groups[code] = append(groups[code], key)
+		}
+	}
+
+	index := &phoneticIndex{
+		algorithm: algorithm,
+		keyIds:    []int32{},
+	}
+	offsets := map[string]int32{}
+	for code, keys := range groups {
+		sort.Strings(keys)
+		offsets[code] = int32(len(index.keyIds))
+		index.keyIds = append(index.keyIds, int32(len(keys)))
+		for _, key := range keys {
+			id, ok := t.keyId(key)
+			assert(ok, "buildPhoneticIndex: key not in lexicon")
+			index.keyIds = append(index.keyIds, id)
+		}
+	}
+
+	var err error
+	index.codes, err = Build(offsets, nil)
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// marshal encodes the phonetic index into bytes
+func (index *phoneticIndex) marshal() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := binary.Write(buf, binary.LittleEndian, index.algorithm)
+	if err == nil {
+		err = binary.Write(buf, binary.LittleEndian, int32(len(index.keyIds)))
+	}
+	if err == nil {
+		err = binary.Write(buf, binary.LittleEndian, index.keyIds)
+	}
+	if err == nil {
+		err = index.codes.Write(buf)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readPhoneticIndex reads the phonetic index from payload of its section
+func readPhoneticIndex(payload []byte) (*phoneticIndex, error) {
+	r := bytes.NewReader(payload)
+	index := &phoneticIndex{}
+	var numKeyIds int32
+	err := binary.Read(r, binary.LittleEndian, &index.algorithm)
+	if err == nil {
+		err = binary.Read(r, binary.LittleEndian, &numKeyIds)
+	}
+	if err == nil && index.algorithm.Encode("a") == "" {
+		return nil, ErrCorrupted
+	}
+	if err == nil && (numKeyIds < 0 || int(numKeyIds) > r.Len()/4) {
+		return nil, ErrCorrupted
+	}
+	if err == nil {
+		index.keyIds = make([]int32, numKeyIds)
+		err = binary.Read(r, binary.LittleEndian, &index.keyIds)
+	}
+	if err == nil {
+		index.codes, err = readLexicon(r, &readBudget{size: int64(r.Len())})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Key ids are groups of the number of keys and their ids, and each code
+	// is the offset of a group
+	groups := map[int32]bool{}
+	for offset := 0; offset < len(index.keyIds); {
+		numKeys := index.keyIds[offset]
+		if numKeys < 0 || int(numKeys) >= len(index.keyIds)-offset {
+			return nil, ErrCorrupted
+		}
+		groups[int32(offset)] = true
+		offset += 1 + int(numKeys)
+	}
+	corrupted := false
+	index.codes.walkPrefix("", func(code string, offset int32) bool {
+		corrupted = !groups[offset]
+		return !corrupted
+	})
+	if corrupted {
+		return nil, ErrCorrupted
+	}
+
+	return index, nil
+}
+
+// ids calls fn with the key ids of each group in index
+func (index *phoneticIndex) ids(fn func(ids []int32)) {
+	for offset := 0; offset < len(index.keyIds); {
+		numKeys := int(index.keyIds[offset])
+		fn(index.keyIds[offset+1 : offset+1+numKeys])
+		offset += 1 + numKeys
+	}
+}
+
+// GetPhonetic returns entries whose key sounds like 'key', that is, has the
+// same phonetic code. Returns nil if Lexicon is built without phonetic index
+func (t *Lexicon) GetPhonetic(key string) []Entry {
+	if t.phonetic == nil {
+		return nil
+	}
+
+	entries := []Entry{}
+	code := t.phonetic.algorithm.Encode(key)
+	offset, ok := t.phonetic.codes.Get(code)
+	if code == "" || !ok {
+		return entries
+	}
+
+	numKeys := t.phonetic.keyIds[offset]
+	for _, id := range t.phonetic.keyIds[offset+1 : offset+1+numKeys] {
+		entries = append(entries, Entry{string(t.keyAt(id)), t.valueAt(id)})
+	}
+	return entries
+}
+
+// Soundex returns the American Soundex code of word, for example, both
+// "Robert" and "Rupert" are "R163". Non-ASCII letters are ignored
+func Soundex(word string) string {
+	const codes = "01230120022455012623010202"
+
+	code := []byte{}
+	var last byte
+	for i := 0; i < len(word) && len(code) < 4; i++ {
+		c := word[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		if c < 'A' || c > 'Z' {
+			continue
+		}
+
+		digit := codes[c-'A']
+		if len(code) == 0 {
+			code = append(code, c)
+		} else if digit != '0' && digit != last {
+			code = append(code, digit)
+		}
+
+		// 'H' and 'W' don't separate consonants with the same code, while
+		// vowels do
+		if c != 'H' && c != 'W' {
+			last = digit
+		}
+	}
+
+	if len(code) == 0 {
+		return ""
+	}
+	for len(code) < 4 {
+		code = append(code, '0')
+	}
+	return string(code)
+}
+
+// Metaphone returns the phonetic code of word by original Metaphone
+// algorithm of Lawrence Philips, where '0' represents "th". Non-ASCII letters
+// are ignored
+func Metaphone(word string) string {
+	w := []byte{}
+	for i := 0; i < len(word); i++ {
+		c := word[i]
+		if c >= 'a' && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		if c >= 'A' && c <= 'Z' {
+			w = append(w, c)
+		}
+	}
+	if len(w) == 0 {
+		return ""
+	}
+
+	isVowel := func(c byte) bool {
+		return strings.IndexByte("AEIOU", c) >= 0
+	}
+	// at returns the letter at i, or 0 if out of range
+	at := func(i int) byte {
+		if i < 0 || i >= len(w) {
+			return 0
+		}
+		return w[i]
+	}
+
+	// Exceptions at the beginning of word
+	switch {
+	case bytes.HasPrefix(w, []byte("AE")),
+		bytes.HasPrefix(w, []byte("GN")),
+		bytes.HasPrefix(w, []byte("KN")),
+		bytes.HasPrefix(w, []byte("PN")),
+		bytes.HasPrefix(w, []byte("WR")):
+		w = w[1:]
+	case w[0] == 'X':
+		w[0] = 'S'
+	case bytes.HasPrefix(w, []byte("WH")):
+		w = append([]byte{'W'}, w[2:]...)
+	}
+
+	code := []byte{}
+	for i, c := range w {
+		// Drop duplicated adjacent letters except 'C'
+		if c == at(i-1) && c != 'C' {
+			continue
+		}
+
+		switch c {
+		case 'A', 'E', 'I', 'O', 'U':
+			if i == 0 {
+				code = append(code, c)
+			}
+		case 'B':
+			// Silent in "-MB"
+			if !(at(i-1) == 'M' && i == len(w)-1) {
+				code = append(code, 'B')
+			}
+		case 'C':
+			switch {
+			case at(i+1) == 'I' && at(i+2) == 'A':
+				code = append(code, 'X')
+			case at(i+1) == 'H':
+				if at(i-1) == 'S' {
+					code = append(code, 'K')
+				} else {
+					code = append(code, 'X')
+				}
+			case at(i+1) == 'I' || at(i+1) == 'E' || at(i+1) == 'Y':
+				if at(i-1) != 'S' {
+					code = append(code, 'S')
+				}
+			default:
+				code = append(code, 'K')
+			}
+		case 'D':
+			next := at(i + 2)
+			if at(i+1) == 'G' && (next == 'E' || next == 'Y' || next == 'I') {
+				code = append(code, 'J')
+			} else {
+				code = append(code, 'T')
+			}
+		case 'G':
+			next := at(i + 1)
+			switch {
+			case next == 'H' && !(i+2 == len(w) || isVowel(at(i+2))):
+				// Silent in "-GH-" not followed by a vowel
+			case next == 'N' && (i+2 == len(w) ||
+				(at(i+2) == 'E' && at(i+3) == 'D' && i+4 == len(w))):
+				// Silent in "-GN" and "-GNED"
+			case (next == 'I' || next == 'E' || next == 'Y') && at(i-1) != 'G':
+				code = append(code, 'J')
+			default:
+				code = append(code, 'K')
+			}
+		case 'H':
+			prev := at(i - 1)
+			afterVowel := isVowel(prev) && !isVowel(at(i+1))
+			afterLetter := strings.IndexByte("CSPTG", prev) >= 0
+			if !afterVowel && !afterLetter {
+				code = append(code, 'H')
+			}
+		case 'K':
+			if at(i-1) != 'C' {
+				code = append(code, 'K')
+			}
+		case 'P':
+			if at(i+1) == 'H' {
+				code = append(code, 'F')
+			} else {
+				code = append(code, 'P')
+			}
+		case 'Q':
+			code = append(code, 'K')
+		case 'S':
+			switch {
+			case at(i+1) == 'H':
+				code = append(code, 'X')
+			case at(i+1) == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				code = append(code, 'X')
+			default:
+				code = append(code, 'S')
+			}
+		case 'T':
+			switch {
+			case at(i+1) == 'I' && (at(i+2) == 'O' || at(i+2) == 'A'):
+				code = append(code, 'X')
+			case at(i+1) == 'H':
+				code = append(code, '0')
+			case at(i+1) == 'C' && at(i+2) == 'H':
+				// Silent in "-TCH-"
+			default:
+				code = append(code, 'T')
+			}
+		case 'V':
+			code = append(code, 'F')
+		case 'W', 'Y':
+			if isVowel(at(i + 1)) {
+				code = append(code, c)
+			}
+		case 'X':
+			code = append(code, 'K', 'S')
+		case 'Z':
+			code = append(code, 'S')
+		default:
+			// F, J, L, M, N, R
+			code = append(code, c)
+		}
+	}
+
+	return string(code)
+}
@@ -0,0 +1,131 @@
+package lexicon
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// PinyinStyle selects the variants of pinyin keys generated by ExpandPinyin
+type PinyinStyle int
+
+const (
+	// Syllables with tone numbers, e.g. "zhong1guo2"
+	PinyinTone PinyinStyle = 1 << iota
+
+	// Syllables without tones, e.g. "zhongguo"
+	PinyinToneless
+)
+
+// maxPinyinVariants limits the pinyin keys of a word, since each character
+// with several readings multiplies them
+const maxPinyinVariants = 64
+
+// ExpandPinyin expands words into pinyin keys for IME lexicons, by table
+// from hanzi to their readings with tone numbers, like "zhong1". Returns the
+// map from each pinyin key to its words in order. Syllables are joined by
+// sep, style selects tone and toneless variants, and characters with several
+// readings give one key for each combination, up to 64 keys per word.
+// Characters not in table are kept in keys as is. The result could be built
+// by BuildPayloads, or BuildStrings after joining the words
+func ExpandPinyin(
+	words []string,
+	table map[rune][]string,
+	style PinyinStyle,
+	sep string) map[string][]string {
+	keys := map[string][]string{}
+	for _, word := range words {
+		added := map[string]bool{}
+		for _, syllables := range pinyinVariants(word, table) {
+			variants := []string{}
+			if style&PinyinTone != 0 {
+				variants = append(variants, strings.Join(syllables, sep))
+			}
+			if style&PinyinToneless != 0 {
+				toneless := make([]string, len(syllables))
+				for i, s := range syllables {
+					toneless[i] = strings.TrimRight(s, "12345")
+				}
+				variants = append(variants, strings.Join(toneless, sep))
+			}
+
+			for _, key := range variants {
+				if key != "" && !added[key] {
+					added[key] = true
+					keys[key] = append(keys[key], word)
+				}
+			}
+		}
+	}
+
+	return keys
+}
+
+// pinyinVariants returns the syllables of each combination of readings of
+// characters in word, at most maxPinyinVariants of them
+func pinyinVariants(word string, table map[rune][]string) [][]string {
+	variants := [][]string{{}}
+	for _, r := range word {
+		readings, ok := table[r]
+		if !ok || len(readings) == 0 {
+			readings = []string{string(r)}
+		}
+
+		next := [][]string{}
+		for _, v := range variants {
+			for _, reading := range readings {
+				if len(next) == maxPinyinVariants {
+					break
+				}
+				syllables := append(append([]string{}, v...), reading)
+				next = append(next, syllables)
+			}
+		}
+		variants = next
+	}
+
+	return variants
+}
+
+// CedictPinyinTable reads CC-CEDICT and returns the readings of each hanzi
+// from single-character entries, for ExpandPinyin. Readings are in lower
+// case with tone numbers, where "u:" is written as "v" like in most IMEs.
+// Readings of each hanzi are ordered by their first appearance in file
+func CedictPinyinTable(r io.Reader) (map[rune][]string, error) {
+	entries, err := ReadCedict(r, CedictSimplified|CedictTraditional)
+	if err != nil {
+		return nil, err
+	}
+
+	// Headwords are iterated in order, so the result is the same for the
+	// same file
+	headwords := make([]string, 0, len(entries))
+	for headword := range entries {
+		headwords = append(headwords, headword)
+	}
+	sort.Strings(headwords)
+
+	table := map[rune][]string{}
+	for _, headword := range headwords {
+		if utf8.RuneCountInString(headword) != 1 {
+			continue
+		}
+		r, _ := utf8.DecodeRuneInString(headword)
+		for _, entry := range entries[headword] {
+			reading := strings.ToLower(strings.ReplaceAll(entry.Pinyin, "u:", "v"))
+			if strings.ContainsRune(reading, ' ') {
+				continue
+			}
+			exists := false
+			for _, existing := range table[r] {
+				exists = exists || existing == reading
+			}
+			if !exists {
+				table[r] = append(table[r], reading)
+			}
+		}
+	}
+
+	return table, nil
+}
@@ -0,0 +1,24 @@
+package lexicon
+
+import (
+	"math/rand"
+)
+
+// RandomKey returns a key chosen uniformly at random by rng, together with
+// its value. Returns false if Lexicon is empty. Every key has exactly one id
+// in slots, so it samples slots until hitting a key id, which takes about
+// NumSlots() / (number of keys) tries without any extra memory
+func (t *Lexicon) RandomKey(rng *rand.Rand) (key string, value int32, ok bool) {
+	// Every node leads to a key, so it is empty if the root has no child
+	root := InitialState()
+	if _, _, hasChild := t.childFrom(&root, 1); !hasChild {
+		return "", 0, false
+	}
+
+	for {
+		id := int32(rng.Intn(len(t.slots)))
+		if t.isKeyId(id) {
+			return string(t.keyAt(id)), t.valueAt(id), true
+		}
+	}
+}
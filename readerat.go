@@ -0,0 +1,183 @@
+package lexicon
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// CachedReaderAt caches the blocks read from an io.ReaderAt, and evicts the
+// least recently used ones when it is full. It is safe for concurrent use
+type CachedReaderAt struct {
+	r         io.ReaderAt
+	size      int64
+	blockSize int64
+	maxBlocks int
+
+	mutex  sync.Mutex
+	blocks map[int64]*list.Element
+	lru    *list.List
+}
+
+// cachedBlock is a block in CachedReaderAt
+type cachedBlock struct {
+	index int64
+	data  []byte
+}
+
+// defaultCachedBlockSize is the block size of CachedReaderAt if blockSize is
+// not positive
+const defaultCachedBlockSize = 64 << 10
+
+// defaultCachedMaxBlocks is the number of blocks of CachedReaderAt if
+// maxBlocks is not positive
+const defaultCachedMaxBlocks = 64
+
+// NewCachedReaderAt creates the CachedReaderAt of r with 'size' bytes, which
+// caches at most maxBlocks blocks of blockSize bytes. Non-positive blockSize
+// and maxBlocks are 64 KiB and 64 blocks
+func NewCachedReaderAt(r io.ReaderAt, size int64, blockSize, maxBlocks int) *CachedReaderAt {
+	if blockSize <= 0 {
+		blockSize = defaultCachedBlockSize
+	}
+	if maxBlocks <= 0 {
+		maxBlocks = defaultCachedMaxBlocks
+	}
+	return &CachedReaderAt{
+		r:         r,
+		size:      size,
+		blockSize: int64(blockSize),
+		maxBlocks: maxBlocks,
+		blocks:    map[int64]*list.Element{},
+		lru:       list.New(),
+	}
+}
+
+// ReadAt implements io.ReaderAt
+func (c *CachedReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n := 0
+	for n < len(p) {
+		if off >= c.size {
+			return n, io.EOF
+		}
+		block, err := c.block(off / c.blockSize)
+		if err != nil {
+			return n, err
+		}
+		copied := copy(p[n:], block[off%c.blockSize:])
+		n += copied
+		off += int64(copied)
+	}
+	return n, nil
+}
+
+// block returns the data of i-th block, reads it if not cached
+func (c *CachedReaderAt) block(i int64) ([]byte, error) {
+	c.mutex.Lock()
+	if e, ok := c.blocks[i]; ok {
+		c.lru.MoveToFront(e)
+		c.mutex.Unlock()
+		return e.Value.(*cachedBlock).data, nil
+	}
+	c.mutex.Unlock()
+
+	// Read without lock, so reads of different blocks don't wait for each
+	// other
+	begin := i * c.blockSize
+	end := begin + c.blockSize
+	if end > c.size {
+		end = c.size
+	}
+	data := make([]byte, end-begin)
+	if _, err := c.r.ReadAt(data, begin); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, ok := c.blocks[i]; !ok {
+		c.blocks[i] = c.lru.PushFront(&cachedBlock{i, data})
+		for c.lru.Len() > c.maxBlocks {
+			oldest := c.lru.Back()
+			c.lru.Remove(oldest)
+			delete(c.blocks, oldest.Value.(*cachedBlock).index)
+		}
+	}
+	return data, nil
+}
+
+// HTTPReaderAt reads a remote file by HTTP range requests, e.g. a lexicon on
+// object storage. Use it with CachedReaderAt to avoid a request for each read
+type HTTPReaderAt struct {
+	client *http.Client
+	url    string
+	size   int64
+}
+
+// NewHTTPReaderAt creates the HTTPReaderAt for url, and gets its size by a
+// HEAD request. client could be nil to use http.DefaultClient
+func NewHTTPReaderAt(client *http.Client, url string) (*HTTPReaderAt, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Head(url)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status: %s", url, resp.Status)
+	}
+	if resp.ContentLength < 0 {
+		return nil, fmt.Errorf("%s: unknown content length", url)
+	}
+
+	return &HTTPReaderAt{client, url, resp.ContentLength}, nil
+}
+
+// Size returns the size of remote file
+func (h *HTTPReaderAt) Size() int64 {
+	return h.size
+}
+
+// ReadAt implements io.ReaderAt
+func (h *HTTPReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off >= h.size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > h.size {
+		end = h.size
+	}
+	if end == off {
+		return 0, nil
+	}
+
+	req, err := http.NewRequest(http.MethodGet, h.url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", "bytes="+strconv.FormatInt(off, 10)+"-"+strconv.FormatInt(end-1, 10))
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("%s: unexpected status: %s", h.url, resp.Status)
+	}
+
+	n, err := io.ReadFull(resp.Body, p[:end-off])
+	if err == nil && int64(n) < int64(len(p)) {
+		err = io.EOF
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
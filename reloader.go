@@ -0,0 +1,116 @@
+package lexicon
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Reloader keeps a Lexicon loaded from file up to date. It polls the
+// modification time and size of file, loads the new version in background,
+// validates it, and then atomically swaps the active Lexicon. Lookups on the
+// Lexicon got before the swap are not affected
+type Reloader struct {
+	filename string
+	validate func(*Lexicon) error
+
+	current atomic.Value
+
+	// Guards the fields below, and makes sure only one reload at a time
+	mutex   sync.Mutex
+	modTime time.Time
+	size    int64
+	lastErr error
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReloader loads the Lexicon from filename and creates a Reloader for it.
+// validate is called on each newly loaded Lexicon before swapping, a non-nil
+// error rejects it. validate could be nil
+func NewReloader(
+	filename string,
+	validate func(*Lexicon) error) (*Reloader, error) {
+	r := &Reloader{
+		filename: filename,
+		validate: validate,
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Lexicon returns the active Lexicon
+func (r *Reloader) Lexicon() *Lexicon {
+	return r.current.Load().(*Lexicon)
+}
+
+// Reload loads and swaps the Lexicon if file changed since last load.
+// Returns whether the Lexicon is swapped. On error, the active Lexicon is
+// kept
+func (r *Reloader) Reload() (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	info, err := os.Stat(r.filename)
+	if err == nil && info.ModTime().Equal(r.modTime) && info.Size() == r.size {
+		return false, nil
+	}
+
+	var t *Lexicon
+	if err == nil {
+		t, err = Read(r.filename)
+	}
+	if err == nil && r.validate != nil {
+		err = r.validate(t)
+	}
+	r.lastErr = err
+	if err != nil {
+		return false, err
+	}
+
+	r.modTime = info.ModTime()
+	r.size = info.Size()
+	r.current.Store(t)
+	return true, nil
+}
+
+// LastError returns the error of the last reload, or nil if it succeeded
+func (r *Reloader) LastError() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.lastErr
+}
+
+// Start starts polling the file for changes every interval in background,
+// until Stop is called. Errors of reloading could be got by LastError
+func (r *Reloader) Start(interval time.Duration) {
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.Reload()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the background polling started by Start, and waits for it
+func (r *Reloader) Stop() {
+	if r.stop != nil {
+		close(r.stop)
+		<-r.done
+		r.stop = nil
+	}
+}
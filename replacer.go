@@ -0,0 +1,81 @@
+package lexicon
+
+import (
+	"io"
+	"strings"
+)
+
+// Replacer rewrites the keys of a Lexicon found in text, like
+// strings.Replacer but scales to millions of keys. Keys are matched by
+// longest-match, left-to-right strategy and matched text is never rescanned
+type Replacer struct {
+	lexicon *Lexicon
+	replace func(key string, value int32) string
+}
+
+// NewReplacer creates a Replacer of lexicon, each matched key is replaced by
+// the return value of replace(key, value). For example, to annotate keys:
+//
+//	NewReplacer(lexicon, func(key string, value int32) string {
+//	    return fmt.Sprintf("[%s/%d]", key, value)
+//	})
+func NewReplacer(
+	lexicon *Lexicon,
+	replace func(key string, value int32) string) *Replacer {
+	return &Replacer{
+		lexicon: lexicon,
+		replace: replace,
+	}
+}
+
+// Replace returns a copy of s with all matched keys replaced
+func (r *Replacer) Replace(s string) string {
+	var b strings.Builder
+	r.WriteString(&b, s)
+	return b.String()
+}
+
+// WriteString writes s to w with all matched keys replaced
+func (r *Replacer) WriteString(w io.Writer, s string) (n int, err error) {
+	sw, ok := w.(io.StringWriter)
+	if !ok {
+		sw = stringWriter{w}
+	}
+
+	// s[last:i] are the bytes not matched yet
+	last := 0
+	for i := 0; i < len(s); {
+		end, value, ok := r.lexicon.longestAt(s, i)
+		if !ok {
+			i++
+			continue
+		}
+
+		written, err := sw.WriteString(s[last:i])
+		n += written
+		if err != nil {
+			return n, err
+		}
+		written, err = sw.WriteString(r.replace(s[i:end], value))
+		n += written
+		if err != nil {
+			return n, err
+		}
+		i = end
+		last = end
+	}
+
+	written, err := sw.WriteString(s[last:])
+	n += written
+	return n, err
+}
+
+// stringWriter adapts io.Writer to io.StringWriter
+type stringWriter struct {
+	w io.Writer
+}
+
+// WriteString writes s to the underlying writer
+func (sw stringWriter) WriteString(s string) (int, error) {
+	return sw.w.Write([]byte(s))
+}
@@ -0,0 +1,404 @@
+package lexicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// rowTable stores the columns of keys in schema row by row, the i-th row is
+// data[offsets[i]:offsets[i+1]]. The value of a key in Lexicon is its row
+// index. Each field in row is encoded by its type:
+//   - ColumnString: length (uvarint) then bytes
+//   - ColumnInt32: varint
+//   - ColumnFloat32: IEEE 754 bits in little endian
+//   - ColumnEnum: index of value (uvarint)
+type rowTable struct {
+	schema  Schema
+	offsets []int32
+	data    []byte
+}
+
+// ReadCSVRows reads a CSV file with a header line for BuildRows. The field
+// in column 'keyColumn' is the key, and the other fields are the row of key.
+// Returns the names of other columns in order and the rows
+func ReadCSVRows(r io.Reader, keyColumn string) ([]string, map[string][]string, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("expect the header line")
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	keyIndex := -1
+	columns := []string{}
+	for i, name := range header {
+		if name == keyColumn && keyIndex < 0 {
+			keyIndex = i
+		} else {
+			columns = append(columns, name)
+		}
+	}
+	if keyIndex < 0 {
+		return nil, nil, fmt.Errorf("no key column %q in header", keyColumn)
+	}
+
+	dict := map[string][]string{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		key := record[keyIndex]
+		if _, ok := dict[key]; ok {
+			return nil, nil, fmt.Errorf("line %d: duplicated key: %q", line, key)
+		}
+		row := make([]string, 0, len(columns))
+		row = append(row, record[:keyIndex]...)
+		dict[key] = append(row, record[keyIndex+1:]...)
+	}
+
+	return columns, dict, nil
+}
+
+// BuildRows builds the reimu-trie from dict where each key carries one
+// string for each column in 'columns', e.g. the rows from ReadCSVRows. Rows
+// could be got by GetRow. The value of a key got by Get is its row index. It
+// is BuildSchema with string columns
+func BuildRows(
+	columns []string,
+	dict map[string][]string,
+	progress func(int, int),
+	opts ...Option) (*Lexicon, error) {
+	schema := make(Schema, len(columns))
+	for i, name := range columns {
+		schema[i] = Column{Name: name, Type: ColumnString}
+	}
+	return BuildSchema(schema, dict, progress, opts...)
+}
+
+// buildRowTable builds the row table of dict in schema, values of enum
+// columns without Values are collected from dict. Returns the table and the
+// map from key to its row index
+func buildRowTable(schema Schema, dict map[string][]string) (*rowTable, map[string]int32, error) {
+	table := &rowTable{
+		schema:  schema.clone(),
+		offsets: make([]int32, 0, len(dict)+1),
+		data:    []byte{},
+	}
+
+	// Rows are ordered by key, so the result is the same for the same dict
+	keys := make([]string, 0, len(dict))
+	for key, row := range dict {
+		if len(row) != len(schema) {
+			return nil, nil, &KeyError{key, fmt.Errorf(
+				"%w: expect %d but got %d",
+				ErrColumnCount,
+				len(schema),
+				len(row))}
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	enumIndex := make([]map[string]uint64, len(schema))
+	for i := range table.schema {
+		column := &table.schema[i]
+		if column.Type != ColumnEnum {
+			continue
+		}
+		if len(column.Values) == 0 {
+			unique := map[string]bool{}
+			for _, row := range dict {
+				unique[row[i]] = true
+			}
+			for value := range unique {
+				column.Values = append(column.Values, value)
+			}
+			sort.Strings(column.Values)
+		}
+		enumIndex[i] = map[string]uint64{}
+		for index, value := range column.Values {
+			enumIndex[i][value] = uint64(index)
+		}
+	}
+
+	rowIndex := make(map[string]int32, len(dict))
+	for _, key := range keys {
+		rowIndex[key] = int32(len(rowIndex))
+		table.offsets = append(table.offsets, int32(len(table.data)))
+		for i, value := range dict[key] {
+			var err error
+			table.data, err = appendField(table.data, table.schema[i], enumIndex[i], value)
+			if err != nil {
+				return nil, nil, &KeyError{key, fmt.Errorf(
+					"%w: column %q: %v",
+					ErrColumnValue,
+					table.schema[i].Name,
+					err)}
+			}
+		}
+	}
+	table.offsets = append(table.offsets, int32(len(table.data)))
+
+	return table, rowIndex, nil
+}
+
+// appendField appends value of column to data in the encoding of its type
+func appendField(data []byte, column Column, enumIndex map[string]uint64, value string) ([]byte, error) {
+	varint := make([]byte, binary.MaxVarintLen64)
+	switch column.Type {
+	case ColumnInt32:
+		v, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		return append(data, varint[:binary.PutVarint(varint, v)]...), nil
+	case ColumnFloat32:
+		v, err := strconv.ParseFloat(value, 32)
+		if err != nil {
+			return nil, err
+		}
+		binary.LittleEndian.PutUint32(varint, math.Float32bits(float32(v)))
+		return append(data, varint[:4]...), nil
+	case ColumnEnum:
+		index, ok := enumIndex[value]
+		if !ok {
+			return nil, fmt.Errorf("%q not in enum values", value)
+		}
+		return append(data, varint[:binary.PutUvarint(varint, index)]...), nil
+	default:
+		data = append(data, varint[:binary.PutUvarint(varint, uint64(len(value)))]...)
+		return append(data, value...), nil
+	}
+}
+
+// fieldSize returns the size of the field of column at the beginning of
+// data, or 0 if it is invalid
+func fieldSize(data []byte, column Column) int {
+	switch column.Type {
+	case ColumnInt32:
+		value, n := binary.Varint(data)
+		if n <= 0 || value < math.MinInt32 || value > math.MaxInt32 {
+			return 0
+		}
+		return n
+	case ColumnFloat32:
+		if len(data) < 4 {
+			return 0
+		}
+		return 4
+	case ColumnEnum:
+		index, n := binary.Uvarint(data)
+		if n <= 0 || index >= uint64(len(column.Values)) {
+			return 0
+		}
+		return n
+	default:
+		length, n := binary.Uvarint(data)
+		if n <= 0 || length > uint64(len(data)-n) {
+			return 0
+		}
+		return n + int(length)
+	}
+}
+
+// field returns the bytes of i-th field in row
+func (rt *rowTable) field(row int32, i int) []byte {
+	data := rt.data[rt.offsets[row]:rt.offsets[row+1]]
+	for j := 0; j < i; j++ {
+		data = data[fieldSize(data, rt.schema[j]):]
+	}
+	return data[:fieldSize(data, rt.schema[i])]
+}
+
+// int32 returns the i-th field in row as int32. Enum values are their
+// indexes, and string fields are parsed. Returns ok = false if it is not an
+// integer
+func (rt *rowTable) int32(row int32, i int) (value int32, ok bool) {
+	data := rt.field(row, i)
+	switch rt.schema[i].Type {
+	case ColumnInt32:
+		v, _ := binary.Varint(data)
+		return int32(v), true
+	case ColumnFloat32:
+		return 0, false
+	case ColumnEnum:
+		index, _ := binary.Uvarint(data)
+		return int32(index), true
+	default:
+		_, n := binary.Uvarint(data)
+		v, err := strconv.ParseInt(string(data[n:]), 10, 32)
+		return int32(v), err == nil
+	}
+}
+
+// float32 returns the i-th field in row as float32. Int32 fields are
+// converted, and string fields are parsed. Returns ok = false if it is not a
+// number
+func (rt *rowTable) float32(row int32, i int) (value float32, ok bool) {
+	data := rt.field(row, i)
+	switch rt.schema[i].Type {
+	case ColumnInt32:
+		v, _ := binary.Varint(data)
+		return float32(v), true
+	case ColumnFloat32:
+		return math.Float32frombits(binary.LittleEndian.Uint32(data)), true
+	case ColumnEnum:
+		return 0, false
+	default:
+		_, n := binary.Uvarint(data)
+		v, err := strconv.ParseFloat(string(data[n:]), 32)
+		return float32(v), err == nil
+	}
+}
+
+// string returns the i-th field in row as string
+func (rt *rowTable) string(row int32, i int) string {
+	data := rt.field(row, i)
+	switch rt.schema[i].Type {
+	case ColumnInt32:
+		v, _ := binary.Varint(data)
+		return strconv.FormatInt(v, 10)
+	case ColumnFloat32:
+		v := math.Float32frombits(binary.LittleEndian.Uint32(data))
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case ColumnEnum:
+		index, _ := binary.Uvarint(data)
+		return rt.schema[i].Values[index]
+	default:
+		_, n := binary.Uvarint(data)
+		return string(data[n:])
+	}
+}
+
+// clone returns a deep copy of row table
+func (rt *rowTable) clone() *rowTable {
+	return &rowTable{
+		schema:  rt.schema.clone(),
+		offsets: append([]int32{}, rt.offsets...),
+		data:    append([]byte{}, rt.data...),
+	}
+}
+
+// marshal encodes the row table into bytes: number of columns, name, type
+// and enum values of each column, number of offsets, offsets and then data
+func (rt *rowTable) marshal() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var err error
+	binaryWrite := func(data interface{}) {
+		if err == nil {
+			err = binary.Write(buf, binary.LittleEndian, data)
+		}
+	}
+	writeString := func(s string) {
+		binaryWrite(int32(len(s)))
+		binaryWrite([]byte(s))
+	}
+
+	binaryWrite(int32(len(rt.schema)))
+	for _, column := range rt.schema {
+		writeString(column.Name)
+		binaryWrite(column.Type)
+		binaryWrite(int32(len(column.Values)))
+		for _, value := range column.Values {
+			writeString(value)
+		}
+	}
+	binaryWrite(int32(len(rt.offsets)))
+	binaryWrite(rt.offsets)
+	if err != nil {
+		return nil, err
+	}
+
+	buf.Write(rt.data)
+	return buf.Bytes(), nil
+}
+
+// readRowTable reads the row table from payload of its section
+func readRowTable(payload []byte) (*rowTable, error) {
+	r := bytes.NewReader(payload)
+	var err error
+	binaryRead := func(data interface{}) {
+		if err == nil {
+			err = binary.Read(r, binary.LittleEndian, data)
+		}
+	}
+	readCount := func(min int32) int32 {
+		var n int32
+		binaryRead(&n)
+		if err == nil && (n < min || int(n) > r.Len()/4) {
+			err = ErrCorrupted
+		}
+		return n
+	}
+	readString := func() string {
+		var length int32
+		binaryRead(&length)
+		if err == nil && (length < 0 || int(length) > r.Len()) {
+			err = ErrCorrupted
+		}
+		if err != nil {
+			return ""
+		}
+		s := make([]byte, length)
+		binaryRead(&s)
+		return string(s)
+	}
+
+	numColumns := readCount(0)
+	if err != nil {
+		return nil, err
+	}
+	rt := &rowTable{schema: make(Schema, numColumns)}
+	for i := range rt.schema {
+		column := &rt.schema[i]
+		column.Name = readString()
+		binaryRead(&column.Type)
+		if err == nil && column.Type > ColumnEnum {
+			return nil, ErrCorrupted
+		}
+		numValues := readCount(0)
+		if err != nil {
+			return nil, err
+		}
+		column.Values = make([]string, numValues)
+		for j := range column.Values {
+			column.Values[j] = readString()
+		}
+	}
+
+	numOffsets := readCount(1)
+	if err == nil {
+		rt.offsets = make([]int32, numOffsets)
+		binaryRead(&rt.offsets)
+	}
+	if err != nil {
+		return nil, err
+	}
+	rt.data = payload[len(payload)-r.Len():]
+
+	// Each row should be inside data and have exactly the fields of schema
+	previous := int32(0)
+	for i, offset := range rt.offsets {
+		if offset < previous || int(offset) > len(rt.data) {
+			return nil, ErrCorrupted
+		}
+		if i > 0 && !rt.schema.validRow(rt.data[previous:offset]) {
+			return nil, ErrCorrupted
+		}
+		previous = offset
+	}
+
+	return rt, nil
+}
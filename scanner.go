@@ -0,0 +1,128 @@
+package lexicon
+
+import (
+	"io"
+)
+
+// scannerBufferSize is the initial size of buffer in Scanner
+const scannerBufferSize = 4096
+
+// maxEmptyReads is the max number of consecutive reads returning no data
+// and no error, after that Scanner fails with io.ErrNoProgress
+const maxEmptyReads = 100
+
+// Scanner finds the occurrences of keys in text from io.Reader, like FindAll
+// but for streams of any length, e.g. logs or large files. Matches are got
+// one by one by Scan and Match, their Start and End are the byte offsets in
+// stream. Only the bytes which could still begin a key are kept in buffer,
+// so memory is bounded by the longest key in Lexicon
+type Scanner struct {
+	lexicon *Lexicon
+	r       io.Reader
+
+	// buf[0] is at offset in stream, matches beginning before buf[start]
+	// are already found
+	buf    []byte
+	offset int64
+	start  int
+
+	eof     bool
+	err     error
+	pending []Match
+	match   Match
+}
+
+// NewScanner creates a Scanner finding the keys of lexicon in r
+func NewScanner(lexicon *Lexicon, r io.Reader) *Scanner {
+	return &Scanner{
+		lexicon: lexicon,
+		r:       r,
+		buf:     make([]byte, 0, scannerBufferSize),
+	}
+}
+
+// Scan advances to the next match, which is got by Match. Matches are
+// ordered by start offset then by end offset as FindAll. Returns false at the
+// end of stream or on a read error, which is got by Err
+func (s *Scanner) Scan() bool {
+	for len(s.pending) == 0 {
+		if s.start == len(s.buf) {
+			if s.eof {
+				return false
+			}
+			s.fill()
+			continue
+		}
+		s.scanAt()
+		s.start++
+	}
+
+	s.match = s.pending[0]
+	s.pending = s.pending[1:]
+	return true
+}
+
+// Match returns the match got by the last Scan
+func (s *Scanner) Match() Match {
+	return s.match
+}
+
+// Err returns the first error of reading, except io.EOF
+func (s *Scanner) Err() error {
+	return s.err
+}
+
+// scanAt adds the keys beginning at buf[start] to pending. Bytes are read
+// into buffer when a key may go across the end of buffer
+func (s *Scanner) scanAt() {
+	state := InitialState()
+	for n := 1; ; n++ {
+		for s.start+n > len(s.buf) && !s.eof {
+			s.fill()
+		}
+		if s.start+n > len(s.buf) || !s.lexicon.next(&state, s.buf[s.start+n-1]) {
+			return
+		}
+		if value, ok := s.lexicon.value(&state); ok {
+			start := s.offset + int64(s.start)
+			s.pending = append(s.pending, Match{
+				Start: int(start),
+				End:   int(start) + n,
+				Key:   string(s.buf[s.start : s.start+n]),
+				Value: value,
+			})
+		}
+	}
+}
+
+// fill drops the bytes before start from buffer and reads more bytes into
+// it. The buffer grows when it is full
+func (s *Scanner) fill() {
+	if s.start > 0 {
+		s.buf = s.buf[:copy(s.buf, s.buf[s.start:])]
+		s.offset += int64(s.start)
+		s.start = 0
+	}
+	if len(s.buf) == cap(s.buf) {
+		buf := make([]byte, len(s.buf), 2*cap(s.buf))
+		copy(buf, s.buf)
+		s.buf = buf
+	}
+
+	for i := 0; i < maxEmptyReads; i++ {
+		n, err := s.r.Read(s.buf[len(s.buf):cap(s.buf)])
+		s.buf = s.buf[:len(s.buf)+n]
+		if err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			s.eof = true
+			return
+		}
+		if n > 0 {
+			return
+		}
+	}
+	s.err = io.ErrNoProgress
+	s.eof = true
+}
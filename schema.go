@@ -0,0 +1,238 @@
+package lexicon
+
+import (
+	"fmt"
+)
+
+// ColumnType is the type of a column in Schema
+type ColumnType byte
+
+const (
+	ColumnString ColumnType = iota
+	ColumnInt32
+	ColumnFloat32
+
+	// ColumnEnum is a string from a small set of values, e.g. POS tags,
+	// which is stored as the index of value
+	ColumnEnum
+)
+
+// String returns the name of column type
+func (c ColumnType) String() string {
+	switch c {
+	case ColumnString:
+		return "string"
+	case ColumnInt32:
+		return "int32"
+	case ColumnFloat32:
+		return "float32"
+	case ColumnEnum:
+		return "enum"
+	}
+	return fmt.Sprintf("ColumnType(%d)", byte(c))
+}
+
+// Column is a column in Schema
+type Column struct {
+	Name string
+	Type ColumnType
+
+	// Values of ColumnEnum. If empty, they are collected from the rows in
+	// BuildSchema
+	Values []string
+}
+
+// Schema is the columns of rows in Lexicon, which is stored in file, so
+// consumers get columns by name and type rather than by index
+type Schema []Column
+
+// clone returns a deep copy of schema
+func (s Schema) clone() Schema {
+	c := make(Schema, len(s))
+	for i, column := range s {
+		c[i] = column
+		if column.Values != nil {
+			c[i].Values = append([]string{}, column.Values...)
+		}
+	}
+	return c
+}
+
+// validRow returns true if data is exactly the fields of schema
+func (s Schema) validRow(data []byte) bool {
+	for _, column := range s {
+		n := fieldSize(data, column)
+		if n == 0 {
+			return false
+		}
+		data = data[n:]
+	}
+	return len(data) == 0
+}
+
+// BuildSchema builds the reimu-trie from dict where each key carries one
+// value for each column in schema, e.g. the rows from ReadCSVRows. Values are
+// parsed by the types of columns, and KeyError with ErrColumnValue is
+// returned for invalid ones. Rows could be got by GetRow or the typed fields
+// like Int32Field. The value of a key got by Get is its row index
+func BuildSchema(
+	schema Schema,
+	dict map[string][]string,
+	progress func(int, int),
+	opts ...Option) (*Lexicon, error) {
+	table, rowIndex, err := buildRowTable(schema, dict)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := Build(rowIndex, progress, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t.rows = table
+	return t, nil
+}
+
+// Schema returns the schema of rows in Lexicon, or nil if it is not built by
+// BuildSchema, BuildRows or BuildColumns. Columns of BuildColumns are int32
+func (t *Lexicon) Schema() Schema {
+	if t.rows != nil {
+		return t.rows.schema.clone()
+	} else if t.columns != nil {
+		schema := make(Schema, len(t.columns.names))
+		for i, name := range t.columns.names {
+			schema[i] = Column{Name: name, Type: ColumnInt32}
+		}
+		return schema
+	}
+	return nil
+}
+
+// column returns the index of column 'name' with type 'columnType' in
+// Lexicon
+func (t *Lexicon) column(name string, columnType ColumnType) (int, error) {
+	for i, column := range t.Schema() {
+		if column.Name != name {
+			continue
+		}
+		if column.Type != columnType {
+			return 0, fmt.Errorf(
+				"%w: column %q is %s but not %s",
+				ErrColumnType,
+				name,
+				column.Type,
+				columnType)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnknownColumn, name)
+}
+
+// Int32Field gets the values of an int32 column by key
+type Int32Field struct {
+	t     *Lexicon
+	index int
+}
+
+// Int32Field returns the field of int32 column 'name'. Returns
+// ErrUnknownColumn if no such column, or ErrColumnType if it is not int32
+func (t *Lexicon) Int32Field(name string) (Int32Field, error) {
+	index, err := t.column(name, ColumnInt32)
+	return Int32Field{t, index}, err
+}
+
+// Get gets the value of field by key. On success, returns (value, true)
+func (f Int32Field) Get(key string) (value int32, ok bool) {
+	row, ok := f.t.GetRow(key)
+	if !ok {
+		return 0, false
+	}
+	return row.Column(f.index), true
+}
+
+// Float32Field gets the values of a float32 column by key
+type Float32Field struct {
+	t     *Lexicon
+	index int
+}
+
+// Float32Field returns the field of float32 column 'name'. Returns
+// ErrUnknownColumn if no such column, or ErrColumnType if it is not float32
+func (t *Lexicon) Float32Field(name string) (Float32Field, error) {
+	index, err := t.column(name, ColumnFloat32)
+	return Float32Field{t, index}, err
+}
+
+// Get gets the value of field by key. On success, returns (value, true)
+func (f Float32Field) Get(key string) (value float32, ok bool) {
+	row, ok := f.t.GetRow(key)
+	if !ok {
+		return 0, false
+	}
+	value, _ = f.t.rows.float32(row.row, f.index)
+	return value, true
+}
+
+// StringField gets the values of a string column by key
+type StringField struct {
+	t     *Lexicon
+	index int
+}
+
+// StringField returns the field of string column 'name'. Returns
+// ErrUnknownColumn if no such column, or ErrColumnType if it is not string
+func (t *Lexicon) StringField(name string) (StringField, error) {
+	index, err := t.column(name, ColumnString)
+	return StringField{t, index}, err
+}
+
+// Get gets the value of field by key. On success, returns (value, true)
+func (f StringField) Get(key string) (value string, ok bool) {
+	row, ok := f.t.GetRow(key)
+	if !ok {
+		return "", false
+	}
+	return row.Field(f.index), true
+}
+
+// EnumField gets the values of an enum column by key
+type EnumField struct {
+	t      *Lexicon
+	index  int
+	values []string
+}
+
+// EnumField returns the field of enum column 'name'. Returns
+// ErrUnknownColumn if no such column, or ErrColumnType if it is not enum
+func (t *Lexicon) EnumField(name string) (EnumField, error) {
+	index, err := t.column(name, ColumnEnum)
+	if err != nil {
+		return EnumField{}, err
+	}
+	return EnumField{t, index, t.rows.schema[index].Values}, nil
+}
+
+// Values returns all values of the enum column
+func (f EnumField) Values() []string {
+	return append([]string{}, f.values...)
+}
+
+// Get gets the value of field by key. On success, returns (value, true)
+func (f EnumField) Get(key string) (value string, ok bool) {
+	index, ok := f.Index(key)
+	if !ok {
+		return "", false
+	}
+	return f.values[index], true
+}
+
+// Index gets the index of value in Values by key. On success, returns
+// (index, true)
+func (f EnumField) Index(key string) (index int, ok bool) {
+	row, ok := f.t.GetRow(key)
+	if !ok {
+		return 0, false
+	}
+	return int(row.Column(f.index)), true
+}
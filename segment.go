@@ -0,0 +1,38 @@
+package lexicon
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Segment splits text into tokens by forward maximum matching: from the
+// beginning of text, the longest key at each position is taken as a token.
+// Where no key matches, a run of ASCII letters and digits, or otherwise one
+// UTF-8 character, is a token. White spaces separate tokens and are dropped
+func (t *Lexicon) Segment(text string) []string {
+	tokens := []string{}
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		end, _, ok := t.longestAt(text, i)
+		if !ok {
+			end = i + size
+			for end < len(text) && isASCIIAlnum(text[i]) && isASCIIAlnum(text[end]) {
+				end++
+			}
+		}
+		tokens = append(tokens, text[i:end])
+		i = end
+	}
+
+	return tokens
+}
+
+// isASCIIAlnum returns true if b is an ASCII letter or digit
+func isASCIIAlnum(b byte) bool {
+	return b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
@@ -0,0 +1,33 @@
+package lexicon
+
+// BuildSet builds the reimu-trie from keys without values, for set
+// membership like stopwords or blocklists. Values of keys are all 0, and the
+// file is smaller since no values of suffixes are stored
+func BuildSet(
+	keys []string,
+	progress func(int, int),
+	opts ...Option) (*Lexicon, error) {
+	dict := make(map[string]int32, len(keys))
+	for _, key := range keys {
+		dict[key] = 0
+	}
+
+	t, err := Build(dict, progress, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t.flags |= flagSet
+	return t, nil
+}
+
+// IsSet returns true if Lexicon has only keys, that is, built by BuildSet
+func (t *Lexicon) IsSet() bool {
+	return t.flags&flagSet != 0
+}
+
+// Contains returns true if key exists in Lexicon
+func (t *Lexicon) Contains(key string) bool {
+	_, ok := t.Get(key)
+	return ok
+}
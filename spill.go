@@ -0,0 +1,252 @@
+package lexicon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"sort"
+)
+
+// trieNodeBytes is the approximate memory of a _Trie node together with the
+// edge to it, used to estimate the memory of trie in building
+const trieNodeBytes = 96
+
+// Flags of a node in spill file
+const (
+	spillHasSuffix byte = 1 << iota
+	spillHasValue
+)
+
+// spillRange is the range of a spilled subtree in spill file
+type spillRange struct {
+	offset int64
+	length int64
+}
+
+// trieSpill stores the subtrees of root children in a temporary file, when
+// the trie in building exceeds the memory limit
+type trieSpill struct {
+	file   *os.File
+	size   int64
+	ranges map[byte]spillRange
+
+	// Number of nodes in spilled subtrees except their roots, which are kept
+	// in trie as placeholders
+	nodes int
+}
+
+// WithBuildMemoryLimit limits the memory of the intermediate trie in Build
+// to about n bytes. Once exceeded, completed subtrees are spilled to a
+// temporary file and read back one by one when placing them into double
+// array. The unit of spilling is the subtree of a first byte, so a single
+// huge subtree could still exceed the limit. 0 means no limit, which is the
+// default
+func WithBuildMemoryLimit(n int64) Option {
+	return func(o *buildOptions) {
+		o.memoryLimit = n
+	}
+}
+
+// buildSpilledTrie is buildTrie under memory limit. It builds the subtrees
+// of root children in order of keys, then spills all subtrees in memory once
+// they exceed the limit
+func buildSpilledTrie(
+	dict map[string]int32,
+	keepSuffix func(suffix []byte) bool,
+	limit int64) (*_Trie, *trieSpill, error) {
+	keys := make([]string, 0, len(dict))
+	for key := range dict {
+		if err := checkKey(key); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	spill := &trieSpill{ranges: map[byte]spillRange{}}
+	trie := &_Trie{}
+	held := []trieEdge{}
+	var heldBytes int64
+	for i := 0; i < len(keys); {
+		label := keys[i][0]
+		arena := &trieArena{}
+		node := arena.newNode()
+		var keyBytes int64
+		for ; i < len(keys) && keys[i][0] == label; i++ {
+			node.add(arena, []byte(keys[i][1:]), dict[keys[i]])
+			keyBytes += int64(len(keys[i]))
+		}
+		if keepSuffix != nil {
+			node.expandSuffix(arena, keepSuffix)
+		}
+
+		trie.addChild(label, node)
+		held = append(held, trieEdge{label, node})
+		heldBytes += int64(node.countNode())*trieNodeBytes + keyBytes
+		if heldBytes > limit {
+			for _, edge := range held {
+				if err := spill.store(edge.label, edge.node); err != nil {
+					spill.close()
+					return nil, nil, err
+				}
+				trie.children[trie.search(edge.label)].node = &_Trie{}
+			}
+			held = held[:0]
+			heldBytes = 0
+		}
+	}
+
+	return trie, spill, nil
+}
+
+// store writes the subtree of label into spill file
+func (s *trieSpill) store(label byte, node *_Trie) error {
+	if s.file == nil {
+		file, err := os.CreateTemp("", "lexicon-spill-*")
+		if err != nil {
+			return err
+		}
+		s.file = file
+	}
+
+	buf := &bytes.Buffer{}
+	writeSpilledTrie(buf, node)
+	if _, err := s.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+
+	s.ranges[label] = spillRange{s.size, int64(buf.Len())}
+	s.size += int64(buf.Len())
+	s.nodes += node.countNode() - 1
+	return nil
+}
+
+// load reads the subtree of label from spill file, or returns nil if it is
+// not spilled
+func (s *trieSpill) load(label byte) (*_Trie, error) {
+	r, ok := s.ranges[label]
+	if !ok {
+		return nil, nil
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(s.file, r.offset, r.length))
+	return readSpilledTrie(reader, &trieArena{})
+}
+
+// countNode returns the number of nodes in spilled subtrees except their
+// roots. s could be nil
+func (s *trieSpill) countNode() int {
+	if s == nil {
+		return 0
+	}
+	return s.nodes
+}
+
+// close removes the spill file. s could be nil
+func (s *trieSpill) close() {
+	if s == nil || s.file == nil {
+		return
+	}
+	s.file.Close()
+	os.Remove(s.file.Name())
+	s.file = nil
+}
+
+// writeSpilledTrie encodes node and its descendants into buf, each node is:
+// flags, value, suffix and children
+func writeSpilledTrie(buf *bytes.Buffer, node *_Trie) {
+	var flags byte
+	if node.hasSuffix {
+		flags |= spillHasSuffix
+	}
+	if node.hasValue {
+		flags |= spillHasValue
+	}
+	buf.WriteByte(flags)
+
+	varint := make([]byte, binary.MaxVarintLen64)
+	buf.Write(varint[:binary.PutVarint(varint, int64(node.value))])
+	buf.Write(varint[:binary.PutUvarint(varint, uint64(len(node.suffix)))])
+	buf.Write(node.suffix)
+	buf.Write(varint[:binary.PutUvarint(varint, uint64(len(node.children)))])
+	for _, child := range node.children {
+		buf.WriteByte(child.label)
+		writeSpilledTrie(buf, child.node)
+	}
+}
+
+// readSpilledTrie decodes the node written by writeSpilledTrie, new nodes are
+// allocated from arena
+func readSpilledTrie(r *bufio.Reader, arena *trieArena) (*_Trie, error) {
+	flags, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	value, err := binary.ReadVarint(r)
+	if err != nil {
+		return nil, err
+	}
+	suffixLen, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	node := arena.newNode()
+	node.hasSuffix = flags&spillHasSuffix != 0
+	node.hasValue = flags&spillHasValue != 0
+	node.value = int32(value)
+	if suffixLen > 0 {
+		node.suffix = make([]byte, suffixLen)
+		if _, err = io.ReadFull(r, node.suffix); err != nil {
+			return nil, err
+		}
+	}
+
+	numChildren, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if numChildren > 256 {
+		return nil, ErrCorrupted
+	}
+	node.children = make([]trieEdge, 0, numChildren)
+	for i := 0; i < int(numChildren); i++ {
+		label, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		child, err := readSpilledTrie(r, arena)
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, trieEdge{label, child})
+	}
+
+	return node, nil
+}
+
+// buildSpilled is build of root with spilled subtrees, which are read back
+// one at a time, so only one of them is in memory
+func (t *Lexicon) buildSpilled(
+	root *_Trie,
+	spill *trieSpill,
+	progress func(int, int)) (int32, error) {
+	t.visit(progress)
+	base := t.place(root, 0)
+	for _, child := range root.children {
+		node, err := spill.load(child.label)
+		if err != nil {
+			return 0, err
+		}
+		if node == nil {
+			node = child.node
+		}
+
+		s := base ^ int(child.label)
+		t.slots[s].Base = t.build(node, int32(s), progress)
+	}
+
+	return int32(base), nil
+}
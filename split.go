@@ -0,0 +1,251 @@
+package lexicon
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Files of the split layout in its directory
+const (
+	splitManifestFile = "manifest.json"
+	splitSlotsFile    = "slots.bin"
+	splitSuffixFile   = "suffix.bin"
+	splitSectionsFile = "sections.bin"
+)
+
+// splitManifest describes the files of the split layout. Slots file is the
+// raw little endian slot array, so it could be mapped into memory as is.
+// Suffix file is suffixIndex, suffixValue and then suffix bytes. Sections file
+// is the optional sections as in a single file
+type splitManifest struct {
+	Version        string `json:"version"`
+	Flags          uint32 `json:"flags"`
+	NumSlots       int32  `json:"num_slots"`
+	NumSuffix      int32  `json:"num_suffix"`
+	NumSuffixBytes int64  `json:"num_suffix_bytes"`
+	SlotsCRC32     uint32 `json:"slots_crc32"`
+	SuffixCRC32    uint32 `json:"suffix_crc32"`
+}
+
+// SaveSplit saves the reimu-trie into directory dir as separate files: a
+// manifest, the slot array, the suffixes and the optional sections. The hot
+// slot array could be mapped or pinned in memory, while the colder suffixes
+// stay on disk. dir is created if not exists. Use ReadSplit to read it
+func (t *Lexicon) SaveSplit(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Manifest is removed first and written at last, so a partially saved
+	// directory could not be read
+	err := os.Remove(filepath.Join(dir, splitManifestFile))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	slots := t.trimmedSlots()
+	if err = checkLength("slots", len(slots)); err != nil {
+		return err
+	}
+	manifest := splitManifest{
+		Version:        Header,
+		Flags:          t.flags,
+		NumSlots:       int32(len(slots)),
+		NumSuffix:      int32(len(t.suffixIndex)),
+		NumSuffixBytes: int64(len(t.suffix)),
+	}
+	suffix64 := checkLength("suffix bytes", len(t.suffix)) != nil
+	if suffix64 {
+		manifest.Flags |= flagSuffix64
+	}
+
+	manifest.SlotsCRC32, err = writeSplitFile(
+		filepath.Join(dir, splitSlotsFile),
+		func(w io.Writer) error {
+			return binary.Write(w, binary.LittleEndian, slots)
+		})
+	if err != nil {
+		return err
+	}
+	manifest.SuffixCRC32, err = writeSplitFile(
+		filepath.Join(dir, splitSuffixFile),
+		func(w io.Writer) error {
+			err := binary.Write(w, binary.LittleEndian, t.suffixIndexOf(suffix64))
+			if err == nil {
+				err = binary.Write(w, binary.LittleEndian, t.suffixValue)
+			}
+			if err == nil {
+				_, err = w.Write(t.suffix)
+			}
+			return err
+		})
+	if err != nil {
+		return err
+	}
+	_, err = writeSplitFile(
+		filepath.Join(dir, splitSectionsFile),
+		func(w io.Writer) error {
+			checksum := crc32.NewIEEE()
+			return t.writeSections(io.MultiWriter(w, checksum), checksum)
+		})
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, splitManifestFile), data, 0644)
+}
+
+// writeSplitFile creates file filename and writes it by write. Returns the
+// CRC-32 of the file
+func writeSplitFile(filename string, write func(w io.Writer) error) (uint32, error) {
+	fd, err := os.Create(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer fd.Close()
+
+	checksum := crc32.NewIEEE()
+	bw := bufio.NewWriter(fd)
+	err = write(io.MultiWriter(bw, checksum))
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = fd.Close()
+	}
+	return checksum.Sum32(), err
+}
+
+// ReadSplit reads reimu-trie saved by SaveSplit from directory dir
+func ReadSplit(dir string, opts ...ReadOption) (*Lexicon, error) {
+	t, err := readSplit(dir, newReadOptions(opts))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, err)
+	}
+	return t, nil
+}
+
+// readSplit reads reimu-trie from the files in dir
+func readSplit(dir string, options *readOptions) (*Lexicon, error) {
+	data, err := os.ReadFile(filepath.Join(dir, splitManifestFile))
+	if err != nil {
+		return nil, err
+	}
+	manifest := splitManifest{}
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCorruptHeader, err)
+	}
+	if manifest.Version != Header || manifest.Flags&^knownFlags != 0 {
+		return nil, ErrUnsupportedVersion
+	}
+
+	// The budget is the total size of files
+	budget := &readBudget{memoryLimit: options.memoryLimit}
+	for _, name := range []string{splitSlotsFile, splitSuffixFile, splitSectionsFile} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		budget.size += info.Size()
+	}
+	if manifest.NumSlots < 0 || manifest.NumSuffix < 0 || manifest.NumSuffixBytes < 0 {
+		return nil, ErrCorrupted
+	}
+	suffix64 := manifest.Flags&flagSuffix64 != 0
+	suffixIndexSize := int64(4)
+	if suffix64 {
+		suffixIndexSize = 8
+	}
+	err = budget.alloc(int64(manifest.NumSlots)*8 +
+		int64(manifest.NumSuffix)*(suffixIndexSize+4) +
+		manifest.NumSuffixBytes)
+	if err == nil {
+		// suffixIndex is int64 in memory
+		err = budget.decode(int64(manifest.NumSuffix) * (8 - suffixIndexSize))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Lexicon{
+		flags:       manifest.Flags &^ flagSuffix64,
+		slots:       make([]slotT, manifest.NumSlots),
+		suffixValue: make([]int32, manifest.NumSuffix),
+		suffix:      make([]byte, manifest.NumSuffixBytes),
+	}
+	err = readSplitFile(
+		filepath.Join(dir, splitSlotsFile),
+		manifest.SlotsCRC32,
+		func(r io.Reader) error {
+			return binary.Read(r, binary.LittleEndian, t.slots)
+		})
+	if err == nil {
+		err = readSplitFile(
+			filepath.Join(dir, splitSuffixFile),
+			manifest.SuffixCRC32,
+			func(r io.Reader) error {
+				var err error
+				t.suffixIndex, err = readSuffixIndex(r, manifest.NumSuffix, suffix64, nil)
+				if err == nil {
+					err = binary.Read(r, binary.LittleEndian, t.suffixValue)
+				}
+				if err == nil {
+					_, err = io.ReadFull(r, t.suffix)
+				}
+				return err
+			})
+	}
+	if err == nil {
+		err = t.verifySuffix()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Sections file has its own checksum section
+	fd, err := os.Open(filepath.Join(dir, splitSectionsFile))
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	checksum := crc32.NewIEEE()
+	err = t.readSections(io.TeeReader(bufio.NewReader(fd), checksum), checksum, budget)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// readSplitFile reads file filename by read, and checks that the file is
+// fully read and its CRC-32 is expected
+func readSplitFile(filename string, expected uint32, read func(r io.Reader) error) error {
+	fd, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	checksum := crc32.NewIEEE()
+	r := io.TeeReader(bufio.NewReader(fd), checksum)
+	if err = read(r); err != nil {
+		return err
+	}
+	if n, _ := io.Copy(io.Discard, r); n != 0 {
+		return ErrCorrupted
+	}
+	if checksum.Sum32() != expected {
+		return ErrChecksum
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package lexicon
+
+import (
+	"bufio"
+	"unicode"
+	"unicode/utf8"
+)
+
+// SplitFunc returns a bufio.SplitFunc which splits input into the tokens of
+// Segment, so Lexicon could tokenize a stream by bufio.Scanner:
+//
+//	scanner := bufio.NewScanner(r)
+//	scanner.Split(lexicon.SplitFunc())
+//	for scanner.Scan() {
+//	    token := scanner.Text()
+//	}
+//
+// More data is requested while a key or a run of ASCII letters and digits
+// may go across the end of buffer, so tokens are the same as Segment no
+// matter how the input is read
+func (t *Lexicon) SplitFunc() bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		// Skip white spaces
+		start := 0
+		for start < len(data) {
+			if !atEOF && !utf8.FullRune(data[start:]) {
+				return start, nil, nil
+			}
+			r, size := utf8.DecodeRune(data[start:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			start += size
+		}
+		if start == len(data) {
+			return start, nil, nil
+		}
+
+		end, ok, more := t.longestPrefixBytes(data[start:])
+		if more && !atEOF {
+			return start, nil, nil
+		}
+		end += start
+		if !ok {
+			_, size := utf8.DecodeRune(data[start:])
+			end = start + size
+			for end < len(data) && isASCIIAlnum(data[start]) && isASCIIAlnum(data[end]) {
+				end++
+			}
+			if end == len(data) && isASCIIAlnum(data[start]) && !atEOF {
+				return start, nil, nil
+			}
+		}
+		return end, data[start:end], nil
+	}
+}
+
+// longestPrefixBytes returns the end of longest key which is a prefix of
+// data. more is true if the traversal reaches the end of data, that is, a
+// longer key may be found with more data
+func (t *Lexicon) longestPrefixBytes(data []byte) (end int, ok bool, more bool) {
+	s := InitialState()
+	for i := 0; i < len(data); i++ {
+		if !t.next(&s, data[i]) {
+			return end, ok, false
+		}
+		if _, found := t.value(&s); found {
+			end, ok = i+1, true
+		}
+	}
+	return end, ok, true
+}
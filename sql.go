@@ -0,0 +1,79 @@
+package lexicon
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// quoteIdentifier quotes the table or column name in SQL
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// ReadSQL reads the dict for Build from table of db, with keys in column
+// keyColumn and values in column valueColumn. The SQL is written for SQLite,
+// and works for the databases quoting identifiers by '"'. db could be
+// opened by any driver
+func ReadSQL(db *sql.DB, table, keyColumn, valueColumn string) (map[string]int32, error) {
+	query := fmt.Sprintf(
+		"SELECT %s, %s FROM %s",
+		quoteIdentifier(keyColumn),
+		quoteIdentifier(valueColumn),
+		quoteIdentifier(table))
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dict := map[string]int32{}
+	for rows.Next() {
+		var key string
+		var value int32
+		if err = rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		dict[key] = value
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return dict, nil
+}
+
+// ExportSQL creates table in db with columns "key" (primary key) and
+// "value", and inserts the keys and values of Lexicon in one transaction.
+// The SQL is written for SQLite, like ReadSQL
+func (t *Lexicon) ExportSQL(db *sql.DB, table string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(fmt.Sprintf(
+		"CREATE TABLE %s (key TEXT PRIMARY KEY, value INTEGER NOT NULL)",
+		quoteIdentifier(table)))
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf(
+		"INSERT INTO %s (key, value) VALUES (?, ?)",
+		quoteIdentifier(table)))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	t.walkPrefix("", func(key string, value int32) bool {
+		_, err = stmt.Exec(key, value)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,139 @@
+package lexicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+// stringTable stores strings concatenated in data, the i-th string is
+// data[offsets[i]:offsets[i+1]]
+type stringTable struct {
+	offsets []int32
+	data    []byte
+}
+
+// BuildStrings builds the reimu-trie from dict where each key maps to a
+// string, e.g. lemma, canonical form or pinyin. Strings could be got by
+// GetString. Identical strings are stored once, and the value of a key got by
+// Get is the index of its string in string table
+func BuildStrings(
+	dict map[string]string,
+	progress func(int, int),
+	opts ...Option) (*Lexicon, error) {
+	table, stringIndex := buildStringTable(dict)
+	t, err := Build(stringIndex, progress, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	t.strings = table
+	return t, nil
+}
+
+// buildStringTable builds the string table of values in dict. Returns the
+// table and the map from key to index of its value in table
+func buildStringTable(dict map[string]string) (*stringTable, map[string]int32) {
+	// Strings are ordered, so the result is the same for the same dict
+	unique := map[string]int32{}
+	for _, s := range dict {
+		unique[s] = 0
+	}
+	values := make([]string, 0, len(unique))
+	for s := range unique {
+		values = append(values, s)
+	}
+	sort.Strings(values)
+
+	table := &stringTable{
+		offsets: make([]int32, 0, len(values)+1),
+		data:    []byte{},
+	}
+	for i, s := range values {
+		unique[s] = int32(i)
+		table.offsets = append(table.offsets, int32(len(table.data)))
+		table.data = append(table.data, s...)
+	}
+	table.offsets = append(table.offsets, int32(len(table.data)))
+
+	stringIndex := make(map[string]int32, len(dict))
+	for key, s := range dict {
+		stringIndex[key] = unique[s]
+	}
+
+	return table, stringIndex
+}
+
+// GetString gets the string by key in Lexicon built by BuildStrings. On
+// success, returns (value, true)
+func (t *Lexicon) GetString(key string) (value string, ok bool) {
+	if t.strings == nil {
+		return "", false
+	}
+
+	index, ok := t.Get(key)
+	if !ok {
+		return "", false
+	}
+	return t.strings.get(index), true
+}
+
+// get returns the i-th string in table
+func (st *stringTable) get(i int32) string {
+	return string(st.data[st.offsets[i]:st.offsets[i+1]])
+}
+
+// clone returns a deep copy of string table
+func (st *stringTable) clone() *stringTable {
+	return &stringTable{
+		offsets: append([]int32{}, st.offsets...),
+		data:    append([]byte{}, st.data...),
+	}
+}
+
+// marshal encodes the string table into bytes: number of offsets, offsets
+// and then data
+func (st *stringTable) marshal() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := binary.Write(buf, binary.LittleEndian, int32(len(st.offsets)))
+	if err == nil {
+		err = binary.Write(buf, binary.LittleEndian, st.offsets)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	buf.Write(st.data)
+	return buf.Bytes(), nil
+}
+
+// readStringTable reads the string table from payload of its section
+func readStringTable(payload []byte) (*stringTable, error) {
+	r := bytes.NewReader(payload)
+	var numOffsets int32
+	err := binary.Read(r, binary.LittleEndian, &numOffsets)
+	if err == nil && (numOffsets < 1 || int(numOffsets) > r.Len()/4) {
+		return nil, ErrCorrupted
+	}
+
+	st := &stringTable{}
+	if err == nil {
+		st.offsets = make([]int32, numOffsets)
+		err = binary.Read(r, binary.LittleEndian, &st.offsets)
+	}
+	if err != nil {
+		return nil, err
+	}
+	st.data = payload[len(payload)-r.Len():]
+
+	// Offsets should be in order and inside data
+	previous := int32(0)
+	for _, offset := range st.offsets {
+		if offset < previous || int(offset) > len(st.data) {
+			return nil, ErrCorrupted
+		}
+		previous = offset
+	}
+
+	return st, nil
+}
@@ -0,0 +1,194 @@
+package lexicon
+
+import (
+	"math"
+	"sort"
+)
+
+// maxSuggestEdits is the max edit distance of the corrections from Suggest
+const maxSuggestEdits = 2
+
+// CostMatrix is the costs of substituting bytes in Suggest, so plausible
+// typos like adjacent keys on keyboard or confusable pinyin are ranked
+// before others. Substitutions not in matrix cost 1, as well as insertions
+// and deletions
+type CostMatrix struct {
+	costs map[[2]byte]float64
+}
+
+// NewCostMatrix creates an empty CostMatrix, in which all substitutions cost
+// 1
+func NewCostMatrix() *CostMatrix {
+	return &CostMatrix{costs: map[[2]byte]float64{}}
+}
+
+// Set sets the cost of substituting a by b and b by a, it should be in
+// [0, 1]
+func (m *CostMatrix) Set(a, b byte, cost float64) {
+	m.costs[[2]byte{a, b}] = cost
+	m.costs[[2]byte{b, a}] = cost
+}
+
+// Cost returns the cost of substituting a by b
+func (m *CostMatrix) Cost(a, b byte) float64 {
+	if a == b {
+		return 0
+	}
+	if cost, ok := m.costs[[2]byte{a, b}]; ok {
+		return cost
+	}
+	return 1
+}
+
+// qwertyRows are the letter rows of QWERTY keyboard, each row is shifted
+// right by about half a key from the row above
+var qwertyRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// QWERTYCosts returns the CostMatrix where substituting adjacent letters on
+// QWERTY keyboard costs 'cost', in both lower and upper case
+func QWERTYCosts(cost float64) *CostMatrix {
+	m := NewCostMatrix()
+	set := func(a, b byte) {
+		m.Set(a, b, cost)
+		m.Set(a-'a'+'A', b-'a'+'A', cost)
+	}
+	for i, row := range qwertyRows {
+		for j := 0; j < len(row); j++ {
+			if j+1 < len(row) {
+				set(row[j], row[j+1])
+			}
+			if i+1 < len(qwertyRows) {
+				// The keys below are at j-1 and j in the next row
+				below := qwertyRows[i+1]
+				if j-1 >= 0 && j-1 < len(below) {
+					set(row[j], below[j-1])
+				}
+				if j < len(below) {
+					set(row[j], below[j])
+				}
+			}
+		}
+	}
+	return m
+}
+
+// SuggestOption is the option of Suggest
+type SuggestOption func(*suggestOptions)
+
+// suggestOptions are the options of Suggest
+type suggestOptions struct {
+	costs *CostMatrix
+}
+
+// WithCostMatrix weights the substitutions in Suggest by costs, e.g.
+// QWERTYCosts. Corrections are ranked by the weighted distance and bounded by
+// it as well
+func WithCostMatrix(costs *CostMatrix) SuggestOption {
+	return func(o *suggestOptions) {
+		o.costs = costs
+	}
+}
+
+// suggestion is a candidate of Suggest with its distance to the word
+type suggestion struct {
+	Entry
+	distance float64
+}
+
+// Suggest returns up to n corrections of word for spell checking, the keys
+// within 2 edits (Levenshtein distance) of word. They are ranked by edit
+// distance, then by value descending as the frequency of key, then by key.
+// So word itself is the first one if it is a key. Substitutions could be
+// weighted by WithCostMatrix
+func (t *Lexicon) Suggest(word string, n int, opts ...SuggestOption) []Entry {
+	options := &suggestOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	entries := []Entry{}
+	if n <= 0 {
+		return entries
+	}
+
+	candidates := []suggestion{}
+	t.fuzzySearch(word, maxSuggestEdits, options.costs, func(e suggestion) bool {
+		candidates = append(candidates, e)
+		return true
+	})
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return rankedBefore(candidates[i].Entry, candidates[j].Entry)
+	})
+	for _, e := range candidates {
+		if len(entries) == n {
+			break
+		}
+		entries = append(entries, e.Entry)
+	}
+
+	return entries
+}
+
+// fuzzySearch calls fn for each entry whose key is within distance
+// 'maxDistance' of word, in lexicographical order. Substitutions are weighted
+// by costs, or cost 1 if it is nil. Stops once fn returns false
+func (t *Lexicon) fuzzySearch(
+	word string,
+	maxDistance float64,
+	costs *CostMatrix,
+	fn func(e suggestion) bool) {
+	// row[i] is the distance between word[:i] and the bytes consumed
+	row := make([]float64, len(word)+1)
+	for i := range row {
+		row[i] = float64(i)
+	}
+
+	s := InitialState()
+	t.fuzzySearchWalk(&s, []byte{}, word, row, maxDistance, costs, fn)
+}
+
+// fuzzySearchWalk is the recursive part of fuzzySearch
+func (t *Lexicon) fuzzySearchWalk(
+	s *State,
+	key []byte,
+	word string,
+	row []float64,
+	maxDistance float64,
+	costs *CostMatrix,
+	fn func(e suggestion) bool) bool {
+	if distance := row[len(word)]; distance <= maxDistance {
+		if value, ok := t.value(s); ok && s.state != 0 {
+			if !fn(suggestion{Entry{string(key), value}, distance}) {
+				return false
+			}
+		}
+	}
+
+	// Distances only grow with more bytes, so no key in the subtree is
+	// within maxDistance
+	minDistance := row[0]
+	for _, d := range row {
+		minDistance = math.Min(minDistance, d)
+	}
+	if minDistance > maxDistance {
+		return true
+	}
+
+	return t.children(s, func(b byte, child State) bool {
+		nextRow := make([]float64, len(row))
+		nextRow[0] = row[0] + 1
+		for i := 1; i < len(row); i++ {
+			cost := 1.0
+			if costs != nil {
+				cost = costs.Cost(word[i-1], b)
+			} else if word[i-1] == b {
+				cost = 0
+			}
+			nextRow[i] = math.Min(math.Min(row[i]+1, nextRow[i-1]+1), row[i-1]+cost)
+		}
+		return t.fuzzySearchWalk(&child, append(key, b), word, nextRow, maxDistance, costs, fn)
+	})
+}
@@ -0,0 +1,79 @@
+package lexicon
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// entryHeap is the min-heap of entries, the root is the entry ranked last in
+// TopKByValue, that is, the smallest value and the largest key among them
+type entryHeap []Entry
+
+func (h entryHeap) Len() int      { return len(h) }
+func (h entryHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h entryHeap) Less(i, j int) bool {
+	return rankedBefore(h[j], h[i])
+}
+func (h *entryHeap) Push(x interface{}) { *h = append(*h, x.(Entry)) }
+func (h *entryHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// rankedBefore returns true if a is ranked before b in TopKByValue
+func rankedBefore(a, b Entry) bool {
+	if a.Value != b.Value {
+		return a.Value > b.Value
+	}
+	return a.Key < b.Key
+}
+
+// TopKByValue returns the k entries with the largest values, ordered by value
+// descending then by key. With the value index (WithValueIndex) it only
+// visits the returned entries, otherwise it walks the whole Lexicon keeping a
+// heap of k entries
+func (t *Lexicon) TopKByValue(k int) []Entry {
+	entries := []Entry{}
+	if k <= 0 {
+		return entries
+	}
+
+	if t.values != nil {
+		for i := len(t.values.values) - 1; i >= 0 && len(entries) < k; i-- {
+			value := t.values.values[i]
+			for _, id := range t.values.keyIds[t.values.starts[i]:t.values.starts[i+1]] {
+				if len(entries) == k {
+					break
+				}
+				entries = append(entries, Entry{string(t.keyAt(id)), value})
+			}
+		}
+		return entries
+	}
+
+	return t.topKPrefix("", k)
+}
+
+// topKPrefix returns the k entries with the largest values whose key starts
+// with prefix, ordered as TopKByValue. It walks all of them keeping a heap of
+// k entries
+func (t *Lexicon) topKPrefix(prefix string, k int) []Entry {
+	h := &entryHeap{}
+	t.walkPrefix(prefix, func(key string, value int32) bool {
+		e := Entry{key, value}
+		if h.Len() < k {
+			heap.Push(h, e)
+		} else if rankedBefore(e, (*h)[0]) {
+			(*h)[0] = e
+			heap.Fix(h, 0)
+		}
+		return true
+	})
+	entries := append([]Entry{}, *h...)
+	sort.Slice(entries, func(i, j int) bool {
+		return rankedBefore(entries[i], entries[j])
+	})
+	return entries
+}
@@ -0,0 +1,72 @@
+package lexicon
+
+import (
+	"context"
+)
+
+// Tracer creates spans for distributed tracing, e.g. by OpenTelemetry:
+//
+//	func (o otelTracer) StartSpan(ctx context.Context, name string) (context.Context, func(error)) {
+//		ctx, span := o.tracer.Start(ctx, name)
+//		return ctx, func(err error) {
+//			if err != nil {
+//				span.RecordError(err)
+//			}
+//			span.End()
+//		}
+//	}
+//
+// StartSpan starts a span as the child of ctx, and returns the context of
+// the span and the function to end it with the error of operation
+type Tracer interface {
+	StartSpan(ctx context.Context, name string) (context.Context, func(err error))
+}
+
+// startSpan starts a span by tracer, tracer could be nil
+func startSpan(ctx context.Context, tracer Tracer, name string) (context.Context, func(err error)) {
+	if tracer == nil {
+		return ctx, func(error) {}
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return tracer.StartSpan(ctx, name)
+}
+
+// WithTracer traces Build by tracer, with spans of its phases as children
+// of ctx. The built Lexicon keeps the tracer for GetBatch
+func WithTracer(ctx context.Context, tracer Tracer) Option {
+	return func(o *buildOptions) {
+		o.traceContext = ctx
+		o.tracer = tracer
+	}
+}
+
+// WithReadTracer traces Read by tracer as a child span of ctx. The read
+// Lexicon keeps the tracer for GetBatch
+func WithReadTracer(ctx context.Context, tracer Tracer) ReadOption {
+	return func(o *readOptions) {
+		o.traceContext = ctx
+		o.tracer = tracer
+	}
+}
+
+// SetTracer sets the tracer of GetBatch, nil disables tracing. It should be
+// called before Lexicon is shared between goroutines
+func (t *Lexicon) SetTracer(tracer Tracer) {
+	t.tracer = tracer
+}
+
+// GetBatch gets the values of keys, values[i] and found[i] are the result
+// of Get(keys[i]). It is traced as a span if Lexicon has a tracer
+func (t *Lexicon) GetBatch(ctx context.Context, keys []string) (values []int32, found []bool) {
+	_, end := startSpan(ctx, t.tracer, "lexicon.GetBatch")
+	defer end(nil)
+
+	values = make([]int32, len(keys))
+	found = make([]bool, len(keys))
+	for i, key := range keys {
+		values[i], found[i] = t.Get(key)
+	}
+	return values, found
+}
@@ -0,0 +1,312 @@
+package lexicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Transform normalizes keys. Transforms are applied to each key in Build,
+// and recorded in the lexicon file so that Get applies the same transforms
+// to queries
+type Transform struct {
+	// name identifies the transform in lexicon file, and params are its
+	// parameters, e.g. the mapping table
+	name   string
+	params []byte
+
+	apply func(key string) string
+}
+
+// transformFactories creates transforms by their names and params, used in
+// reading transforms from file
+var transformFactories = map[string]func(params []byte) (Transform, error){}
+
+// registerTransform registers the factory of transforms named 'name'
+func registerTransform(name string, factory func(params []byte) (Transform, error)) {
+	transformFactories[name] = factory
+}
+
+func init() {
+	registerTransform("lower-ascii", func([]byte) (Transform, error) {
+		return LowerASCII(), nil
+	})
+	registerTransform("trim-space", func([]byte) (Transform, error) {
+		return TrimSpace(), nil
+	})
+	registerTransform("fold-case", func(params []byte) (Transform, error) {
+		return FoldCase(string(params)), nil
+	})
+	registerTransform("normalize-width", func([]byte) (Transform, error) {
+		return NormalizeWidth(), nil
+	})
+	registerTransform("kana-reading", func([]byte) (Transform, error) {
+		return KanaReading(), nil
+	})
+	registerTransform("rune-map", func(params []byte) (Transform, error) {
+		if len(params)%8 != 0 {
+			return Transform{}, ErrCorrupted
+		}
+		table := make(map[rune]rune, len(params)/8)
+		for i := 0; i < len(params); i += 8 {
+			from := rune(binary.LittleEndian.Uint32(params[i:]))
+			table[from] = rune(binary.LittleEndian.Uint32(params[i+4:]))
+		}
+		return RuneMap(table), nil
+	})
+	registerTransform("byte-map", func(params []byte) (Transform, error) {
+		if len(params) != 256 {
+			return Transform{}, ErrCorrupted
+		}
+		var table [256]byte
+		copy(table[:], params)
+		return ByteMap(table), nil
+	})
+}
+
+// Name returns the name of transform
+func (tr Transform) Name() string {
+	return tr.name
+}
+
+// Apply applies the transform on key
+func (tr Transform) Apply(key string) string {
+	return tr.apply(key)
+}
+
+// LowerASCII is the transform mapping ASCII letters to lower case, other
+// bytes are unchanged
+func LowerASCII() Transform {
+	return Transform{
+		name: "lower-ascii",
+		apply: func(key string) string {
+			for i := 0; i < len(key); i++ {
+				if key[i] >= 'A' && key[i] <= 'Z' {
+					return lowerASCII(key, i)
+				}
+			}
+			return key
+		},
+	}
+}
+
+// lowerASCII maps ASCII letters in key[start:] to lower case
+func lowerASCII(key string, start int) string {
+	b := []byte(key)
+	for i := start; i < len(b); i++ {
+		if b[i] >= 'A' && b[i] <= 'Z' {
+			b[i] += 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// FoldCase is the transform of Unicode simple case folding, e.g. "ΣΊΣΥΦΟΣ"
+// and "σίσυφος" are the same. Characters are mapped to lower case after
+// upper case, so variants like final sigma and Kelvin sign are folded too.
+// Like Unicode, the default rules keep "İ" and "ı" unchanged. locale "tr" or
+// "az" folds them in Turkish rules, where "I" is the upper case of "ı" and
+// "İ" is the one of "i". Other locales use the default rules
+func FoldCase(locale string) Transform {
+	fold := func(r rune) rune {
+		if r == 'İ' || r == 'ı' {
+			return r
+		}
+		return unicode.ToLower(unicode.ToUpper(r))
+	}
+	if locale == "tr" || locale == "az" {
+		fold = func(r rune) rune {
+			return unicode.TurkishCase.ToLower(unicode.TurkishCase.ToUpper(r))
+		}
+	} else {
+		locale = ""
+	}
+
+	return Transform{
+		name:   "fold-case",
+		params: []byte(locale),
+		apply: func(key string) string {
+			return strings.Map(fold, key)
+		},
+	}
+}
+
+// TrimSpace is the transform removing leading and trailing white spaces
+func TrimSpace() Transform {
+	return Transform{
+		name:  "trim-space",
+		apply: strings.TrimSpace,
+	}
+}
+
+// ByteMap is the transform mapping each byte b in key to table[b], e.g. to
+// convert keys from legacy single-byte encodings
+func ByteMap(table [256]byte) Transform {
+	params := append([]byte{}, table[:]...)
+	return Transform{
+		name:   "byte-map",
+		params: params,
+		apply: func(key string) string {
+			b := []byte(key)
+			for i := range b {
+				b[i] = params[b[i]]
+			}
+			return string(b)
+		},
+	}
+}
+
+// RuneMap is the transform mapping each character r in key to table[r] if
+// exists, e.g. traditional Chinese characters to simplified ones (see
+// CedictRuneMap), so a dictionary of one form answers queries of both
+func RuneMap(table map[rune]rune) Transform {
+	// Params are pairs of runes ordered by the first one, so the same table
+	// is always stored the same
+	from := make([]rune, 0, len(table))
+	for r := range table {
+		from = append(from, r)
+	}
+	sort.Slice(from, func(i, j int) bool { return from[i] < from[j] })
+	params := make([]byte, len(from)*8)
+	for i, r := range from {
+		binary.LittleEndian.PutUint32(params[i*8:], uint32(r))
+		binary.LittleEndian.PutUint32(params[i*8+4:], uint32(table[r]))
+	}
+
+	mapping := make(map[rune]rune, len(table))
+	for k, v := range table {
+		mapping[k] = v
+	}
+	return Transform{
+		name:   "rune-map",
+		params: params,
+		apply: func(key string) string {
+			return strings.Map(func(r rune) rune {
+				if mapped, ok := mapping[r]; ok {
+					return mapped
+				}
+				return r
+			}, key)
+		},
+	}
+}
+
+// transformKeys applies transforms on keys in dict. If several keys become
+// the same, the value of the smallest original key is kept
+func transformKeys(dict map[string]int32, transforms []Transform) map[string]int32 {
+	if len(transforms) == 0 {
+		return dict
+	}
+
+	keys := make([]string, 0, len(dict))
+	for key := range dict {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	transformed := make(map[string]int32, len(dict))
+	for _, key := range keys {
+		normalized := applyTransforms(key, transforms)
+		if _, ok := transformed[normalized]; !ok {
+			transformed[normalized] = dict[key]
+		}
+	}
+	return transformed
+}
+
+// applyTransforms applies transforms on key in order
+func applyTransforms(key string, transforms []Transform) string {
+	for _, tr := range transforms {
+		key = tr.apply(key)
+	}
+	return key
+}
+
+// Transforms returns the transforms of Lexicon, in the order of applying
+func (t *Lexicon) Transforms() []Transform {
+	return append([]Transform{}, t.transforms...)
+}
+
+// Normalize applies the transforms of Lexicon on key. Get does it already,
+// while other methods like Traverse, Complete and FindAll work on the
+// normalized bytes, so callers should normalize their inputs first
+func (t *Lexicon) Normalize(key string) string {
+	return applyTransforms(key, t.transforms)
+}
+
+// marshalTransforms encodes transforms into bytes: number of transforms,
+// then name and params of each transform, both prefixed by its length
+func marshalTransforms(transforms []Transform) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	var err error
+	binaryWrite := func(data interface{}) {
+		if err == nil {
+			err = binary.Write(buf, binary.LittleEndian, data)
+		}
+	}
+
+	binaryWrite(int32(len(transforms)))
+	for _, tr := range transforms {
+		binaryWrite(int32(len(tr.name)))
+		binaryWrite([]byte(tr.name))
+		binaryWrite(int32(len(tr.params)))
+		binaryWrite(tr.params)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readTransforms reads transforms from payload of its section
+func readTransforms(payload []byte) ([]Transform, error) {
+	r := bytes.NewReader(payload)
+	var err error
+	readBytes := func() []byte {
+		var length int32
+		if err == nil {
+			err = binary.Read(r, binary.LittleEndian, &length)
+		}
+		if err == nil && (length < 0 || int(length) > r.Len()) {
+			err = ErrCorrupted
+		}
+		if err != nil {
+			return nil
+		}
+		data := make([]byte, length)
+		err = binary.Read(r, binary.LittleEndian, &data)
+		return data
+	}
+
+	var numTransforms int32
+	err = binary.Read(r, binary.LittleEndian, &numTransforms)
+	if err == nil && (numTransforms < 0 || int(numTransforms) > r.Len()) {
+		return nil, ErrCorrupted
+	}
+
+	transforms := []Transform{}
+	for i := 0; i < int(numTransforms) && err == nil; i++ {
+		name := string(readBytes())
+		params := readBytes()
+		if err != nil {
+			break
+		}
+
+		factory, ok := transformFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownTransform, name)
+		}
+		var tr Transform
+		tr, err = factory(params)
+		transforms = append(transforms, tr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return transforms, nil
+}
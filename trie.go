@@ -1,10 +1,7 @@
 package lexicon
 
 import (
-	"fmt"
-	"strings"
-
-	"github.com/pkg/errors"
+	"sort"
 )
 
 // _Trie is a ordinary implementation of trie
@@ -15,12 +12,58 @@ type _Trie struct {
 	hasValue bool
 	value    int32
 
-	children map[byte]*_Trie
+	// Child nodes ordered by label
+	children []trieEdge
+}
+
+// trieEdge is the edge from a _Trie node to its child
+type trieEdge struct {
+	label byte
+	node  *_Trie
+}
+
+// trieArenaSlabSize is the number of nodes allocated at once by trieArena
+const trieArenaSlabSize = 4096
+
+// trieArena allocates _Trie nodes from large slabs instead of one by one,
+// to reduce the allocations and GC pressure of building huge tries
+type trieArena struct {
+	slab []_Trie
+}
+
+// newNode allocates a new _Trie node from arena
+func (a *trieArena) newNode() *_Trie {
+	if len(a.slab) == 0 {
+		a.slab = make([]_Trie, trieArenaSlabSize)
+	}
+	node := &a.slab[0]
+	a.slab = a.slab[1:]
+	return node
+}
+
+// child returns the child of label, or nil if not exist
+func (t *_Trie) child(label byte) *_Trie {
+	i := t.search(label)
+	if i < len(t.children) && t.children[i].label == label {
+		return t.children[i].node
+	}
+	return nil
 }
 
-// newTrie creates a new instance of trie-node
-func newTrie() *_Trie {
-	return new(_Trie)
+// search returns the index of the first child with label not less than
+// 'label'
+func (t *_Trie) search(label byte) int {
+	return sort.Search(len(t.children), func(i int) bool {
+		return t.children[i].label >= label
+	})
+}
+
+// addChild adds a child node of label, which should not exist before
+func (t *_Trie) addChild(label byte, node *_Trie) {
+	i := t.search(label)
+	t.children = append(t.children, trieEdge{})
+	copy(t.children[i+1:], t.children[i:])
+	t.children[i] = trieEdge{label, node}
 }
 
 // isEmpty returns true if the trie is empty (no child, no value and no suffix)
@@ -29,27 +72,24 @@ func (t *_Trie) isEmpty() bool {
 }
 
 // convertSuffix converts suffix to child in trie-node
-func (t *_Trie) convertSuffix() {
+func (t *_Trie) convertSuffix(arena *trieArena) {
 	assert(t.hasSuffix, "unexpected call of convertSuffix()")
-	if t.children == nil {
-		t.children = make(map[byte]*_Trie)
-	}
 
-	child := newTrie()
-	child.add(t.suffix[1:], t.value)
+	child := arena.newNode()
+	child.add(arena, t.suffix[1:], t.value)
 
-	t.children[t.suffix[0]] = child
+	t.addChild(t.suffix[0], child)
 	t.hasSuffix = false
 	t.suffix = nil
 	t.value = 0
 }
 
-// add adds a key value pair into trie
-func (t *_Trie) add(key []byte, value int32) {
+// add adds a key value pair into trie, new nodes are allocated from arena
+func (t *_Trie) add(arena *trieArena, key []byte, value int32) {
 	// We will put some thing into this trie-node now. So, if the node has
 	// suffix, we need to convert it to normal child-node first
 	if t.hasSuffix {
-		t.convertSuffix()
+		t.convertSuffix(arena)
 	}
 
 	if len(key) == 0 {
@@ -63,84 +103,69 @@ func (t *_Trie) add(key []byte, value int32) {
 		t.value = value
 	} else {
 		// Put the key recursively
-		if t.children == nil {
-			t.children = make(map[byte]*_Trie)
+		child := t.child(key[0])
+		if child == nil {
+			child = arena.newNode()
+			t.addChild(key[0], child)
 		}
-
-		if _, ok := t.children[key[0]]; !ok {
-			t.children[key[0]] = newTrie()
-		}
-		t.children[key[0]].add(key[1:], value)
+		child.add(arena, key[1:], value)
 	}
 }
 
-// buildTrie constructs the trie from string->int map
-func buildTrie(dict map[string]int32) (trie *_Trie, err error) {
-	trie = newTrie()
-
-	for key, value := range dict {
-		if strings.Contains("key", "\x00") {
-			err = errors.Errorf("unexpected character '\\x00' in key: %s", key)
-			return nil, err
-		}
-
-		if key == "" {
-			err = errors.New("unexpected empty key")
+// buildTrie constructs the trie from string->int map. keepSuffix decides
+// whether a suffix stays as suffix or is expanded into nodes, nil keeps all
+// suffixes
+func buildTrie(
+	dict map[string]int32,
+	keepSuffix func(suffix []byte) bool) (trie *_Trie, err error) {
+	arena := &trieArena{}
+	trie = arena.newNode()
+
+	// Keys are added in order, so nodes of a subtree are mostly in the same
+	// slabs, which are released together after the subtree is placed into
+	// double array
+	keys := make([]string, 0, len(dict))
+	for key := range dict {
+		if err = checkKey(key); err != nil {
 			return nil, err
 		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		trie.add(arena, []byte(key), dict[key])
+	}
 
-		trie.add([]byte(key), value)
+	// Root node should always be in double array, even if there is only one
+	// key in dict
+	if trie.hasSuffix {
+		trie.convertSuffix(arena)
+	}
+	if keepSuffix != nil {
+		trie.expandSuffix(arena, keepSuffix)
 	}
 	return
 }
 
+// expandSuffix converts the suffixes rejected by keepSuffix into nodes. The
+// converted node has a shorter suffix which is checked again
+func (t *_Trie) expandSuffix(arena *trieArena, keepSuffix func(suffix []byte) bool) {
+	if t.hasSuffix && !keepSuffix(t.suffix) {
+		t.convertSuffix(arena)
+	}
+	for _, c := range t.children {
+		c.node.expandSuffix(arena, keepSuffix)
+	}
+}
+
 // countNode counts the node in _Trie
 func (t *_Trie) countNode() int {
 	// 1 for the node self
 	count := 1
 
 	for _, c := range t.children {
-		count += c.countNode()
+		count += c.node.countNode()
 	}
 
 	return count
 }
-
-// printTree prints the structure of trie
-func (t *_Trie) printTree() {
-	fmt.Println("ROOT")
-	t.print("")
-}
-
-// print prints the current trie (just for debugging)
-func (t *_Trie) print(prefix string) {
-	if t.hasSuffix {
-		assert(t.children == nil, "unexpected _Trie node")
-		fmt.Printf(
-			"%s+- SUFFIX('%s', %d)\n",
-			prefix,
-			t.suffix,
-			t.value)
-	} else {
-		assert(t.suffix == nil, "unexpected _Trie node")
-		childList := []byte{}
-		for child := range t.children {
-			childList = append(childList, child)
-		}
-		for i, child := range childList {
-			medium := "|-"
-			nextPrefix := prefix + "|  "
-			if i == len(childList)-1 && !t.hasValue {
-				medium = "+-"
-				nextPrefix = prefix + "   "
-			}
-			fmt.Printf("%s%s %c\n", prefix, medium, child)
-			t.children[child].print(nextPrefix)
-		}
-
-		// The value node
-		if t.hasValue {
-			fmt.Printf("%s+- VALUE(%d)\n", prefix, t.value)
-		}
-	}
-}
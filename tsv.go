@@ -0,0 +1,66 @@
+package lexicon
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadTSV reads the dict for Build from r, each line is a key and its int32
+// value separated by tab. Empty lines are skipped
+func ReadTSV(r io.Reader) (map[string]int32, error) {
+	dict := map[string]int32{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+
+		tab := strings.LastIndexByte(line, '\t')
+		if tab < 0 {
+			return nil, fmt.Errorf("line %d: expect key and value separated by tab", lineNo)
+		}
+		key := line[:tab]
+		value, err := strconv.ParseInt(line[tab+1:], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if _, ok := dict[key]; ok {
+			return nil, fmt.Errorf("line %d: duplicated key: %q", lineNo, key)
+		}
+
+		dict[key] = int32(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return dict, nil
+}
+
+// WriteTSV writes keys and values in Lexicon to w in the format of ReadTSV,
+// ordered by key. Keys containing '\n' could not be written
+func (t *Lexicon) WriteTSV(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	var err error
+	t.walkPrefix("", func(key string, value int32) bool {
+		if strings.IndexByte(key, '\n') >= 0 {
+			err = fmt.Errorf("unexpected '\\n' in key: %q", key)
+			return false
+		}
+		_, err = fmt.Fprintf(bw, "%s\t%d\n", key, value)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
@@ -0,0 +1,50 @@
+package lexicon
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// WithUTF8Validation rejects keys of malformed UTF-8 with KeyError of
+// ErrInvalidUTF8 in Build. It is checked after transforms, and recorded in
+// Lexicon so that GetUTF8 could reject malformed queries too
+func WithUTF8Validation() Option {
+	return func(o *buildOptions) {
+		o.validateUTF8 = true
+	}
+}
+
+// checkUTF8 returns KeyError of the smallest key in dict which is not valid
+// UTF-8
+func checkUTF8(dict map[string]int32) error {
+	invalid := []string{}
+	for key := range dict {
+		if !utf8.ValidString(key) {
+			invalid = append(invalid, key)
+		}
+	}
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	sort.Strings(invalid)
+	return &KeyError{invalid[0], ErrInvalidUTF8}
+}
+
+// IsUTF8Validated returns true if keys in Lexicon are checked to be valid
+// UTF-8, that is, built with WithUTF8Validation
+func (t *Lexicon) IsUTF8Validated() bool {
+	return t.flags&flagUTF8 != 0
+}
+
+// GetUTF8 is Get, but returns KeyError of ErrInvalidUTF8 if Lexicon is built
+// with WithUTF8Validation and key is not valid UTF-8, which could never be
+// found. It tells encoding mismatches from missing keys
+func (t *Lexicon) GetUTF8(key string) (value int32, ok bool, err error) {
+	if t.IsUTF8Validated() && !utf8.ValidString(key) {
+		return -1, false, &KeyError{key, ErrInvalidUTF8}
+	}
+
+	value, ok = t.Get(key)
+	return value, ok, nil
+}
@@ -1,12 +1,20 @@
 package lexicon
 
-import (
-	"log"
-)
-
-// assert check exp value, if exp == false then fatal with message
+// assert check exp value, if exp == false then panic with message. It never
+// exits the process, so servers could recover from it
 func assert(exp bool, message string) {
 	if !exp {
-		log.Fatal(message)
+		panic("lexicon: " + message)
+	}
+}
+
+// min3 returns the minimum of a, b and c
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
 	}
+	return a
 }
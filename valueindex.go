@@ -0,0 +1,190 @@
+package lexicon
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+)
+
+// valueIndex maps values to ids of keys having that value
+type valueIndex struct {
+	// Distinct values in ascending order
+	values []int32
+
+	// Ids of keys with values[i] are keyIds[starts[i]:starts[i+1]], in order
+	// of keys
+	starts []int32
+	keyIds []int32
+}
+
+// WithValueIndex additionally indexes keys by their values, so KeysForValue
+// and KeysWithValueIn don't need to walk the whole Lexicon
+func WithValueIndex() Option {
+	return func(o *buildOptions) {
+		o.valueIndex = true
+	}
+}
+
+// buildValueIndex builds the value index of all keys in Lexicon t
+func buildValueIndex(t *Lexicon) *valueIndex {
+	groups := map[int32][]int32{}
+	t.walkPrefix("", func(key string, value int32) bool {
+		id, ok := t.keyId(key)
+		assert(ok, "buildValueIndex: key not in lexicon")
+		groups[value] = append(groups[value], id)
+		return true
+	})
+
+	index := &valueIndex{
+		values: make([]int32, 0, len(groups)),
+		starts: []int32{0},
+		keyIds: []int32{},
+	}
+	for value := range groups {
+		index.values = append(index.values, value)
+	}
+	sort.Slice(index.values, func(i, j int) bool {
+		return index.values[i] < index.values[j]
+	})
+	for _, value := range index.values {
+		index.keyIds = append(index.keyIds, groups[value]...)
+		index.starts = append(index.starts, int32(len(index.keyIds)))
+	}
+
+	return index
+}
+
+// search returns the index of the first value not less than v
+func (index *valueIndex) search(v int32) int {
+	return sort.Search(len(index.values), func(i int) bool {
+		return index.values[i] >= v
+	})
+}
+
+// clone returns a deep copy of index
+func (index *valueIndex) clone() *valueIndex {
+	return &valueIndex{
+		values: append([]int32{}, index.values...),
+		starts: append([]int32{}, index.starts...),
+		keyIds: append([]int32{}, index.keyIds...),
+	}
+}
+
+// marshal encodes the value index into bytes: number of values, values,
+// starts and then key ids
+func (index *valueIndex) marshal() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	err := binary.Write(buf, binary.LittleEndian, int32(len(index.values)))
+	if err == nil {
+		err = binary.Write(buf, binary.LittleEndian, index.values)
+	}
+	if err == nil {
+		err = binary.Write(buf, binary.LittleEndian, index.starts)
+	}
+	if err == nil {
+		err = binary.Write(buf, binary.LittleEndian, index.keyIds)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// readValueIndex reads the value index from payload of its section. Key ids
+// should be slots in the double array of numSlots slots
+func readValueIndex(payload []byte, numSlots int) (*valueIndex, error) {
+	r := bytes.NewReader(payload)
+	index := &valueIndex{}
+	var numValues int32
+	err := binary.Read(r, binary.LittleEndian, &numValues)
+	if err == nil && (numValues < 0 || int(numValues) > r.Len()/8) {
+		return nil, ErrCorrupted
+	}
+	if err == nil {
+		index.values = make([]int32, numValues)
+		index.starts = make([]int32, numValues+1)
+		err = binary.Read(r, binary.LittleEndian, &index.values)
+	}
+	if err == nil {
+		err = binary.Read(r, binary.LittleEndian, &index.starts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	numKeyIds := index.starts[numValues]
+	if index.starts[0] != 0 || int(numKeyIds)*4 != r.Len() {
+		return nil, ErrCorrupted
+	}
+	for i := 1; i <= int(numValues); i++ {
+		if index.starts[i] < index.starts[i-1] {
+			return nil, ErrCorrupted
+		}
+	}
+	index.keyIds = make([]int32, numKeyIds)
+	if err = binary.Read(r, binary.LittleEndian, &index.keyIds); err != nil {
+		return nil, err
+	}
+	for _, id := range index.keyIds {
+		if id <= 0 || int(id) >= numSlots {
+			return nil, ErrCorrupted
+		}
+	}
+
+	return index, nil
+}
+
+// KeysForValue returns keys with value v in lexicographical order. It takes
+// O(number of keys) time unless Lexicon is built with WithValueIndex
+func (t *Lexicon) KeysForValue(v int32) []string {
+	keys := []string{}
+	if t.values == nil {
+		t.walkPrefix("", func(key string, value int32) bool {
+			if value == v {
+				keys = append(keys, key)
+			}
+			return true
+		})
+		return keys
+	}
+
+	i := t.values.search(v)
+	if i == len(t.values.values) || t.values.values[i] != v {
+		return keys
+	}
+	for _, id := range t.values.keyIds[t.values.starts[i]:t.values.starts[i+1]] {
+		keys = append(keys, string(t.keyAt(id)))
+	}
+	return keys
+}
+
+// KeysWithValueIn returns entries whose value is in [lo, hi], ordered by
+// value then by key. It takes O(number of keys) time unless Lexicon is built
+// with WithValueIndex
+func (t *Lexicon) KeysWithValueIn(lo, hi int32) []Entry {
+	entries := []Entry{}
+	if lo > hi {
+		return entries
+	}
+	if t.values == nil {
+		t.walkPrefix("", func(key string, value int32) bool {
+			if value >= lo && value <= hi {
+				entries = append(entries, Entry{key, value})
+			}
+			return true
+		})
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Value < entries[j].Value
+		})
+		return entries
+	}
+
+	for i := t.values.search(lo); i < len(t.values.values) && t.values.values[i] <= hi; i++ {
+		value := t.values.values[i]
+		for _, id := range t.values.keyIds[t.values.starts[i]:t.values.starts[i+1]] {
+			entries = append(entries, Entry{string(t.keyAt(id)), value})
+		}
+	}
+	return entries
+}
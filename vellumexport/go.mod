@@ -0,0 +1,16 @@
+module github.com/ling0322/lexicon/vellumexport
+
+go 1.24.0
+
+require (
+	github.com/blevesearch/vellum v1.2.0
+	github.com/ling0322/lexicon v0.0.0
+)
+
+require (
+	github.com/bits-and-blooms/bitset v1.24.2 // indirect
+	github.com/blevesearch/mmap-go v1.2.0 // indirect
+	golang.org/x/sys v0.40.0 // indirect
+)
+
+replace github.com/ling0322/lexicon => ../
@@ -0,0 +1,53 @@
+// Package vellumexport exports lexicons as vellum FSTs, which could be
+// loaded by search engines built on github.com/blevesearch/vellum. It is
+// a separate module with its own go.mod, so that the lexicon package
+// doesn't depend on vellum
+package vellumexport
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/blevesearch/vellum"
+	"github.com/ling0322/lexicon"
+)
+
+// Write writes the keys and values of t to w as a vellum FST. Values of FST
+// are uint64, an int32 value v is stored as uint64(uint32(v)), so negative
+// values could be got back by int32(uint32(x))
+func Write(w io.Writer, t *lexicon.Lexicon) error {
+	builder, err := vellum.New(w, nil)
+	if err != nil {
+		return err
+	}
+
+	// WalkPrefix visits keys in order, as vellum requires
+	t.WalkPrefix("", func(key string, value int32) bool {
+		err = builder.Insert([]byte(key), uint64(uint32(value)))
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return builder.Close()
+}
+
+// Save saves the keys and values of t to file filename as a vellum FST
+func Save(filename string, t *lexicon.Lexicon) error {
+	fd, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	w := bufio.NewWriter(fd)
+	if err = Write(w, t); err != nil {
+		return err
+	}
+	if err = w.Flush(); err != nil {
+		return err
+	}
+	return fd.Close()
+}
@@ -0,0 +1,67 @@
+package vellumexport
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+
+	"github.com/blevesearch/vellum"
+	"github.com/ling0322/lexicon"
+)
+
+// readFST returns the entries of vellum FST in data
+func readFST(t *testing.T, data []byte) map[string]int32 {
+	fst, err := vellum.Load(data)
+	if err != nil {
+		t.FailNow()
+	}
+	defer fst.Close()
+
+	entries := map[string]int32{}
+	itr, err := fst.Iterator(nil, nil)
+	for err == nil {
+		key, value := itr.Current()
+		entries[string(key)] = int32(uint32(value))
+		err = itr.Next()
+	}
+	if err != vellum.ErrIteratorDone {
+		t.FailNow()
+	}
+	return entries
+}
+
+func TestWrite(t *testing.T) {
+	dict := map[string]int32{"a": 1, "ab": -2, "b": 3, "bcd": 1 << 30, "中文": 0}
+	l, err := lexicon.Build(dict, nil)
+	if err != nil {
+		t.FailNow()
+	}
+
+	buf := &bytes.Buffer{}
+	if Write(buf, l) != nil {
+		t.FailNow()
+	}
+	entries := readFST(t, buf.Bytes())
+	if len(entries) != len(dict) {
+		t.FailNow()
+	}
+	for key, value := range dict {
+		if v, ok := entries[key]; !ok || v != value {
+			t.FailNow()
+		}
+	}
+
+	// Save writes the same FST
+	filename := filepath.Join(t.TempDir(), "lexicon.fst")
+	if Save(filename, l) != nil {
+		t.FailNow()
+	}
+	fst, err := vellum.Open(filename)
+	if err != nil || fst.Len() != len(dict) {
+		t.FailNow()
+	}
+	if v, ok, err := fst.Get([]byte("ab")); err != nil || !ok || int32(uint32(v)) != -2 {
+		t.FailNow()
+	}
+	fst.Close()
+}
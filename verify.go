@@ -0,0 +1,153 @@
+package lexicon
+
+import (
+	"fmt"
+)
+
+// Verify checks the structure of Lexicon: slots link to their parents,
+// suffix links point to existing suffixes, and each suffix is inside the
+// suffix region and terminated by '\x00'. It is useful for lexicons from
+// untrusted sources. Read checks the suffixes already, Verify additionally
+// checks the slots
+func (t *Lexicon) Verify() error {
+	if err := t.verifySuffix(); err != nil {
+		return err
+	}
+
+	slots := t.slots
+	if len(slots) == 0 || slots[0].Check != 0 || slots[0].Base < 0 {
+		return fmt.Errorf("%w: invalid root", ErrCorrupted)
+	}
+	for i := 1; i < len(slots); i++ {
+		if slots[i].empty() {
+			continue
+		}
+
+		parent := slots[i].Check
+		if int(parent) >= len(slots) || slots[parent].empty() || slots[parent].Base < 0 {
+			return fmt.Errorf("%w: invalid parent of slot %d", ErrCorrupted, i)
+		}
+		label := int32(i) ^ slots[parent].Base
+		if label < 0 || label > 255 {
+			return fmt.Errorf("%w: invalid label of slot %d", ErrCorrupted, i)
+		}
+
+		// Base of value slot is the value, others are nodes
+		base := slots[i].Base
+		if label != 0 && base < 0 && int(-base-1) >= len(t.suffixIndex) {
+			return fmt.Errorf("%w: invalid suffix link of slot %d", ErrCorrupted, i)
+		}
+	}
+
+	return nil
+}
+
+// verifySuffix checks that suffixIndex and suffixValue have the same length,
+// and each suffix is inside suffix region and terminated by '\x00'. Lookups
+// in suffix rely on it to skip bound checks
+func (t *Lexicon) verifySuffix() error {
+	if len(t.suffixIndex) != len(t.suffixValue) {
+		return fmt.Errorf("%w: suffix index and value mismatch", ErrCorrupted)
+	}
+
+	for i, begin := range t.suffixIndex {
+		if begin < 0 || int(begin) >= len(t.suffix) {
+			return fmt.Errorf("%w: suffix %d out of range", ErrCorrupted, i)
+		}
+	}
+
+	// Since each suffix begins inside the region, all of them are terminated
+	// if the region ends with '\x00'
+	if len(t.suffix) > 0 && t.suffix[len(t.suffix)-1] != '\x00' {
+		return fmt.Errorf("%w: suffix not terminated", ErrCorrupted)
+	}
+
+	return nil
+}
+
+// verifySections checks that suffix links point to existing suffixes, the
+// key ids in sections are keys in the double array, and values used as
+// indexes of tables are inside them, so lookups could not reach other slots
+// or panic
+func (t *Lexicon) verifySections() error {
+	// Negative bases of nodes link to suffixes, and those of value slots are
+	// the values
+	for i := range t.slots {
+		slot := &t.slots[i]
+		if slot.empty() || slot.Base >= 0 {
+			continue
+		}
+		parent := slot.Check
+		isValue := i != 0 && int(parent) < len(t.slots) && t.slots[parent].Base == int32(i)
+		if !isValue && int(-slot.Base-1) >= len(t.suffixIndex) {
+			return fmt.Errorf("%w: invalid suffix link of slot %d", ErrCorrupted, i)
+		}
+	}
+
+	if t.values != nil {
+		for _, id := range t.values.keyIds {
+			if !t.isKeyId(id) {
+				return fmt.Errorf("%w: value index refers to slot %d", ErrCorrupted, id)
+			}
+		}
+	}
+
+	if t.phonetic != nil {
+		var err error
+		t.phonetic.ids(func(ids []int32) {
+			for _, id := range ids {
+				if err == nil && !t.isKeyId(id) {
+					err = fmt.Errorf("%w: phonetic index refers to slot %d", ErrCorrupted, id)
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Values are indexes of the tables, the scan of slots is skipped if
+	// there is no table
+	if t.strings == nil && t.rows == nil && t.columns == nil {
+		return nil
+	}
+	min, max, ok := t.valueRange()
+	if ok && t.strings != nil && (min < 0 || int(max) >= len(t.strings.offsets)-1) {
+		return fmt.Errorf("%w: string index out of string table", ErrCorrupted)
+	}
+	if ok && t.rows != nil && (min < 0 || int(max) >= len(t.rows.offsets)-1) {
+		return fmt.Errorf("%w: row index out of row table", ErrCorrupted)
+	}
+	if ok && t.columns != nil && len(t.columns.values) > 0 &&
+		(min < 0 || int(max) >= len(t.columns.values[0])) {
+		return fmt.Errorf("%w: row index out of column table", ErrCorrupted)
+	}
+
+	return nil
+}
+
+// valueRange returns the min and max values of keys, or ok = false if there
+// is no key
+func (t *Lexicon) valueRange() (min int32, max int32, ok bool) {
+	for i := range t.slots {
+		id := int32(i)
+		if !t.isKeyId(id) {
+			continue
+		}
+
+		var value int32
+		if t.slots[t.slots[id].Check].Base == id {
+			value = t.slots[id].Base
+		} else {
+			value = t.suffixValue[-t.slots[id].Base-1]
+		}
+		if !ok || value < min {
+			min = value
+		}
+		if !ok || value > max {
+			max = value
+		}
+		ok = true
+	}
+	return min, max, ok
+}
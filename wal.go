@@ -0,0 +1,114 @@
+package lexicon
+
+import (
+	"fmt"
+	"os"
+)
+
+// EnableWAL makes the changes of overlay durable by a write-ahead log in
+// filename, which has the format of delta files. Existing records in it are
+// replayed first, a torn record at the end (e.g. of a crash in writing) is
+// discarded. Then each Set and Delete is appended to the log before it is
+// applied. If sync is true, the log is synced to disk on each change.
+// After compacting overlay into a new lexicon file, call ResetWAL
+func (o *Overlay) EnableWAL(filename string, sync bool) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.wal != nil {
+		return fmt.Errorf("WAL is already enabled: %s", o.wal.fd.Name())
+	}
+	if err := o.replayWAL(filename); err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	wal, err := OpenDeltaWriter(filename)
+	if err != nil {
+		return err
+	}
+	o.wal = wal
+	o.walSync = sync
+	return nil
+}
+
+// replayWAL applies the records in WAL file, and truncates the torn record
+// at the end of file
+func (o *Overlay) replayWAL(filename string) error {
+	fd, err := os.OpenFile(filename, os.O_RDWR, 0)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer fd.Close()
+
+	info, err := fd.Stat()
+	if err != nil {
+		return err
+	}
+	if err = checkDeltaHeader(fd); err != nil {
+		return err
+	}
+	size := info.Size() - int64(len(deltaHeader))
+	valid, err := readDeltaRecords(fd, size, func(op byte, key string, value int32) error {
+		if op == deltaSet {
+			o.set(key, value)
+		} else {
+			o.delete(key)
+		}
+		return nil
+	})
+	if err == ErrCorrupted {
+		err = fd.Truncate(int64(len(deltaHeader)) + valid)
+	}
+	return err
+}
+
+// logChange appends the change to WAL if enabled. It should be called with
+// the write lock held
+func (o *Overlay) logChange(op byte, key string, value int32) error {
+	if o.wal == nil {
+		return nil
+	}
+
+	err := o.wal.write(op, key, value)
+	if err == nil {
+		err = o.wal.Flush()
+	}
+	if err == nil && o.walSync {
+		err = o.wal.fd.Sync()
+	}
+	return err
+}
+
+// ResetWAL clears the records in WAL, after the changes are persisted in a
+// new lexicon file, e.g. by Compact and Save
+func (o *Overlay) ResetWAL() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.wal == nil {
+		return nil
+	}
+	err := o.wal.Flush()
+	if err == nil {
+		err = o.wal.fd.Truncate(int64(len(deltaHeader)))
+	}
+	if err == nil && o.walSync {
+		err = o.wal.fd.Sync()
+	}
+	return err
+}
+
+// CloseWAL flushes and closes the WAL, later changes are not logged
+func (o *Overlay) CloseWAL() error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.wal == nil {
+		return nil
+	}
+	err := o.wal.Close()
+	o.wal = nil
+	return err
+}
@@ -0,0 +1,106 @@
+package lexicon
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// halfwidthKatakana are the full-width forms of half-width katakana from
+// U+FF66 to U+FF9D
+var halfwidthKatakana = []rune(
+	"ヲァィゥェォャュョッーアイウエオカキクケコサシスセソタチツテト" +
+		"ナニヌネノハヒフヘホマミムメモヤユヨラリルレロワン")
+
+// punctuationVariants maps variants of punctuation to their canonical forms
+var punctuationVariants = map[rune]rune{
+	'　': ' ',  // Ideographic space
+	'‘': '\'', // Left single quotation mark
+	'’': '\'', // Right single quotation mark
+	'“': '"',  // Left double quotation mark
+	'”': '"',  // Right double quotation mark
+	'‐': '-',  // Hyphen
+	'‑': '-',  // Non-breaking hyphen
+	'−': '-',  // Minus sign
+	'〜': '~',  // Wave dash
+	'｡': '。',  // Half-width ideographic full stop
+	'｢': '「',  // Half-width left corner bracket
+	'｣': '」',  // Half-width right corner bracket
+	'､': '、',  // Half-width ideographic comma
+	'･': '・',  // Half-width katakana middle dot
+	'ﾞ': '゛',  // Half-width voiced sound mark
+	'ﾟ': '゜',  // Half-width semi-voiced sound mark
+}
+
+// NormalizeWidth is the transform for CJK texts, which maps full-width ASCII
+// (U+FF01 to U+FF5E) to ASCII, half-width katakana to full-width ones, with
+// the voiced sound marks combined, and variants of spaces, quotation marks,
+// hyphens and half-width CJK punctuation to their canonical forms, e.g.
+// "ＡＢＣ１２３" to "ABC123", "ｶﾞｷﾞ" to "ガギ" and "“”" to `""`
+func NormalizeWidth() Transform {
+	return Transform{
+		name:  "normalize-width",
+		apply: normalizeWidth,
+	}
+}
+
+// normalizeWidth applies NormalizeWidth on key
+func normalizeWidth(key string) string {
+	// Fast path for keys without characters to normalize, which are all
+	// beyond ASCII
+	i := 0
+	for i < len(key) && key[i] < utf8.RuneSelf {
+		i++
+	}
+	if i == len(key) {
+		return key
+	}
+
+	b := &strings.Builder{}
+	b.Grow(len(key))
+	b.WriteString(key[:i])
+	runes := []rune(key[i:])
+	for j := 0; j < len(runes); j++ {
+		r := runes[j]
+		switch {
+		case r >= '！' && r <= '～':
+			r = r - '！' + '!'
+		case r >= 'ｦ' && r <= 'ﾝ':
+			r = halfwidthKatakana[r-'ｦ']
+			if j+1 < len(runes) {
+				if voiced, ok := combineVoicedMark(r, runes[j+1]); ok {
+					r = voiced
+					j++
+				}
+			}
+		default:
+			if canonical, ok := punctuationVariants[r]; ok {
+				r = canonical
+			}
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// combineVoicedMark combines katakana r with the half-width (semi-)voiced
+// sound mark, e.g. "カ" and "ﾞ" to "ガ". Returns false if they couldn't be
+// combined
+func combineVoicedMark(r rune, mark rune) (rune, bool) {
+	switch mark {
+	case 'ﾞ':
+		switch {
+		case r == 'ウ':
+			return 'ヴ', true
+		case strings.ContainsRune("カキクケコサシスセソタチツテト", r):
+			return r + 1, true
+		case strings.ContainsRune("ハヒフヘホ", r):
+			return r + 1, true
+		}
+	case 'ﾟ':
+		if strings.ContainsRune("ハヒフヘホ", r) {
+			return r + 2, true
+		}
+	}
+	return r, false
+}
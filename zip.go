@@ -0,0 +1,53 @@
+package lexicon
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io/fs"
+)
+
+// ReadZip reads reimu-trie from entry entryName of zip archive path, without
+// extracting it to a file
+func ReadZip(path, entryName string, opts ...ReadOption) (*Lexicon, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if f.Name == entryName {
+			t, err := ReadZipFile(f, opts...)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			return t, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%s: %s: %w", path, entryName, fs.ErrNotExist)
+}
+
+// ReadZipFile reads reimu-trie from entry f of an opened zip archive, for
+// applications reading many lexicons from one archive
+func ReadZipFile(f *zip.File, opts ...ReadOption) (*Lexicon, error) {
+	options := newReadOptions(opts)
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// Sizes are checked against the uncompressed size of entry, which is
+	// also verified by zip reader
+	budget := &readBudget{
+		size:        int64(f.UncompressedSize64),
+		memoryLimit: options.memoryLimit,
+	}
+	t, err := readLexicon(bufio.NewReader(rc), budget)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name, err)
+	}
+	return t, nil
+}